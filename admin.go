@@ -0,0 +1,40 @@
+package conlangdev
+
+import "context"
+
+// Site-wide roles of users, separate from their roles on languages and
+// teams. Administrators can moderate users and public content.
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
+// Narrows down the users listed for administrators. Empty fields match
+// every user.
+type UserFilter struct {
+	// Matched against the start of usernames and email addresses.
+	Query string `json:"query"`
+	Role  string `json:"role" validate:"omitempty,oneof=user admin"`
+	// Only lists suspended users.
+	Suspended bool `json:"suspended"`
+}
+
+type AdminService interface {
+	// Creates a user with the given role, marking their email address as
+	// verified if asked to, for setting up accounts without going through
+	// sign up.
+	CreateUser(ctx context.Context, create UserCreate, role string, verified bool) (*User, error)
+	FindUsers(ctx context.Context, filter UserFilter) ([]*User, error)
+	SetUserRole(ctx context.Context, user *User, role string) error
+	// Stops a user from logging in or using the API, ending their sessions.
+	// Administrators have to lose their role before they can be suspended.
+	SuspendUser(ctx context.Context, user *User, reason string) error
+	UnsuspendUser(ctx context.Context, user *User) error
+	// Hides the bio, avatar and links of a user from their public profile.
+	HideUserProfile(ctx context.Context, user *User) error
+	ShowUserProfile(ctx context.Context, user *User) error
+	// Hides a language from everyone but its members, whatever its
+	// visibility, until an administrator shows it again.
+	HideLanguage(ctx context.Context, language *Language, reason string) error
+	ShowLanguage(ctx context.Context, language *Language) error
+}
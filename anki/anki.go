@@ -0,0 +1,421 @@
+// Package anki builds Anki flashcard decks (.apkg files) from the words
+// of a language.
+//
+// An .apkg file is a zip archive containing an SQLite collection database
+// (`collection.anki2`) and a JSON media manifest. Notes are given GUIDs
+// derived from each word's UID, so re-importing a deck for the same
+// language updates existing cards rather than duplicating them.
+package anki
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DefaultFront = "{{Headword}}"
+	DefaultBack  = "{{FrontSide}}<hr id=answer>{{Definition}}<br><i>{{PartOfSpeech}}</i>"
+)
+
+// Note fields available to card templates, in the order they are stored
+// in each note. Any custom fields the language defines follow these, under
+// their own names.
+var Fields = []string{
+	"Headword", "PartOfSpeech", "Definition", "Pronunciation",
+	"GrammarClass", "Gender", "Etymology", "Notes", "UID",
+}
+
+// Special template fields which are provided by Anki itself.
+var builtinFields = map[string]bool{
+	"FrontSide": true, "Tags": true, "Type": true,
+	"Deck": true, "Subdeck": true, "Card": true,
+}
+
+type Options struct {
+	DeckName string
+	Front    string
+	Back     string
+	// Custom fields defined by the language, to be added to the notes.
+	CustomFields []*conlangdev.FieldDefinition
+}
+
+type Deck struct {
+	language *conlangdev.Language
+	options  Options
+	fields   []string
+}
+
+// Creates a new deck for the given language. Empty options fall back to
+// the language name and the default templates.
+func NewDeck(language *conlangdev.Language, options Options) (*Deck, error) {
+	if options.DeckName == "" {
+		options.DeckName = language.Name
+	}
+	if options.Front == "" {
+		options.Front = DefaultFront
+	}
+	if options.Back == "" {
+		options.Back = DefaultBack
+	}
+
+	fields := append([]string{}, Fields...)
+	for _, field := range options.CustomFields {
+		fields = append(fields, field.Name)
+	}
+
+	var invalid []string
+	if err := validateTemplate(options.Front, fields); err != nil {
+		invalid = append(invalid, "front")
+	}
+	if err := validateTemplate(options.Back, fields); err != nil {
+		invalid = append(invalid, "back")
+	}
+	if len(invalid) > 0 {
+		return nil, &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "templates may only reference these fields: " + strings.Join(fields, ", "),
+			StatusCode: http.StatusBadRequest,
+			Fields:     invalid,
+		}
+	}
+
+	return &Deck{language, options, fields}, nil
+}
+
+var templateTag = regexp.MustCompile(`{{([^{}]*)}}`)
+
+// Checks that every field referenced by a template exists on the note type.
+func validateTemplate(template string, fields []string) error {
+	known := make(map[string]bool)
+	for _, field := range fields {
+		known[field] = true
+	}
+	for _, match := range templateTag.FindAllStringSubmatch(template, -1) {
+		name := strings.TrimSpace(match[1])
+		name = strings.TrimLeft(name, "#^/")
+		// Filters are given as `filter:Field`, the field always comes last.
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		if !known[name] && !builtinFields[name] {
+			return fmt.Errorf("unknown template field %q", name)
+		}
+	}
+	return nil
+}
+
+// Derives a stable 63-bit ID from the given parts, so that the model and
+// deck keep their IDs between exports of the same language.
+func stableID(parts ...string) int64 {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return int64(binary.BigEndian.Uint64(sum[:8]) >> 1)
+}
+
+const base91Table = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&()*+,-./:;<=>?@[]^_`{|}~"
+
+// Generates a note GUID from a word UID in the same base91 format Anki
+// uses for its own GUIDs.
+func GUIDForWord(uid uint64) string {
+	sum := sha256.Sum256([]byte("conlangdev:word:" + strconv.FormatUint(uid, 10)))
+	n := binary.BigEndian.Uint64(sum[:8])
+	var guid []byte
+	for n > 0 {
+		guid = append([]byte{base91Table[n%91]}, guid...)
+		n /= 91
+	}
+	return string(guid)
+}
+
+// Formats a word field as note HTML.
+func fieldHTML(value string) string {
+	return strings.ReplaceAll(html.EscapeString(value), "\n", "<br>")
+}
+
+// Lists the fields of a note for a word. Word references in custom fields
+// are given as the headword of the word referred to.
+func (d *Deck) noteFields(word *conlangdev.Word, headwords map[uint64]string) []string {
+	fields := []string{
+		fieldHTML(word.Headword), fieldHTML(word.PartOfSpeech),
+		fieldHTML(word.Definition), fieldHTML(word.Pronunciation),
+		fieldHTML(word.GrammarClass), fieldHTML(word.Gender),
+		fieldHTML(word.Etymology), fieldHTML(word.Notes),
+		strconv.FormatUint(word.UID, 10),
+	}
+	for _, field := range d.options.CustomFields {
+		fields = append(fields, fieldHTML(customFieldText(field, word.CustomFields[field.Name], headwords)))
+	}
+	return fields
+}
+
+// Formats a custom field value as plain text, giving an empty string for
+// fields which are not set.
+func customFieldText(field *conlangdev.FieldDefinition, value json.RawMessage, headwords map[uint64]string) string {
+	if len(value) == 0 {
+		return ""
+	}
+	switch field.Kind {
+	case conlangdev.FieldKindWord:
+		var uid uint64
+		if err := json.Unmarshal(value, &uid); err == nil {
+			return headwords[uid]
+		}
+	case conlangdev.FieldKindBoolean:
+		var set bool
+		if err := json.Unmarshal(value, &set); err == nil && set {
+			return field.Label
+		}
+		return ""
+	default:
+		var text string
+		if err := json.Unmarshal(value, &text); err == nil {
+			return text
+		}
+	}
+	return string(value)
+}
+
+// Formats the tags of a word as note tags, which are separated by spaces
+// and so cannot contain any.
+func noteTags(word *conlangdev.Word) string {
+	if len(word.Tags) == 0 {
+		return ""
+	}
+	tags := make([]string, len(word.Tags))
+	for i, tag := range word.Tags {
+		tags[i] = strings.Join(strings.Fields(tag), "_")
+	}
+	return " " + strings.Join(tags, " ") + " "
+}
+
+// Anki's checksum of the first field, used for duplicate detection.
+func fieldChecksum(field string) int64 {
+	sum := sha1.Sum([]byte(field))
+	n, _ := strconv.ParseInt(hex.EncodeToString(sum[:4]), 16, 64)
+	return n
+}
+
+// Writes the deck containing the given words as an .apkg archive.
+func (d *Deck) WriteAPKG(ctx context.Context, w io.Writer, words []*conlangdev.Word) error {
+	dir, err := os.MkdirTemp("", "conlangdev-anki-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "collection.anki2")
+	if err := d.writeCollection(ctx, path, words); err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	collection, err := archive.Create("collection.anki2")
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(collection, file); err != nil {
+		return err
+	}
+	media, err := archive.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := media.Write([]byte("{}")); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func (d *Deck) writeCollection(ctx context.Context, path string, words []*conlangdev.Word) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range strings.Split(collectionSchema, ";\n") {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	language := strconv.FormatUint(uint64(d.language.ID), 10)
+	// The note type changes along with the custom fields, so it has to
+	// be given a new ID for Anki to pick up the change
+	modelParts := []string{"model", language}
+	if len(d.options.CustomFields) > 0 {
+		modelParts = append(modelParts, strings.Join(d.fields, "\x1f"))
+	}
+	modelID := stableID(modelParts...)
+	deckID := stableID("deck", language)
+
+	models, decks, err := d.collectionJSON(modelID, deckID, now)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		now.Unix(), now.UnixMilli(), now.UnixMilli(),
+		collectionConf, models, decks, deckConf,
+	); err != nil {
+		return err
+	}
+
+	// Note and card IDs only need to be unique within this collection, as
+	// Anki matches notes by GUID when importing.
+	headwords := make(map[uint64]string, len(words))
+	for _, word := range words {
+		headwords[word.UID] = word.Headword
+	}
+	base := now.UnixMilli()
+	for i, word := range words {
+		fields := d.noteFields(word, headwords)
+		noteID := base + int64(i)
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`,
+			noteID, GUIDForWord(word.UID), modelID, now.Unix(), noteTags(word),
+			strings.Join(fields, "\x1f"), fields[0], fieldChecksum(fields[0]),
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+			noteID, noteID, deckID, now.Unix(), i+1,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Builds the JSON note type and deck definitions stored in the collection.
+func (d *Deck) collectionJSON(modelID int64, deckID int64, now time.Time) (string, string, error) {
+	flds := make([]map[string]interface{}, len(d.fields))
+	for i, name := range d.fields {
+		flds[i] = map[string]interface{}{
+			"name": name, "ord": i, "font": "Arial", "size": 20,
+			"media": []string{}, "rtl": false, "sticky": false,
+		}
+	}
+
+	models, err := json.Marshal(map[string]interface{}{
+		strconv.FormatInt(modelID, 10): map[string]interface{}{
+			"id":        modelID,
+			"name":      "conlang.dev: " + d.language.Name,
+			"type":      0,
+			"mod":       now.Unix(),
+			"usn":       -1,
+			"sortf":     0,
+			"did":       deckID,
+			"flds":      flds,
+			"tags":      []string{},
+			"vers":      []string{},
+			"req":       [][]interface{}{{0, "any", []int{0}}},
+			"css":       ".card { font-family: arial; font-size: 20px; text-align: center; }",
+			"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\begin{document}\n",
+			"latexPost": "\\end{document}",
+			"tmpls": []map[string]interface{}{{
+				"name":  "Card 1",
+				"ord":   0,
+				"qfmt":  d.options.Front,
+				"afmt":  d.options.Back,
+				"bqfmt": "",
+				"bafmt": "",
+				"did":   nil,
+			}},
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	deck := func(id int64, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name, "desc": "", "mod": now.Unix(), "usn": -1,
+			"conf": 1, "dyn": 0, "collapsed": false, "extendNew": 10, "extendRev": 50,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0},
+			"lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	decks, err := json.Marshal(map[string]interface{}{
+		"1":                           deck(1, "Default"),
+		strconv.FormatInt(deckID, 10): deck(deckID, d.options.DeckName),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(models), string(decks), nil
+}
+
+const collectionConf = `{"activeDecks":[1],"curDeck":1,"newSpread":0,"collapseTime":1200,"timeLim":0,"estTimes":true,"dueCounts":true,"curModel":null,"nextPos":1,"sortType":"noteFld","sortBackwards":false,"addToCur":true}`
+
+const deckConf = `{"1":{"id":1,"name":"Default","mod":0,"usn":0,"maxTaken":60,"autoplay":true,"timer":0,"replayq":true,"dyn":false,"new":{"bury":true,"delays":[1,10],"initialFactor":2500,"ints":[1,4,7],"order":1,"perDay":20,"separate":true},"lapse":{"delays":[10],"leechAction":0,"leechFails":8,"minInt":1,"mult":0},"rev":{"bury":true,"ease4":1.3,"fuzz":0.05,"ivlFct":1,"maxIvl":36500,"minSpace":1,"perDay":100}}}`
+
+const collectionSchema = `CREATE TABLE col (
+    id INTEGER PRIMARY KEY, crt INTEGER NOT NULL, mod INTEGER NOT NULL,
+    scm INTEGER NOT NULL, ver INTEGER NOT NULL, dty INTEGER NOT NULL,
+    usn INTEGER NOT NULL, ls INTEGER NOT NULL, conf TEXT NOT NULL,
+    models TEXT NOT NULL, decks TEXT NOT NULL, dconf TEXT NOT NULL,
+    tags TEXT NOT NULL
+);
+CREATE TABLE notes (
+    id INTEGER PRIMARY KEY, guid TEXT NOT NULL, mid INTEGER NOT NULL,
+    mod INTEGER NOT NULL, usn INTEGER NOT NULL, tags TEXT NOT NULL,
+    flds TEXT NOT NULL, sfld INTEGER NOT NULL, csum INTEGER NOT NULL,
+    flags INTEGER NOT NULL, data TEXT NOT NULL
+);
+CREATE TABLE cards (
+    id INTEGER PRIMARY KEY, nid INTEGER NOT NULL, did INTEGER NOT NULL,
+    ord INTEGER NOT NULL, mod INTEGER NOT NULL, usn INTEGER NOT NULL,
+    type INTEGER NOT NULL, queue INTEGER NOT NULL, due INTEGER NOT NULL,
+    ivl INTEGER NOT NULL, factor INTEGER NOT NULL, reps INTEGER NOT NULL,
+    lapses INTEGER NOT NULL, left INTEGER NOT NULL, odue INTEGER NOT NULL,
+    odid INTEGER NOT NULL, flags INTEGER NOT NULL, data TEXT NOT NULL
+);
+CREATE TABLE revlog (
+    id INTEGER PRIMARY KEY, cid INTEGER NOT NULL, usn INTEGER NOT NULL,
+    ease INTEGER NOT NULL, ivl INTEGER NOT NULL, lastIvl INTEGER NOT NULL,
+    factor INTEGER NOT NULL, time INTEGER NOT NULL, type INTEGER NOT NULL
+);
+CREATE TABLE graves (
+    usn INTEGER NOT NULL, oid INTEGER NOT NULL, type INTEGER NOT NULL
+);
+CREATE INDEX ix_notes_usn ON notes (usn);
+CREATE INDEX ix_cards_usn ON cards (usn);
+CREATE INDEX ix_revlog_usn ON revlog (usn);
+CREATE INDEX ix_cards_nid ON cards (nid);
+CREATE INDEX ix_cards_sched ON cards (did, queue, due);
+CREATE INDEX ix_revlog_cid ON revlog (cid);
+CREATE INDEX ix_notes_csum ON notes (csum)`
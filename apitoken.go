@@ -0,0 +1,74 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+// Every API token starts with this, which is how they are told apart from
+// access tokens.
+const APITokenPrefix = "cdpat_"
+
+// Access an API token can be given to a language. Read access allows
+// viewing the language, while write access also allows commenting and
+// editing words. Nothing beyond that can be done with an API token.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// Access given to an API token for a single language.
+type APITokenScope struct {
+	LanguageID uint   `json:"language_id"`
+	Access     string `json:"access" validate:"required,oneof=read write"`
+}
+
+// A long-lived credential for scripts, which only works on the languages it
+// has been scoped to. The token itself is only given out when it is created,
+// as only a hash of it is kept.
+type APIToken struct {
+	ID         uint             `json:"id"`
+	CreatedAt  time.Time        `json:"created_at"`
+	LastUsedAt *time.Time       `json:"last_used_at"`
+	ExpiresAt  *time.Time       `json:"expires_at"`
+	UserID     uint             `json:"user_id"`
+	Name       string           `json:"name"`
+	Scopes     []*APITokenScope `json:"scopes"`
+}
+
+// Whether the token lets its user perform the action on the language. The
+// user must still be allowed to perform it themselves.
+func (t *APIToken) Allows(language *Language, action string) bool {
+	for _, scope := range t.Scopes {
+		if scope.LanguageID != language.ID {
+			continue
+		}
+		switch action {
+		case ActionViewLanguage:
+			return true
+		case ActionComment, ActionEditWords:
+			return scope.Access == ScopeWrite
+		}
+		return false
+	}
+	return false
+}
+
+type APITokenCreate struct {
+	Name string `json:"name" validate:"required,max=64"`
+	// When the token stops working, or nil for a token which lasts until
+	// it is revoked.
+	ExpiresAt *time.Time       `json:"expires_at"`
+	Scopes    []*APITokenScope `json:"scopes" validate:"required,min=1,dive"`
+}
+
+type APITokenService interface {
+	// Creates a token for the user, giving it along with the token itself.
+	CreateAPIToken(ctx context.Context, user *User, create APITokenCreate) (*APIToken, string, error)
+	// Gives the API token and its user for a token, as long as it has not
+	// expired or been revoked.
+	GetAPITokenByToken(ctx context.Context, token string) (*APIToken, *User, error)
+	FindAPITokensForUser(ctx context.Context, user *User) ([]*APIToken, error)
+	GetAPITokenForUser(ctx context.Context, user *User, id uint) (*APIToken, error)
+	RevokeAPIToken(ctx context.Context, token *APIToken) error
+}
@@ -0,0 +1,42 @@
+package conlangdev
+
+import "context"
+
+// Actions which can be performed on a language, each of which requires a
+// minimum role.
+const (
+	ActionViewLanguage     = "view_language"
+	ActionComment          = "comment"
+	ActionEditWords        = "edit_words"
+	ActionManageLanguage   = "manage_language"
+	ActionManageAdmins     = "manage_admins"
+	ActionDeleteLanguage   = "delete_language"
+	ActionTransferLanguage = "transfer_language"
+)
+
+// Gives the minimum role needed to perform an action.
+func RoleForAction(action string) string {
+	switch action {
+	case ActionViewLanguage:
+		return RoleViewer
+	case ActionComment:
+		return RoleCommenter
+	case ActionEditWords:
+		return RoleEditor
+	case ActionManageLanguage:
+		return RoleAdmin
+	}
+	return RoleOwner
+}
+
+// The authorization service decides what users may do to languages. All
+// permission checks should go through it rather than comparing user IDs.
+type AuthorizationService interface {
+	// Finds the role the user holds on the language, which may be empty.
+	// The user may be nil for anonymous callers.
+	GetRoleForUser(ctx context.Context, user *User, language *Language) (string, error)
+	// Returns nil if the user may perform the action on the language,
+	// otherwise a `*Error` suitable for returning to the user. Languages
+	// the user cannot see at all are reported as not found.
+	Authorize(ctx context.Context, user *User, language *Language, action string) error
+}
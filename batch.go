@@ -0,0 +1,54 @@
+package conlangdev
+
+const (
+	BatchCreate      = "create"
+	BatchUpdate      = "update"
+	BatchDelete      = "delete"
+	BatchUpdateWhere = "update_where"
+)
+
+// A list of changes to the words of a language, made together or not at
+// all.
+type WordBatch struct {
+	Operations []*WordBatchOperation `json:"operations" validate:"required,min=1,max=1000"`
+}
+
+// A single change within a batch. Updates and deletes name a word by its
+// UID, and may give the version of the word they were based on to have the
+// operation fail if it has changed since. Updates with `update_where`
+// apply to every word matching the given fields.
+type WordBatchOperation struct {
+	Op      string      `json:"op" validate:"required,oneof=create update delete update_where"`
+	UID     uint64      `json:"uid" validate:"required_if=Op update,required_if=Op delete"`
+	Version int         `json:"version"`
+	Create  *WordCreate `json:"create" validate:"required_if=Op create"`
+	Update  *WordUpdate `json:"update" validate:"required_if=Op update,required_if=Op update_where"`
+	Where   *WordMatch  `json:"where" validate:"required_if=Op update_where"`
+}
+
+// Fields which words must have exactly to be matched. Fields which are
+// left out match any word.
+type WordMatch struct {
+	Headword     *string `json:"headword"`
+	PartOfSpeech *string `json:"part_of_speech"`
+	GrammarClass *string `json:"grammar_class"`
+	Gender       *string `json:"gender"`
+}
+
+type WordBatchOperationResult struct {
+	Index    int     `json:"index"`
+	Op       string  `json:"op"`
+	UID      uint64  `json:"uid,omitempty"`
+	Words    []*Word `json:"words,omitempty"`
+	Affected int     `json:"affected"`
+	// Why the operation failed, if it did. The operations after it are
+	// not attempted.
+	Error error `json:"-"`
+}
+
+// The outcome of a batch. If any operation failed, nothing was committed
+// and the results stop at the failed operation.
+type WordBatchResult struct {
+	Committed bool                        `json:"committed"`
+	Results   []*WordBatchOperationResult `json:"results"`
+}
@@ -0,0 +1,122 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+// A named copy of every word in the main line of a language, as it was at
+// the time the snapshot was taken.
+type Snapshot struct {
+	ID             uint      `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	LanguageID     uint      `json:"language_id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	AuthorID       uint      `json:"author_id"`
+	AuthorUsername string    `json:"author_username"`
+	WordCount      int       `json:"word_count"`
+}
+
+type SnapshotCreate struct {
+	Name        string `json:"name" validate:"required,max=255"`
+	Description string `json:"description"`
+}
+
+// A separate line of words within a language, started from a snapshot of
+// the main line. Branch words remember the UID of the main word they were
+// copied from, and the snapshot serves as the common ancestor when the
+// branch is merged back.
+type Branch struct {
+	ID             uint       `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	LanguageID     uint       `json:"language_id"`
+	Name           string     `json:"name"`
+	SnapshotID     uint       `json:"snapshot_id"`
+	AuthorID       uint       `json:"author_id"`
+	AuthorUsername string     `json:"author_username"`
+	MergedAt       *time.Time `json:"merged_at"`
+}
+
+type BranchCreate struct {
+	// The main line is not a branch of its own, so its name is reserved.
+	Name     string `json:"name" validate:"required,max=255,ne=main"`
+	Snapshot string `json:"snapshot" validate:"required"`
+}
+
+const (
+	MergeResolveMain   = "main"
+	MergeResolveBranch = "branch"
+)
+
+type BranchMerge struct {
+	// Reports what the merge would do without changing anything.
+	DryRun bool `json:"dry_run"`
+	// Which side wins when both have changed, if either. A merge with
+	// conflicts and no resolution changes nothing.
+	Resolve string `json:"resolve" validate:"omitempty,oneof=main branch"`
+}
+
+// A change on both sides of a merge which cannot be reconciled
+// automatically. Either a single field was changed to different values,
+// or the word was deleted on one side and changed on the other.
+type MergeConflict struct {
+	UID       uint64 `json:"uid"`
+	BranchUID uint64 `json:"branch_uid,omitempty"`
+	Headword  string `json:"headword"`
+	Field     string `json:"field,omitempty"`
+	Base      string `json:"base,omitempty"`
+	Main      string `json:"main,omitempty"`
+	Branch    string `json:"branch,omitempty"`
+	// The side which deleted the word, for conflicts over a deletion.
+	DeletedIn string `json:"deleted_in,omitempty"`
+}
+
+// A word added to, updated in or deleted from the main line by a merge.
+// Words new on the branch have no main UID until the merge is made.
+type MergeChange struct {
+	UID       uint64 `json:"uid,omitempty"`
+	BranchUID uint64 `json:"branch_uid,omitempty"`
+	Headword  string `json:"headword"`
+}
+
+type MergeResult struct {
+	Merged    bool             `json:"merged"`
+	Added     []*MergeChange   `json:"added"`
+	Updated   []*MergeChange   `json:"updated"`
+	Deleted   []*MergeChange   `json:"deleted"`
+	Conflicts []*MergeConflict `json:"conflicts"`
+}
+
+// Merges a single value three ways given its common ancestor. Where both
+// sides have changed it to different values, the main value is returned
+// and the merge reports a conflict.
+func MergeValue(base string, main string, branch string) (string, bool) {
+	switch {
+	case main == branch, branch == base:
+		return main, true
+	case main == base:
+		return branch, true
+	default:
+		return main, false
+	}
+}
+
+// Snapshot and branch services act on the main line of a language
+// regardless of any branch in the context, and record the user in the
+// context as the author of anything they create.
+type BranchService interface {
+	FindSnapshotsForLanguage(ctx context.Context, language *Language) ([]*Snapshot, error)
+	GetSnapshotByName(ctx context.Context, language *Language, name string) (*Snapshot, error)
+	ListWordsForSnapshot(ctx context.Context, snapshot *Snapshot) ([]*Word, error)
+	CreateSnapshot(ctx context.Context, language *Language, create SnapshotCreate) (*Snapshot, error)
+	DeleteSnapshot(ctx context.Context, snapshot *Snapshot) error
+	FindBranchesForLanguage(ctx context.Context, language *Language) ([]*Branch, error)
+	GetBranchByName(ctx context.Context, language *Language, name string) (*Branch, error)
+	CreateBranch(ctx context.Context, language *Language, create BranchCreate) (*Branch, error)
+	DeleteBranch(ctx context.Context, branch *Branch) error
+	// Merges the changes made on a branch since its snapshot back into the
+	// main line, reporting conflicts per word and per field.
+	MergeBranch(ctx context.Context, branch *Branch, merge BranchMerge) (*MergeResult, error)
+}
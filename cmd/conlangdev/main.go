@@ -1,19 +1,31 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/conlangdev/conlangdev"
+	"github.com/conlangdev/conlangdev/mail"
+	"github.com/conlangdev/conlangdev/oidc"
+	"github.com/conlangdev/conlangdev/publish"
+	"github.com/conlangdev/conlangdev/ratelimit"
 	"github.com/conlangdev/conlangdev/server"
 	"github.com/conlangdev/conlangdev/sql"
 	"github.com/go-playground/validator/v10"
 	log "github.com/sirupsen/logrus"
 )
 
-func Run() error {
+func OpenDatabase() (*sql.DB, error) {
 	database := sql.NewDB(
 		os.Getenv("MARIADB_HOST"),
 		os.Getenv("MARIADB_USER"),
@@ -21,45 +33,553 @@ func Run() error {
 		os.Getenv("MARIADB_DATABASE"),
 	)
 	if err := database.Open(); err != nil {
+		return nil, err
+	}
+	return database, nil
+}
+
+func Run() error {
+	database, err := OpenDatabase()
+	if err != nil {
 		return err
 	}
+	defer database.Close()
 
 	jwtSecret, ok := os.LookupEnv("CONLANGDEV_JWT_SECRET")
 	if !ok {
 		return errors.New("no CONLANGDEV_JWT_SECRET environment variable found")
 	}
 
+	retention, err := TrashRetention()
+	if err != nil {
+		return err
+	}
+
+	mailer, err := NewMailer()
+	if err != nil {
+		return err
+	}
+
+	limits, err := RateLimits()
+	if err != nil {
+		return err
+	}
+
+	providers, err := NewOIDCProviders()
+	if err != nil {
+		return err
+	}
+
 	validate := validator.New()
+	trashService := sql.NewTrashService(database, retention)
+	sessionService := sql.NewSessionService(database, jwtSecret)
+	userService := sql.NewUserService(database, validate)
 	server := server.
 		NewServer().
 		WithAddr(os.Getenv("CONLANGDEV_ADDR")).
-		WithUserService(sql.NewUserService(database, validate, jwtSecret)).
+		WithPublicURL(os.Getenv("CONLANGDEV_PUBLIC_URL")).
+		WithMailer(mailer).
+		WithRateLimits(limits).
+		WithUserService(userService).
+		WithSessionService(sessionService).
+		WithPasswordResetService(sql.NewPasswordResetService(database, validate)).
+		WithEmailVerificationService(sql.NewEmailVerificationService(database, validate)).
+		WithAPITokenService(sql.NewAPITokenService(database, validate)).
 		WithLanguageService(sql.NewLanguageService(database, validate)).
-		WithWordService(sql.NewWordService(database, validate))
+		WithWordService(sql.NewWordService(database, validate)).
+		WithCollaboratorService(sql.NewCollaboratorService(database, validate)).
+		WithAuthorizationService(sql.NewAuthorizationService(database)).
+		WithTeamService(sql.NewTeamService(database, validate)).
+		WithWordRevisionService(sql.NewWordRevisionService(database)).
+		WithTrashService(trashService).
+		WithBranchService(sql.NewBranchService(database, validate)).
+		WithFieldDefinitionService(sql.NewFieldDefinitionService(database, validate)).
+		WithVocabularyService(sql.NewVocabularyService(database, validate)).
+		WithConceptService(sql.NewConceptService(database, validate)).
+		WithStatsService(sql.NewStatsService(database)).
+		WithOIDCService(sql.NewOIDCService(database)).
+		WithOIDCRedirectURIs(OIDCRedirectURIs()).
+		WithTwoFactorService(sql.NewTwoFactorService(database)).
+		WithAdminService(sql.NewAdminService(database, validate))
+	for _, provider := range providers {
+		server.WithOIDCProvider(provider)
+	}
 	if err := server.Open(); err != nil {
 		return err
 	}
 	log.Infof("🌍 Listening on %s", server.Addr)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	go PurgeTrash(ctx, trashService, time.Hour)
+	go PurgeSessions(ctx, sessionService, time.Hour)
+	go PurgeUsers(ctx, userService, time.Hour)
+
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
 	<-ch
 
 	log.Info("👋 Shutting down...")
+	cancel()
 	server.Close()
 	return nil
 }
 
+// Reads how long deleted items are kept in the trash from the environment,
+// falling back to the default if it is not set.
+func TrashRetention() (time.Duration, error) {
+	value, ok := os.LookupEnv("CONLANGDEV_TRASH_RETENTION")
+	if !ok {
+		return conlangdev.DefaultTrashRetention, nil
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("CONLANGDEV_TRASH_RETENTION must be a positive duration such as 720h, not %q", value)
+	}
+	return retention, nil
+}
+
+// Reads the rate limits from the environment, falling back to the defaults
+// for any which are not set. Limits are written as requests per window,
+// such as 10/1m, or off to turn them off.
+func RateLimits() (server.RateLimits, error) {
+	limits := server.DefaultRateLimits
+	for name, limit := range map[string]*conlangdev.RateLimit{
+		"CONLANGDEV_RATE_LIMIT_LOGIN":    &limits.Login,
+		"CONLANGDEV_RATE_LIMIT_REGISTER": &limits.Register,
+		"CONLANGDEV_RATE_LIMIT_ACCOUNT":  &limits.Account,
+	} {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		var err error
+		if *limit, err = ratelimit.ParseLimit(value); err != nil {
+			return server.RateLimits{}, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if value, ok := os.LookupEnv("CONLANGDEV_LOGIN_LOCKOUT_ATTEMPTS"); ok {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 0 {
+			return server.RateLimits{}, fmt.Errorf("CONLANGDEV_LOGIN_LOCKOUT_ATTEMPTS must be a number of attempts, or 0 to turn lockouts off, not %q", value)
+		}
+		limits.Lockout.Attempts = attempts
+	}
+	for name, delay := range map[string]*time.Duration{
+		"CONLANGDEV_LOGIN_LOCKOUT_DELAY":     &limits.Lockout.Delay,
+		"CONLANGDEV_LOGIN_LOCKOUT_MAX_DELAY": &limits.Lockout.MaxDelay,
+	} {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		var err error
+		if *delay, err = time.ParseDuration(value); err != nil || *delay <= 0 {
+			return server.RateLimits{}, fmt.Errorf("%s must be a positive duration such as 30s, not %q", name, value)
+		}
+	}
+	return limits, nil
+}
+
+// Sets up the identity providers named in CONLANGDEV_OIDC_PROVIDERS, a
+// comma-separated list, each configured by CONLANGDEV_OIDC_<NAME>_ISSUER,
+// _CLIENT_ID, _CLIENT_SECRET, _DISPLAY_NAME and _SCOPES.
+func NewOIDCProviders() ([]conlangdev.OIDCProvider, error) {
+	var providers []conlangdev.OIDCProvider
+
+	for _, name := range strings.Split(os.Getenv("CONLANGDEV_OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "CONLANGDEV_OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := oidc.Config{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID must be set to use the %s identity provider", prefix, prefix, name)
+		}
+		providers = append(providers, oidc.NewProvider(config))
+	}
+
+	return providers, nil
+}
+
+// Gives the redirect URIs in CONLANGDEV_OIDC_REDIRECT_URIS, a comma-separated
+// list, which clients may ask identity providers to send users back to.
+func OIDCRedirectURIs() []string {
+	var uris []string
+	for _, uri := range strings.Split(os.Getenv("CONLANGDEV_OIDC_REDIRECT_URIS"), ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// Sets up the mailer chosen by CONLANGDEV_MAILER, which is either `smtp`,
+// `file` or `stdout`, the default.
+func NewMailer() (conlangdev.Mailer, error) {
+	from := os.Getenv("CONLANGDEV_MAIL_FROM")
+	if from == "" {
+		from = "conlangdev <noreply@localhost>"
+	}
+
+	switch kind := os.Getenv("CONLANGDEV_MAILER"); kind {
+	case "", "stdout":
+		return mail.NewWriterMailer(os.Stdout, from), nil
+	case "file":
+		dir := os.Getenv("CONLANGDEV_MAIL_DIR")
+		if dir == "" {
+			return nil, errors.New("CONLANGDEV_MAIL_DIR must be set to use the file mailer")
+		}
+		return mail.NewFileMailer(dir, from), nil
+	case "smtp":
+		host := os.Getenv("CONLANGDEV_SMTP_HOST")
+		if host == "" {
+			return nil, errors.New("CONLANGDEV_SMTP_HOST must be set to use the smtp mailer")
+		}
+		port := os.Getenv("CONLANGDEV_SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return mail.NewSMTPMailer(
+			host, port,
+			os.Getenv("CONLANGDEV_SMTP_USERNAME"), os.Getenv("CONLANGDEV_SMTP_PASSWORD"),
+			from,
+		), nil
+	default:
+		return nil, fmt.Errorf("CONLANGDEV_MAILER must be smtp, file or stdout, not %q", kind)
+	}
+}
+
+// Purges expired items from the trash straight away and then once every
+// interval, until the context is cancelled.
+func PurgeTrash(ctx context.Context, trashService conlangdev.TrashService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		languages, words, err := trashService.PurgeExpired(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.WithField("job", "purge_trash").Error(err.Error())
+		} else if languages > 0 || words > 0 {
+			log.WithField("job", "purge_trash").Infof("🗑️ Purged %d languages and %d words from the trash", languages, words)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deletes sessions which have expired or been revoked straight away and
+// then once every interval, until the context is cancelled.
+func PurgeSessions(ctx context.Context, sessionService conlangdev.SessionService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sessions, err := sessionService.PurgeSessions(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.WithField("job", "purge_sessions").Error(err.Error())
+		} else if sessions > 0 {
+			log.WithField("job", "purge_sessions").Infof("🔑 Purged %d ended sessions", sessions)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Permanently deletes accounts whose deletion grace period is up straight
+// away and then once every interval, until the context is cancelled.
+func PurgeUsers(ctx context.Context, userService conlangdev.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		users, err := userService.PurgeDeletedUsers(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.WithField("job", "purge_users").Error(err.Error())
+		} else if users > 0 {
+			log.WithField("job", "purge_users").Infof("🗑️ Purged %d deleted accounts", users)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func Migrate() error {
 	fmt.Println("not implemented yet sorry!")
 	return nil
 }
 
+// Publishes a language as a static website. The language is given as
+// `namespace/slug`, where the namespace is a username or team slug, and the
+// site is written to the given directory, or a directory named after the
+// slug if none is given.
+func Publish(arguments []string) error {
+	if len(arguments) == 0 {
+		return errors.New("usage: conlangdev publish <namespace>/<slug> [output directory]")
+	}
+	namespace, slug, ok := strings.Cut(arguments[0], "/")
+	if !ok || namespace == "" || slug == "" {
+		return fmt.Errorf("%q is not in the form <namespace>/<slug>", arguments[0])
+	}
+	dir := slug
+	if len(arguments) > 1 {
+		dir = arguments[1]
+	}
+
+	database, err := OpenDatabase()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	validate := validator.New()
+	userService := sql.NewUserService(database, validate)
+	teamService := sql.NewTeamService(database, validate)
+	languageService := sql.NewLanguageService(database, validate)
+	wordService := sql.NewWordService(database, validate)
+	fieldService := sql.NewFieldDefinitionService(database, validate)
+
+	language, err := languageService.GetLanguageByNamespaceAndSlug(ctx, namespace, slug)
+	if err != nil {
+		return err
+	}
+	authorizationService := sql.NewAuthorizationService(database)
+	if err := authorizationService.Authorize(ctx, nil, language, conlangdev.ActionViewLanguage); err != nil {
+		return fmt.Errorf("%s/%s is private, make it public or unlisted before publishing", namespace, slug)
+	}
+
+	// Credit the language to its owner's display name where there is one
+	author := namespace
+	if language.TeamID != 0 {
+		team, err := teamService.GetTeamByID(ctx, language.TeamID)
+		if err != nil {
+			return err
+		}
+		author = team.Name
+	} else {
+		owner, err := userService.GetUserByID(ctx, language.UserID)
+		if err != nil {
+			return err
+		}
+		if owner.DisplayName != "" {
+			author = owner.DisplayName
+		}
+	}
+
+	words, err := wordService.ListWordsForLanguage(ctx, language, conlangdev.WordFilter{})
+	if err != nil {
+		return err
+	}
+
+	fields, err := fieldService.FindFieldDefinitionsForLanguage(ctx, language)
+	if err != nil {
+		return err
+	}
+
+	site := &publish.Site{
+		Dir:      dir,
+		Author:   author,
+		Language: language,
+		Words:    words,
+		Fields:   fields,
+	}
+	result, err := site.Build(ctx)
+	if err != nil {
+		return err
+	}
+	log.Infof(
+		"📚 Published %s/%s to %s (%d words written, %d unchanged, %d removed)",
+		namespace, slug, dir, result.WordsWritten, result.WordsSkipped, result.WordsRemoved,
+	)
+	return nil
+}
+
+// Reads a password from the first line of standard input, so that it is
+// kept out of the shell history.
+func ReadPassword() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password given on standard input")
+	}
+	return password, nil
+}
+
+const adminUsage = `usage: conlangdev admin <command>
+commands:
+- create-user <username> <email> [--admin] [--verified]: creates a user, reading their password from standard input
+  (--verified marks their email address as verified, so they can publish languages straight away)
+- reset-password <username>: sets the password of a user, reading it from standard input
+- reset-2fa <username>: turns off two-factor authentication for a user who has lost access to it
+- set-role <username> <user|admin>: changes the site-wide role of a user
+- suspend <username> [reason]: stops a user from logging in or using the API
+- unsuspend <username>: lets a suspended user back in
+- list-users [query]: lists users whose username or email starts with the query`
+
+// Runs the administration commands, for looking after users without
+// going through the API. This is also how the first administrator is
+// created.
+func Admin(arguments []string) error {
+	if len(arguments) == 0 {
+		return errors.New(adminUsage)
+	}
+
+	database, err := OpenDatabase()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	validate := validator.New()
+	userService := sql.NewUserService(database, validate)
+	adminService := sql.NewAdminService(database, validate)
+
+	command, arguments := arguments[0], arguments[1:]
+	switch {
+	case command == "create-user" && len(arguments) >= 2:
+		role, verified := conlangdev.UserRoleUser, false
+		for _, flag := range arguments[2:] {
+			switch flag {
+			case "--admin":
+				role = conlangdev.UserRoleAdmin
+			case "--verified":
+				verified = true
+			default:
+				return errors.New(adminUsage)
+			}
+		}
+		password, err := ReadPassword()
+		if err != nil {
+			return err
+		}
+		user, err := adminService.CreateUser(ctx, conlangdev.UserCreate{
+			Username: arguments[0],
+			Email:    arguments[1],
+			Password: password,
+		}, role, verified)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			log.Infof("✉️ %s has to verify their email address before publishing, which they can ask for from the app", user.Username)
+		}
+		log.Infof("👤 Created %s %s", user.Role, user.Username)
+	case command == "reset-password" && len(arguments) == 1:
+		user, err := userService.GetUserByUsername(ctx, arguments[0])
+		if err != nil {
+			return err
+		}
+		password, err := ReadPassword()
+		if err != nil {
+			return err
+		}
+		if err := userService.UpdateUserPassword(ctx, user, password); err != nil {
+			return err
+		}
+		log.Infof("🔑 Changed the password of %s", user.Username)
+	case command == "reset-2fa" && len(arguments) == 1:
+		// For users who have lost both their authenticator app and their
+		// recovery codes, once an administrator has confirmed who they are
+		user, err := userService.GetUserByUsername(ctx, arguments[0])
+		if err != nil {
+			return err
+		}
+		if !user.TwoFactorEnabled() {
+			return fmt.Errorf("%s does not have two-factor authentication enabled", user.Username)
+		}
+		if err := sql.NewTwoFactorService(database).DisableTwoFactor(ctx, user); err != nil {
+			return err
+		}
+		log.Infof("🔓 Turned off two-factor authentication for %s", user.Username)
+	case command == "set-role" && len(arguments) == 2:
+		user, err := userService.GetUserByUsername(ctx, arguments[0])
+		if err != nil {
+			return err
+		}
+		if err := adminService.SetUserRole(ctx, user, arguments[1]); err != nil {
+			return err
+		}
+		log.Infof("👤 %s is now a %s", user.Username, user.Role)
+	case command == "suspend" && len(arguments) >= 1:
+		user, err := userService.GetUserByUsername(ctx, arguments[0])
+		if err != nil {
+			return err
+		}
+		if err := adminService.SuspendUser(ctx, user, strings.Join(arguments[1:], " ")); err != nil {
+			return err
+		}
+		log.Infof("🚫 Suspended %s", user.Username)
+	case command == "unsuspend" && len(arguments) == 1:
+		user, err := userService.GetUserByUsername(ctx, arguments[0])
+		if err != nil {
+			return err
+		}
+		if err := adminService.UnsuspendUser(ctx, user); err != nil {
+			return err
+		}
+		log.Infof("✅ Unsuspended %s", user.Username)
+	case command == "list-users" && len(arguments) <= 1:
+		var filter conlangdev.UserFilter
+		if len(arguments) == 1 {
+			filter.Query = arguments[0]
+		}
+		users, err := adminService.FindUsers(ctx, filter)
+		if err != nil {
+			return err
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "USERNAME\tEMAIL\tROLE\tJOINED\tSTATUS")
+		for _, user := range users {
+			status := "active"
+			if user.DeletedAt != nil {
+				status = "deleted"
+			} else if user.Suspended() {
+				status = "suspended"
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n",
+				user.Username, user.Email, user.Role,
+				user.CreatedAt.Format("2006-01-02"), status,
+			)
+		}
+		return table.Flush()
+	default:
+		return errors.New(adminUsage)
+	}
+	return nil
+}
+
 func PrintUsage() {
 	fmt.Println("usage: conlangdev [command]")
 	fmt.Println("commands:")
 	fmt.Println("- run: runs the web server")
 	fmt.Println("- migrate: prepares sql database")
+	fmt.Println("- publish <namespace>/<slug> [dir]: writes a language as a static website")
+	fmt.Println("- admin <command>: looks after users, see `conlangdev admin`")
 }
 
 func main() {
@@ -80,6 +600,16 @@ func main() {
 			log.WithField("command", "migrate").Fatal(err.Error())
 		}
 		os.Exit(0)
+	case "publish":
+		if err := Publish(arguments[1:]); err != nil {
+			log.WithField("command", "publish").Fatal(err.Error())
+		}
+		os.Exit(0)
+	case "admin":
+		if err := Admin(arguments[1:]); err != nil {
+			log.WithField("command", "admin").Fatal(err.Error())
+		}
+		os.Exit(0)
 	default:
 		PrintUsage()
 		os.Exit(1)
@@ -0,0 +1,91 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+// Roles a user can hold on a language, from least to most privileged. The
+// owner role cannot be granted and belongs only to a language's owner.
+const (
+	RoleViewer    = "viewer"
+	RoleCommenter = "commenter"
+	RoleEditor    = "editor"
+	RoleAdmin     = "admin"
+	RoleOwner     = "owner"
+)
+
+// Reports how privileged a role is, so that roles can be compared. Unknown
+// roles, including no role at all, rank lowest.
+func RoleRank(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleCommenter:
+		return 2
+	case RoleEditor:
+		return 3
+	case RoleAdmin:
+		return 4
+	case RoleOwner:
+		return 5
+	}
+	return 0
+}
+
+type Collaborator struct {
+	ID          uint       `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	LanguageID  uint       `json:"language_id"`
+	UserID      uint       `json:"user_id"`
+	Username    string     `json:"username"`
+	Role        string     `json:"role"`
+	InvitedByID uint       `json:"invited_by_id"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+}
+
+type CollaboratorInvite struct {
+	Username string `json:"username" validate:"required"`
+	Role     string `json:"role" validate:"required,oneof=viewer commenter editor admin"`
+}
+
+type CollaboratorUpdate struct {
+	Role string `json:"role" validate:"required,oneof=viewer commenter editor admin"`
+}
+
+// A record of a change to who can access a language.
+type AuditEntry struct {
+	ID              uint      `json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	LanguageID      uint      `json:"language_id"`
+	ActorID         uint      `json:"actor_id"`
+	ActorUsername   string    `json:"actor_username"`
+	Action          string    `json:"action"`
+	SubjectID       uint      `json:"subject_id"`
+	SubjectUsername string    `json:"subject_username"`
+	Details         string    `json:"details"`
+}
+
+const (
+	AuditInvite     = "collaborator.invite"
+	AuditAccept     = "collaborator.accept"
+	AuditRoleChange = "collaborator.role_change"
+	AuditRemove     = "collaborator.remove"
+	AuditTransfer   = "language.transfer"
+	AuditTrash      = "language.trash"
+	AuditRestore    = "language.restore"
+)
+
+// Collaborator services record the user in the context as the actor of
+// any audited change.
+type CollaboratorService interface {
+	GetCollaboratorForLanguage(ctx context.Context, language *Language, user *User) (*Collaborator, error)
+	FindCollaboratorsForLanguage(ctx context.Context, language *Language) ([]*Collaborator, error)
+	FindInvitesForUser(ctx context.Context, user *User) ([]*Collaborator, error)
+	InviteCollaborator(ctx context.Context, language *Language, invite CollaboratorInvite) (*Collaborator, error)
+	AcceptInvite(ctx context.Context, collaborator *Collaborator) error
+	UpdateCollaborator(ctx context.Context, collaborator *Collaborator, update CollaboratorUpdate) error
+	RemoveCollaborator(ctx context.Context, collaborator *Collaborator) error
+	FindAuditEntriesForLanguage(ctx context.Context, language *Language) ([]*AuditEntry, error)
+}
@@ -0,0 +1,48 @@
+package conlangdev
+
+import "context"
+
+// A reference list of concepts, such as the Swadesh list, used to track
+// how much of a basic vocabulary a language has coined words for. Lists
+// are built in rather than kept per language.
+type ConceptList struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Concepts    []*Concept `json:"concepts,omitempty"`
+}
+
+// A concept on a reference list. Concepts shared between lists have the
+// same ID on each, so a word linked to a concept counts towards every list
+// it appears on, though each list may gloss it differently.
+type Concept struct {
+	ID    string `json:"id"`
+	Gloss string `json:"gloss"`
+}
+
+// A concept on a list along with the words linked to it.
+type ConceptCoverageEntry struct {
+	*Concept
+	Words []*WordIndex `json:"words"`
+}
+
+// How many of the concepts on a list a language has words for. Concepts
+// are listed in the order of the list.
+type ConceptCoverage struct {
+	List    *ConceptList            `json:"list"`
+	Total   int                     `json:"total"`
+	Covered int                     `json:"covered"`
+	Coined  []*ConceptCoverageEntry `json:"coined"`
+	Missing []*Concept              `json:"missing"`
+}
+
+// Concept services act on the branch in the context, or the main line of
+// the language if there is none.
+type ConceptService interface {
+	FindConceptLists(ctx context.Context) ([]*ConceptList, error)
+	GetConceptList(ctx context.Context, id string) (*ConceptList, error)
+	GetCoverageForLanguage(ctx context.Context, language *Language, list *ConceptList) (*ConceptCoverage, error)
+	// Creates a word linked to the given concept, defining it by the gloss
+	// of the concept unless a definition is given.
+	CreateWordForConcept(ctx context.Context, language *Language, concept *Concept, create WordCreate) (*Word, error)
+}
@@ -0,0 +1,117 @@
+// Package concepts holds the reference concept lists built into
+// conlangdev, which words can be linked to in order to track how much of
+// a basic vocabulary a language covers.
+//
+// Each list is kept as a tab-separated file of concept IDs and glosses.
+// Concepts which appear on more than one list share their ID.
+package concepts
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+//go:embed lists/*.tsv
+var listFiles embed.FS
+
+var lists = []*conlangdev.ConceptList{
+	{
+		ID:          "swadesh-100",
+		Name:        "Swadesh 100",
+		Description: "Morris Swadesh's 1971 list of 100 items of basic vocabulary.",
+	},
+	{
+		ID:          "swadesh-207",
+		Name:        "Swadesh 207",
+		Description: "The extended Swadesh list of 207 items of basic vocabulary.",
+	},
+	{
+		ID:          "leipzig-jakarta",
+		Name:        "Leipzig–Jakarta",
+		Description: "The Leipzig–Jakarta list of the 100 words most resistant to borrowing.",
+	},
+	{
+		ID:          "sil-domains",
+		Name:        "SIL semantic domains",
+		Description: "The top two levels of the SIL semantic domain hierarchy.",
+	},
+}
+
+var (
+	listsByID    = make(map[string]*conlangdev.ConceptList)
+	knownConcept = make(map[string]bool)
+)
+
+func init() {
+	for _, list := range lists {
+		data, err := listFiles.ReadFile("lists/" + list.ID + ".tsv")
+		if err != nil {
+			panic(err)
+		}
+		if list.Concepts, err = parseList(data); err != nil {
+			panic(fmt.Sprintf("concepts: %s: %v", list.ID, err))
+		}
+		listsByID[list.ID] = list
+		for _, concept := range list.Concepts {
+			knownConcept[concept.ID] = true
+		}
+	}
+}
+
+func parseList(data []byte) ([]*conlangdev.Concept, error) {
+	concepts := make([]*conlangdev.Concept, 0)
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		parts := strings.Split(scanner.Text(), "\t")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("line %d: expected an ID and a gloss", line)
+		}
+		if seen[parts[0]] {
+			return nil, fmt.Errorf("line %d: duplicate concept %q", line, parts[0])
+		}
+		seen[parts[0]] = true
+		concepts = append(concepts, &conlangdev.Concept{ID: parts[0], Gloss: parts[1]})
+	}
+	return concepts, scanner.Err()
+}
+
+// Lists every built-in list, without their concepts.
+func Lists() []*conlangdev.ConceptList {
+	summaries := make([]*conlangdev.ConceptList, 0, len(lists))
+	for _, list := range lists {
+		summaries = append(summaries, &conlangdev.ConceptList{
+			ID:          list.ID,
+			Name:        list.Name,
+			Description: list.Description,
+		})
+	}
+	return summaries
+}
+
+// Gives the list with the given ID, or nil if there is none. The list is
+// shared, so it must not be changed.
+func Get(id string) *conlangdev.ConceptList {
+	return listsByID[id]
+}
+
+// Gives the concept with the given ID on a list, or nil if it is not on
+// the list.
+func Find(list *conlangdev.ConceptList, id string) *conlangdev.Concept {
+	for _, concept := range list.Concepts {
+		if concept.ID == id {
+			return concept
+		}
+	}
+	return nil
+}
+
+// Reports whether a concept is on any of the built-in lists.
+func Exists(id string) bool {
+	return knownConcept[id]
+}
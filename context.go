@@ -10,3 +10,35 @@ func GetUserFromContext(ctx context.Context) *User {
 	user, _ := ctx.Value("conlangdev_user").(*User)
 	return user
 }
+
+// Selects the branch which word services should act on. Without one they
+// act on the main line of the language.
+func NewContextWithBranch(ctx context.Context, branch *Branch) context.Context {
+	return context.WithValue(ctx, "conlangdev_branch", branch)
+}
+
+func GetBranchFromContext(ctx context.Context) *Branch {
+	branch, _ := ctx.Value("conlangdev_branch").(*Branch)
+	return branch
+}
+
+// Records the session a request was authenticated with.
+func NewContextWithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, "conlangdev_session", session)
+}
+
+func GetSessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value("conlangdev_session").(*Session)
+	return session
+}
+
+// Records the API token a request was authenticated with, which limits
+// what the request may do.
+func NewContextWithAPIToken(ctx context.Context, token *APIToken) context.Context {
+	return context.WithValue(ctx, "conlangdev_api_token", token)
+}
+
+func GetAPITokenFromContext(ctx context.Context) *APIToken {
+	token, _ := ctx.Value("conlangdev_api_token").(*APIToken)
+	return token
+}
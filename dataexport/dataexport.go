@@ -0,0 +1,77 @@
+// Package dataexport writes everything conlangdev holds about a user into
+// a zip archive, so that they can take a copy of their data with them.
+//
+// The archive holds `account.json` with the account itself, and a
+// directory under `languages/` for each language the user owns, holding
+// `language.json`, `fields.json` and `words.json`.
+package dataexport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"path"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+// A language along with everything in it.
+type Language struct {
+	Language *conlangdev.Language
+	Fields   []*conlangdev.FieldDefinition
+	Words    []*conlangdev.Word
+}
+
+type Archive struct {
+	CreatedAt  time.Time
+	User       *conlangdev.User
+	Sessions   []*conlangdev.Session
+	APITokens  []*conlangdev.APIToken
+	Identities []*conlangdev.OIDCIdentity
+	Languages  []*Language
+}
+
+// Adds a file to the archive holding the value as indented JSON.
+func writeJSON(archive *zip.Writer, name string, modified time.Time, v interface{}) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (a *Archive) Write(w io.Writer) error {
+	archive := zip.NewWriter(w)
+
+	if err := writeJSON(archive, "account.json", a.CreatedAt, map[string]interface{}{
+		"exported_at": a.CreatedAt,
+		"user":        a.User,
+		"sessions":    a.Sessions,
+		"api_tokens":  a.APITokens,
+		"identities":  a.Identities,
+	}); err != nil {
+		return err
+	}
+
+	for _, language := range a.Languages {
+		dir := path.Join("languages", language.Language.Slug)
+		if err := writeJSON(archive, path.Join(dir, "language.json"), a.CreatedAt, language.Language); err != nil {
+			return err
+		}
+		if err := writeJSON(archive, path.Join(dir, "fields.json"), a.CreatedAt, language.Fields); err != nil {
+			return err
+		}
+		if err := writeJSON(archive, path.Join(dir, "words.json"), a.CreatedAt, language.Words); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
@@ -18,13 +18,16 @@ type FieldsError struct {
 }
 
 const (
-	ECONFLICT       = "conflict"
-	ESERVER         = "server_error"
-	EBADREQUEST     = "bad_request"
-	EVALIDFAIL      = "validation_failed"
-	ENOTFOUND       = "not_found"
-	EUNAUTHORIZED   = "unauthorized"
-	ENOTIMPLEMENTED = "not_implemented"
+	ECONFLICT             = "conflict"
+	ESERVER               = "server_error"
+	EBADREQUEST           = "bad_request"
+	EVALIDFAIL            = "validation_failed"
+	ENOTFOUND             = "not_found"
+	EUNAUTHORIZED         = "unauthorized"
+	ENOTIMPLEMENTED       = "not_implemented"
+	EPRECONDITIONFAILED   = "precondition_failed"
+	EPRECONDITIONREQUIRED = "precondition_required"
+	ETOOMANYREQUESTS      = "too_many_requests"
 )
 
 func (e *Error) Error() string {
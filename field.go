@@ -0,0 +1,56 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	FieldKindText    = "text"
+	FieldKindEnum    = "enum"
+	FieldKindNumber  = "number"
+	FieldKindBoolean = "boolean"
+	// Holds the UID of another word in the same language.
+	FieldKindWord = "word"
+)
+
+// A custom field which the words of a language may have, beyond the fixed
+// set every word has. Values are kept on each word under the field name.
+type FieldDefinition struct {
+	ID         uint      `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	LanguageID uint      `json:"language_id"`
+	Name       string    `json:"name"`
+	Label      string    `json:"label"`
+	Kind       string    `json:"kind"`
+	// The values allowed for an enum field.
+	Options  []string `json:"options"`
+	Required bool     `json:"required"`
+}
+
+// Field names are used as keys in word data and filters, so they are
+// limited to lowercase letters, digits and underscores.
+type FieldDefinitionCreate struct {
+	Name     string   `json:"name" validate:"required,max=64"`
+	Label    string   `json:"label" validate:"required,max=255"`
+	Kind     string   `json:"kind" validate:"required,oneof=text enum number boolean word"`
+	Options  []string `json:"options" validate:"required_if=Kind enum,dive,min=1,max=255"`
+	Required bool     `json:"required"`
+}
+
+// The kind of a field cannot be changed once words may have values for it.
+type FieldDefinitionUpdate struct {
+	Label    *string   `json:"label" validate:"omitempty,min=1,max=255"`
+	Options  *[]string `json:"options" validate:"omitempty,min=1,dive,min=1,max=255"`
+	Required *bool     `json:"required"`
+}
+
+type FieldDefinitionService interface {
+	FindFieldDefinitionsForLanguage(ctx context.Context, language *Language) ([]*FieldDefinition, error)
+	GetFieldDefinitionByName(ctx context.Context, language *Language, name string) (*FieldDefinition, error)
+	CreateFieldDefinition(ctx context.Context, language *Language, create FieldDefinitionCreate) (*FieldDefinition, error)
+	UpdateFieldDefinition(ctx context.Context, field *FieldDefinition, update FieldDefinitionUpdate) error
+	// Deleting a field also removes its values from every word.
+	DeleteFieldDefinition(ctx context.Context, field *FieldDefinition) error
+}
@@ -5,32 +5,77 @@ import (
 	"time"
 )
 
+const (
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPublic   = "public"
+)
+
 type Language struct {
-	ID        uint      `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Name      string    `json:"name" validate:"required"`
-	Slug      string    `json:"slug" validate:"required"`
-	Endonym   string    `json:"endonym"`
-	UserID    uint      `json:"user_id" validate:"required:"`
+	ID         uint      `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Version    int       `json:"version"`
+	Name       string    `json:"name" validate:"required"`
+	Slug       string    `json:"slug" validate:"required"`
+	Endonym    string    `json:"endonym"`
+	Visibility string    `json:"visibility"`
+	UserID     uint      `json:"user_id"`
+	TeamID     uint      `json:"team_id"`
+	// Language this one was forked from, if any. The ID is kept even if the
+	// upstream language is later deleted.
+	ForkedFromID uint       `json:"forked_from_id"`
+	ForkedAt     *time.Time `json:"forked_at"`
+	// Highest upstream word ID when the language was forked. Upstream words
+	// above it were added after the fork.
+	ForkedWordID uint `json:"-"`
+	// When an administrator hid the language from the public, and why.
+	HiddenAt     *time.Time `json:"hidden_at,omitempty"`
+	HiddenReason string     `json:"hidden_reason,omitempty"`
+	// When the language was moved to the trash, if it has been deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (l *Language) Hidden() bool {
+	return l.HiddenAt != nil
 }
 
 type LanguageUpdate struct {
-	Name    string `json:"name"`
-	Endonym string `json:"endonym"`
+	Name       *string `json:"name" validate:"omitempty,min=1"`
+	Endonym    *string `json:"endonym"`
+	Visibility *string `json:"visibility" validate:"omitempty,oneof=private unlisted public"`
 }
 
 type LanguageCreate struct {
-	Name    string `json:"name" validate:"required"`
-	Slug    string `json:"slug" validate:"required"`
-	Endonym string `json:"endonym"`
+	Name       string `json:"name" validate:"required"`
+	Slug       string `json:"slug" validate:"required"`
+	Endonym    string `json:"endonym"`
+	Visibility string `json:"visibility" validate:"omitempty,oneof=private unlisted public"`
+	// Namespace to create the language in, either the creating user's
+	// username (the default) or the slug of a team they belong to.
+	Namespace string `json:"namespace"`
+}
+
+type LanguageFork struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
 }
 
+// Language services refuse to update or delete a language which has been
+// changed since it was loaded, going by its version.
 type LanguageService interface {
 	GetLanguageByID(ctx context.Context, id uint) (*Language, error)
 	GetLanguageByUserAndSlug(ctx context.Context, user *User, slug string) (*Language, error)
+	GetLanguageByNamespaceAndSlug(ctx context.Context, namespace string, slug string) (*Language, error)
 	FindLanguagesForUser(ctx context.Context, user *User) ([]*Language, error)
+	FindLanguagesForTeam(ctx context.Context, team *Team) ([]*Language, error)
+	FindPublicLanguages(ctx context.Context) ([]*Language, error)
 	CreateLanguageForUser(ctx context.Context, user *User, create LanguageCreate) (*Language, error)
+	CreateLanguageForTeam(ctx context.Context, team *Team, create LanguageCreate) (*Language, error)
+	TransferLanguageToUser(ctx context.Context, language *Language, user *User) error
+	TransferLanguageToTeam(ctx context.Context, language *Language, team *Team) error
+	ForkLanguageForUser(ctx context.Context, upstream *Language, user *User, fork LanguageFork) (*Language, error)
 	UpdateLanguage(ctx context.Context, language *Language, update LanguageUpdate) error
+	// Moves a language to the trash, see TrashService.
 	DeleteLanguage(ctx context.Context, language *Language) error
 }
@@ -0,0 +1,129 @@
+// Package mail provides the ways conlangdev can send mail: over SMTP, or
+// by writing each message out to a directory or a stream such as stdout
+// for development and testing.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+// Formats a mail as an RFC 5322 message with a UTF-8 plain text body.
+func format(from string, mail *conlangdev.Mail) []byte {
+	var buffer bytes.Buffer
+	headers := [][2]string{
+		{"From", from},
+		{"To", mail.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", mail.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buffer, "%s: %s\r\n", header[0], header[1])
+	}
+	buffer.WriteString("\r\n")
+	buffer.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return buffer.Bytes()
+}
+
+// Checks that an address cannot be used to add headers to a message.
+func checkAddress(address string) error {
+	if strings.ContainsAny(address, "\r\n") {
+		return fmt.Errorf("invalid mail address %q", address)
+	}
+	return nil
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Sends mail through an SMTP server, authenticating with the given
+// username and password if there is one. The server must support
+// STARTTLS for credentials to be sent, unless it is on localhost.
+func NewSMTPMailer(host string, port string, username string, password string, from string) *SMTPMailer {
+	return &SMTPMailer{host, port, username, password, from}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, mail *conlangdev.Mail) error {
+	if err := checkAddress(mail.To); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(
+		net.JoinHostPort(m.Host, m.Port), auth, m.From,
+		[]string{mail.To}, format(m.From, mail),
+	)
+}
+
+type WriterMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// Writes each mail to the given writer instead of sending it, one after
+// the other, which is useful in development with os.Stdout.
+func NewWriterMailer(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{w: w, from: from}
+}
+
+func (m *WriterMailer) Send(ctx context.Context, mail *conlangdev.Mail) error {
+	if err := checkAddress(mail.To); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := fmt.Fprintf(m.w, "%s\r\n\r\n", format(m.from, mail))
+	return err
+}
+
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Writes each mail to its own .eml file in the given directory instead of
+// sending it, so that tests can read what would have been sent.
+func NewFileMailer(dir string, from string) *FileMailer {
+	return &FileMailer{dir, from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, mail *conlangdev.Mail) error {
+	if err := checkAddress(mail.To); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	// Names sort in the order mails were sent
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, mail), 0644)
+}
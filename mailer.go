@@ -0,0 +1,16 @@
+package conlangdev
+
+import "context"
+
+// A plain text email to a single recipient.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sends mail to users, such as password reset links. See the mail package
+// for the implementations.
+type Mailer interface {
+	Send(ctx context.Context, mail *Mail) error
+}
@@ -0,0 +1,111 @@
+package conlangdev
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// How long a user has to finish signing in with an identity provider once
+// they have been sent to it.
+const OIDCLoginLifetime = 10 * time.Minute
+
+// A sign in with an identity provider which has been started but not yet
+// finished. The state is sent through the provider to tie its answer back
+// to the login, while the verifier is kept here for PKCE.
+//
+// The client token is only given to the client which started the login, and
+// must be sent back with the state, so that a login somebody else started
+// cannot be finished by handing its callback to another user.
+type OIDCLogin struct {
+	Provider    string
+	State       string
+	ClientToken string
+	Nonce       string
+	Verifier    string
+	RedirectURI string
+}
+
+// What an identity provider says about the user who signed in with it.
+type OIDCClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// Whether an identity may be linked to the existing user with the same email
+// address. Both sides must have verified the address, otherwise anyone could
+// take over an account by claiming its address with a provider.
+func (c *OIDCClaims) CanLinkTo(user *User) bool {
+	return c.EmailVerified && user.EmailVerified()
+}
+
+// Gives the user an identity which has not signed in before belongs to,
+// given the user who already has its email address, or nil if nobody does.
+// It is only linked to an existing user as CanLinkTo allows. Otherwise a new
+// user is given to be created, which still needs a username and has no
+// password until they set one by resetting it.
+func UserForOIDCClaims(claims *OIDCClaims, existing *User, now time.Time) (user *User, create bool, err error) {
+	if claims.Email == "" {
+		return nil, false, &Error{
+			Code:       EBADREQUEST,
+			Message:    "the identity provider did not give an email address",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if existing != nil {
+		if !claims.CanLinkTo(existing) {
+			return nil, false, &Error{
+				Code:       ECONFLICT,
+				Message:    "an account already uses that email address, log in with its password instead",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return existing, false, nil
+	}
+
+	user = &User{
+		Email:       claims.Email,
+		DisplayName: claims.Name,
+	}
+	if claims.EmailVerified {
+		user.EmailVerifiedAt = &now
+	}
+	return user, true, nil
+}
+
+// An account with an identity provider which has been linked to a user.
+type OIDCIdentity struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+}
+
+// An OpenID Connect identity provider users can sign in with.
+type OIDCProvider interface {
+	// The name the provider is known by in URLs.
+	Name() string
+	DisplayName() string
+	// Gives the URL to send the user to in order to sign in.
+	AuthCodeURL(ctx context.Context, login *OIDCLogin) (string, error)
+	// Swaps the code the provider sent back for the claims of the user,
+	// checking them against the login.
+	Exchange(ctx context.Context, login *OIDCLogin, code string) (*OIDCClaims, error)
+}
+
+type OIDCService interface {
+	CreateOIDCLogin(ctx context.Context, provider string, redirectURI string) (*OIDCLogin, error)
+	// Gives a login by its state and client token, which can only be done
+	// once.
+	ConsumeOIDCLogin(ctx context.Context, provider string, state string, clientToken string) (*OIDCLogin, error)
+	// Gives the user linked to an identity. Identities which are not yet
+	// linked are linked to the user with the same verified email address,
+	// or a new user is created for them.
+	GetUserForOIDCClaims(ctx context.Context, provider string, claims *OIDCClaims) (*User, error)
+	FindOIDCIdentitiesForUser(ctx context.Context, user *User) ([]*OIDCIdentity, error)
+}
@@ -0,0 +1,343 @@
+// Package oidc lets users sign in with OpenID Connect identity providers,
+// using the authorization code flow with PKCE. ID tokens are only accepted
+// when signed with RS256 by a key the provider publishes.
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/golang-jwt/jwt"
+)
+
+// How far the clocks of a provider and conlangdev may drift apart.
+const clockSkew = time.Minute
+
+type Config struct {
+	// The name the provider is known by in URLs, such as "google".
+	Name        string
+	DisplayName string
+	// The issuer URL, which the provider configuration is discovered from.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes to ask for besides "openid", which defaults to "email" and
+	// "profile".
+	Scopes []string
+}
+
+// The parts of the provider configuration document which are used.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]*rsa.PublicKey
+}
+
+// Creates a provider from its configuration. The provider is only contacted
+// once it is first used, so that it being unavailable does not stop
+// conlangdev from starting.
+func NewProvider(config Config) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"email", "profile"}
+	}
+	if config.DisplayName == "" {
+		config.DisplayName = config.Name
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	return &Provider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+func (p *Provider) DisplayName() string {
+	return p.config.DisplayName
+}
+
+// Gives the S256 PKCE challenge for a verifier.
+func Challenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// Fetches a JSON document from the provider.
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s responded with %s", url, response.Status)
+	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+// Gives the configuration of the provider, fetching it the first time.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	if err := p.getJSON(ctx, p.config.Issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: provider gave issuer %q, expected %q", d.Issuer, p.config.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("oidc: provider configuration is missing endpoints")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// Gives the signing key with the given ID. Keys are fetched again when an
+// unknown key is asked for, as providers rotate them.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, d.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = keys
+
+	key, ok := p.keys[kid]
+	if !ok && kid == "" && len(p.keys) == 1 {
+		// Providers with a single key may leave out its ID
+		for _, only := range p.keys {
+			key, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("oidc: provider has no signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, login *conlangdev.OIDCLogin) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {login.RedirectURI},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.config.Scopes...), " ")},
+		"state":                 {login.State},
+		"nonce":                 {login.Nonce},
+		"code_challenge":        {Challenge(login.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// The audience of a token, which may be given as a string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type idTokenClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+func (c *idTokenClaims) Valid() error {
+	now := time.Now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) {
+		return errors.New("oidc: ID token has expired")
+	}
+	if c.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(c.IssuedAt, 0)) {
+		return errors.New("oidc: ID token was issued in the future")
+	}
+	return nil
+}
+
+// Checks an ID token, giving its claims if it was issued by the provider
+// for this client and login.
+func (p *Provider) verify(ctx context.Context, login *conlangdev.OIDCLogin, idToken string) (*idTokenClaims, error) {
+	var claims idTokenClaims
+	if _, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, errors.New("oidc: unexpected ID token signing method")
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	}); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(claims.Issuer, "/") != p.config.Issuer {
+		return nil, errors.New("oidc: ID token was issued by another provider")
+	}
+	if !claims.Audience.contains(p.config.ClientID) {
+		return nil, errors.New("oidc: ID token was issued for another client")
+	}
+	if claims.Nonce != login.Nonce {
+		return nil, errors.New("oidc: ID token nonce does not match")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("oidc: ID token has no subject")
+	}
+	return &claims, nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, login *conlangdev.OIDCLogin, code string) (*conlangdev.OIDCClaims, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {login.RedirectURI},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {login.Verifier},
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("oidc: could not read token response: %w", err)
+	}
+	if tokens.Error != "" {
+		return nil, fmt.Errorf("oidc: token request failed: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+	if response.StatusCode != http.StatusOK || tokens.IDToken == "" {
+		return nil, fmt.Errorf("oidc: token request failed with %s", response.Status)
+	}
+
+	claims, err := p.verify(ctx, login, tokens.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	return &conlangdev.OIDCClaims{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
@@ -0,0 +1,13 @@
+package oidc
+
+import "testing"
+
+// Challenges must match RFC 7636 for real providers to accept the verifier,
+// not just the test provider.
+func TestChallenge(t *testing.T) {
+	// The example from appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	if challenge := Challenge(verifier); challenge != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("got challenge %q", challenge)
+	}
+}
@@ -0,0 +1,56 @@
+package conlangdev
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserForOIDCClaims(t *testing.T) {
+	now := time.Now()
+	verified := &User{ID: 1, Email: "alice@example.com", EmailVerifiedAt: &now}
+	unverified := &User{ID: 2, Email: "bob@example.com"}
+
+	tests := []struct {
+		name     string
+		claims   OIDCClaims
+		existing *User
+		// The ID of the user linked to, or zero if one is created
+		linked uint
+		create bool
+		code   string
+	}{
+		{"links verified email", OIDCClaims{Email: "alice@example.com", EmailVerified: true}, verified, 1, false, ""},
+		{"refuses unverified email", OIDCClaims{Email: "alice@example.com"}, verified, 0, false, ECONFLICT},
+		{"refuses unverified account", OIDCClaims{Email: "bob@example.com", EmailVerified: true}, unverified, 0, false, ECONFLICT},
+		{"refuses unverified on both sides", OIDCClaims{Email: "bob@example.com"}, unverified, 0, false, ECONFLICT},
+		{"creates user for verified email", OIDCClaims{Email: "new@example.com", EmailVerified: true}, nil, 0, true, ""},
+		{"creates user for unverified email", OIDCClaims{Email: "new@example.com"}, nil, 0, true, ""},
+		{"refuses missing email", OIDCClaims{Subject: "nobody"}, nil, 0, false, EBADREQUEST},
+	}
+	for _, test := range tests {
+		user, create, err := UserForOIDCClaims(&test.claims, test.existing, now)
+		if test.code != "" {
+			if e, ok := err.(*Error); !ok || e.Code != test.code {
+				t.Errorf("%s: got error %v, want %s", test.name, err, test.code)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: got error %v", test.name, err)
+			continue
+		}
+		if create != test.create {
+			t.Errorf("%s: got create %t, want %t", test.name, create, test.create)
+		}
+		if !create && user.ID != test.linked {
+			t.Errorf("%s: linked to user %d, want %d", test.name, user.ID, test.linked)
+		}
+		if create {
+			if user.PasswordHash != "" {
+				t.Errorf("%s: new user has a password", test.name)
+			}
+			if user.Email != test.claims.Email || user.EmailVerified() != test.claims.EmailVerified {
+				t.Errorf("%s: new user has email %q verified %t", test.name, user.Email, user.EmailVerified())
+			}
+		}
+	}
+}
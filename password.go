@@ -0,0 +1,39 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+// How long a password reset token can be used for once it has been sent.
+const PasswordResetLifetime = time.Hour
+
+// Password resets let users who have forgotten their password set a new
+// one using a token sent to their email address. Tokens can only be used
+// once, and asking for a new one cancels any sent before it.
+type PasswordResetService interface {
+	// Creates a reset token for the user with the given email address,
+	// giving the user and the token. Both are nil if there is no such
+	// user, which callers should not reveal.
+	CreatePasswordReset(ctx context.Context, email string) (*User, string, error)
+	// Sets a new password using a reset token, which also ends every
+	// session of the user.
+	ResetPassword(ctx context.Context, token string, password string) (*User, error)
+}
+
+// How long an email verification link can be used for once it has been
+// sent.
+const EmailVerificationLifetime = 48 * time.Hour
+
+// Email verification confirms that users can receive mail at their
+// address, both when they sign up and when they change it. A changed
+// address only replaces the old one once it has been verified. As with
+// password resets, only the latest token sent to a user can be used.
+type EmailVerificationService interface {
+	// Creates a token verifying the given address for a user, which is
+	// either their current address or one they want to change to.
+	CreateEmailVerification(ctx context.Context, user *User, email string) (string, error)
+	// Marks the address a token was sent to as verified, making it the
+	// address of its user if it was a new one.
+	VerifyEmail(ctx context.Context, token string) (*User, error)
+}
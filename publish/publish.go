@@ -0,0 +1,442 @@
+// Package publish renders a language into a fully static website which can
+// be hosted without running the API.
+//
+// Sites are rebuilt incrementally: a manifest recording when each word was
+// last updated is kept alongside the output, and only word pages whose
+// `updated_at` has changed since the last build are rewritten.
+package publish
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+// Bump this whenever the templates change so that existing sites are
+// rebuilt in full.
+const generatorVersion = 2
+
+const manifestName = ".conlangdev-publish.json"
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+//go:embed static/*
+var staticFiles embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"uid": func(uid uint64) string { return strconv.FormatUint(uid, 10) },
+}).ParseFS(templateFiles, "templates/*.html"))
+
+type manifest struct {
+	Version           int                  `json:"version"`
+	LanguageUpdatedAt time.Time            `json:"language_updated_at"`
+	FieldsUpdatedAt   time.Time            `json:"fields_updated_at"`
+	Words             map[string]time.Time `json:"words"`
+}
+
+// Summary of the work done by a build.
+type Result struct {
+	WordsWritten int
+	WordsSkipped int
+	WordsRemoved int
+}
+
+type Site struct {
+	Dir string
+	// Name of the user or team the language is credited to.
+	Author   string
+	Language *conlangdev.Language
+	Words    []*conlangdev.Word
+	// Custom fields defined by the language, shown on word pages in the
+	// order given.
+	Fields []*conlangdev.FieldDefinition
+}
+
+// Data passed to every page template. Root is the relative path back to
+// the site root, so that sites can be hosted under any path.
+type page struct {
+	Root     string
+	Title    string
+	Author   string
+	Language *conlangdev.Language
+	Letters  []*letter
+	Grammar  []*category
+	Letter   *letter
+	Category *category
+	Word     *conlangdev.Word
+	Fields   []*fieldValue
+	Count    int
+}
+
+// A custom field value as shown on a word page. Word references link to
+// the page of the word referred to.
+type fieldValue struct {
+	Label string
+	Value string
+	Path  string
+}
+
+type letter struct {
+	Letter string
+	Path   string
+	Words  []*conlangdev.Word
+}
+
+type category struct {
+	Name  string
+	Path  string
+	Words []*conlangdev.Word
+}
+
+type searchEntry struct {
+	UID          string   `json:"uid"`
+	Headword     string   `json:"headword"`
+	PartOfSpeech string   `json:"part_of_speech"`
+	Definition   string   `json:"definition"`
+	Tags         []string `json:"tags,omitempty"`
+	URL          string   `json:"url"`
+}
+
+// Writes the site to its output directory, skipping word pages which are
+// already up to date.
+func (s *Site) Build(ctx context.Context) (*Result, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	previous, err := s.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	// Changes to the custom fields show on every word page. Headwords of
+	// referenced words are only brought up to date as pages are rewritten.
+	var fieldsUpdatedAt time.Time
+	for _, field := range s.Fields {
+		if field.UpdatedAt.After(fieldsUpdatedAt) {
+			fieldsUpdatedAt = field.UpdatedAt
+		}
+	}
+	full := previous.Version != generatorVersion ||
+		!previous.LanguageUpdatedAt.Equal(s.Language.UpdatedAt) ||
+		!previous.FieldsUpdatedAt.Equal(fieldsUpdatedAt)
+
+	current := &manifest{
+		Version:           generatorVersion,
+		LanguageUpdatedAt: s.Language.UpdatedAt,
+		FieldsUpdatedAt:   fieldsUpdatedAt,
+		Words:             make(map[string]time.Time),
+	}
+	result := &Result{}
+
+	sort.SliceStable(s.Words, func(i, j int) bool {
+		return strings.ToLower(s.Words[i].Headword) < strings.ToLower(s.Words[j].Headword)
+	})
+
+	// Word pages
+	byUID := make(map[uint64]*conlangdev.Word, len(s.Words))
+	for _, word := range s.Words {
+		byUID[word.UID] = word
+	}
+	for _, word := range s.Words {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		uid := strconv.FormatUint(word.UID, 10)
+		current.Words[uid] = word.UpdatedAt
+		if updated, ok := previous.Words[uid]; ok && !full && updated.Equal(word.UpdatedAt) {
+			result.WordsSkipped++
+			continue
+		}
+		if err := s.render(path.Join("word", uid), "word.html", &page{
+			Title:  word.Headword,
+			Word:   word,
+			Fields: s.fieldValues(word, byUID),
+		}); err != nil {
+			return nil, err
+		}
+		result.WordsWritten++
+	}
+
+	// Remove pages for words which no longer exist
+	for uid := range previous.Words {
+		if _, ok := current.Words[uid]; !ok {
+			if err := os.RemoveAll(filepath.Join(s.Dir, "word", uid)); err != nil {
+				return nil, err
+			}
+			result.WordsRemoved++
+		}
+	}
+
+	// Listing pages are cheap to build, so they are always rewritten
+	letters := groupByLetter(s.Words)
+	grammar := groupByPartOfSpeech(s.Words)
+	if err := s.clean("browse"); err != nil {
+		return nil, err
+	}
+	if err := s.clean("grammar"); err != nil {
+		return nil, err
+	}
+	if err := s.render("", "index.html", &page{
+		Title:   s.Language.Name,
+		Letters: letters,
+		Grammar: grammar,
+		Count:   len(s.Words),
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.render("browse", "browse.html", &page{
+		Title:   "Browse",
+		Letters: letters,
+	}); err != nil {
+		return nil, err
+	}
+	for _, l := range letters {
+		if err := s.render(l.Path, "letter.html", &page{
+			Title:   l.Letter,
+			Letters: letters,
+			Letter:  l,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.render("grammar", "grammar.html", &page{
+		Title:   "Grammar",
+		Grammar: grammar,
+	}); err != nil {
+		return nil, err
+	}
+	for _, c := range grammar {
+		if err := s.render(c.Path, "category.html", &page{
+			Title:    c.Name,
+			Category: c,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.writeSearchIndex(); err != nil {
+		return nil, err
+	}
+	if err := s.writeStatic(); err != nil {
+		return nil, err
+	}
+	if err := s.writeManifest(current); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Renders a template to `index.html` inside the given directory.
+func (s *Site) render(dir string, name string, data *page) error {
+	data.Author = s.Author
+	data.Language = s.Language
+	data.Root = "./"
+	if dir != "" {
+		data.Root = strings.Repeat("../", strings.Count(dir, "/")+1)
+	}
+
+	target := filepath.Join(s.Dir, filepath.FromSlash(dir))
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(filepath.Join(target, "index.html"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := templates.ExecuteTemplate(file, name, data); err != nil {
+		return fmt.Errorf("could not render %s: %w", name, err)
+	}
+	return file.Close()
+}
+
+// Lists the custom fields a word has a value for, formatted for its page.
+func (s *Site) fieldValues(word *conlangdev.Word, byUID map[uint64]*conlangdev.Word) []*fieldValue {
+	values := make([]*fieldValue, 0)
+	for _, field := range s.Fields {
+		raw, ok := word.CustomFields[field.Name]
+		if !ok {
+			continue
+		}
+		value := &fieldValue{Label: field.Label}
+		switch field.Kind {
+		case conlangdev.FieldKindWord:
+			var uid uint64
+			if err := json.Unmarshal(raw, &uid); err != nil {
+				continue
+			}
+			// Words which are not being published are left out
+			referenced, ok := byUID[uid]
+			if !ok {
+				continue
+			}
+			value.Value = referenced.Headword
+			value.Path = path.Join("word", strconv.FormatUint(uid, 10))
+		case conlangdev.FieldKindBoolean:
+			var set bool
+			if err := json.Unmarshal(raw, &set); err != nil {
+				continue
+			}
+			value.Value = "no"
+			if set {
+				value.Value = "yes"
+			}
+		case conlangdev.FieldKindNumber:
+			value.Value = string(raw)
+		default:
+			if err := json.Unmarshal(raw, &value.Value); err != nil {
+				continue
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func (s *Site) clean(dir string) error {
+	return os.RemoveAll(filepath.Join(s.Dir, dir))
+}
+
+func (s *Site) writeSearchIndex() error {
+	entries := make([]searchEntry, len(s.Words))
+	for i, word := range s.Words {
+		uid := strconv.FormatUint(word.UID, 10)
+		entries[i] = searchEntry{
+			UID:          uid,
+			Headword:     word.Headword,
+			PartOfSpeech: word.PartOfSpeech,
+			Definition:   word.Definition,
+			Tags:         word.Tags,
+			URL:          "word/" + uid + "/",
+		}
+	}
+	buffer, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.Dir, "search.json"), buffer, 0644)
+}
+
+func (s *Site) writeStatic() error {
+	return fs.WalkDir(staticFiles, "static", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		buffer, err := staticFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(s.Dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, buffer, 0644)
+	})
+}
+
+func (s *Site) readManifest() (*manifest, error) {
+	m := &manifest{Words: make(map[string]time.Time)}
+	buffer, err := os.ReadFile(filepath.Join(s.Dir, manifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buffer, m); err != nil {
+		return nil, fmt.Errorf("could not read publish manifest: %w", err)
+	}
+	if m.Words == nil {
+		m.Words = make(map[string]time.Time)
+	}
+	return m, nil
+}
+
+func (s *Site) writeManifest(m *manifest) error {
+	buffer, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.Dir, manifestName), buffer, 0644)
+}
+
+// Groups words by the first letter of their headword. Headwords which do
+// not begin with a letter are grouped under "#".
+func groupByLetter(words []*conlangdev.Word) []*letter {
+	byLetter := make(map[string]*letter)
+	var letters []*letter
+	for _, word := range words {
+		first, _ := utf8.DecodeRuneInString(strings.TrimSpace(word.Headword))
+		key, dir := "#", "other"
+		if unicode.IsLetter(first) {
+			key = string(unicode.ToUpper(first))
+			dir = string(unicode.ToLower(first))
+		}
+		l, ok := byLetter[key]
+		if !ok {
+			l = &letter{Letter: key, Path: path.Join("browse", dir)}
+			byLetter[key] = l
+			letters = append(letters, l)
+		}
+		l.Words = append(l.Words, word)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].Letter < letters[j].Letter })
+	return letters
+}
+
+// Groups words by their part of speech for the grammar pages. Parts of
+// speech which share a path, such as "n" and "N.", are one category named
+// after the first spelling seen.
+func groupByPartOfSpeech(words []*conlangdev.Word) []*category {
+	bySlug := make(map[string]*category)
+	var categories []*category
+	for _, word := range words {
+		name := strings.TrimSpace(word.PartOfSpeech)
+		slug := slugify(name)
+		c, ok := bySlug[slug]
+		if !ok {
+			c = &category{Name: name, Path: path.Join("grammar", slug)}
+			bySlug[slug] = c
+			categories = append(categories, c)
+		}
+		c.Words = append(c.Words, word)
+	}
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories
+}
+
+// Turns a name into a lowercase path segment.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	if slug := strings.TrimSuffix(b.String(), "-"); slug != "" {
+		return slug
+	}
+	return "other"
+}
@@ -0,0 +1,34 @@
+package publish
+
+import (
+	"testing"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+func TestGroupByPartOfSpeech(t *testing.T) {
+	var words []*conlangdev.Word
+	for _, partOfSpeech := range []string{"n", "N.", " n. ", "verb", "", "?"} {
+		words = append(words, &conlangdev.Word{PartOfSpeech: partOfSpeech})
+	}
+
+	categories := groupByPartOfSpeech(words)
+	want := []struct {
+		name  string
+		path  string
+		words int
+	}{
+		{"", "grammar/other", 2},
+		{"n", "grammar/n", 3},
+		{"verb", "grammar/verb", 1},
+	}
+	if len(categories) != len(want) {
+		t.Fatalf("got %d categories, want %d", len(categories), len(want))
+	}
+	for i, c := range categories {
+		if c.Name != want[i].name || c.Path != want[i].path || len(c.Words) != want[i].words {
+			t.Errorf("category %d: got %q at %s with %d words, want %q at %s with %d words",
+				i, c.Name, c.Path, len(c.Words), want[i].name, want[i].path, want[i].words)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+(function () {
+  var root = document.body.getAttribute("data-root");
+  var input = document.getElementById("search");
+  var results = document.getElementById("results");
+  var index = null;
+
+  function load() {
+    if (index !== null) return Promise.resolve(index);
+    return fetch(root + "search.json")
+      .then(function (response) { return response.json(); })
+      .then(function (entries) { index = entries; return index; });
+  }
+
+  function search(query) {
+    query = query.trim().toLowerCase();
+    results.innerHTML = "";
+    if (query === "") return;
+    load().then(function (entries) {
+      entries
+        .filter(function (entry) {
+          return entry.headword.toLowerCase().indexOf(query) !== -1 ||
+            entry.definition.toLowerCase().indexOf(query) !== -1;
+        })
+        .slice(0, 20)
+        .forEach(function (entry) {
+          var item = document.createElement("li");
+          var link = document.createElement("a");
+          link.href = root + entry.url;
+          link.textContent = entry.headword;
+          item.appendChild(link);
+          item.appendChild(document.createTextNode(" " + entry.definition));
+          results.appendChild(item);
+        });
+    });
+  }
+
+  input.addEventListener("input", function () { search(input.value); });
+})();
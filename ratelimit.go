@@ -0,0 +1,45 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+// A number of requests allowed within a window of time. Limits with no
+// requests or no window are turned off.
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Window > 0
+}
+
+// How logins to an account are locked out after failing. Once that many
+// attempts have failed within the window, the account is locked for the
+// delay, which doubles with each further failure up to the maximum delay.
+// Without a maximum delay, the delay never grows.
+type LockoutPolicy struct {
+	Attempts int
+	Window   time.Duration
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+func (p LockoutPolicy) Enabled() bool {
+	return p.Attempts > 0 && p.Delay > 0
+}
+
+// Keeps counters for rate limiting, each of which expires a while after it
+// is first counted. See the ratelimit package for the implementations.
+type RateLimitStore interface {
+	// Adds one to the counter for the key, starting it if there is none
+	// so that it expires after the window, and gives the new count along
+	// with when the counter expires.
+	Increment(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+	// Gives the counter for the key and when it expires, or zero if there
+	// is none.
+	Get(ctx context.Context, key string) (int, time.Time, error)
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,108 @@
+// Package ratelimit provides the in-memory store used for rate limiting
+// when no other store is given, along with parsing for limits.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+// How often expired counters are swept out of a MemoryStore.
+const sweepInterval = time.Minute
+
+type counter struct {
+	count     int
+	expiresAt time.Time
+}
+
+// Keeps counters in memory. Counters are not shared between processes, so
+// limits apply to each instance of the server separately.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+	sweptAt  time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters: make(map[string]*counter),
+		sweptAt:  time.Now(),
+	}
+}
+
+// Removes expired counters, at most once every sweep interval. The lock
+// must be held.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.sweptAt) < sweepInterval {
+		return
+	}
+	for key, counter := range s.counters {
+		if !now.Before(counter.expiresAt) {
+			delete(s.counters, key)
+		}
+	}
+	s.sweptAt = now
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expiresAt) {
+		c = &counter{expiresAt: now.Add(window)}
+		s.counters[key] = c
+	}
+	c.count++
+	return c.count, c.expiresAt, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !time.Now().Before(c.expiresAt) {
+		return 0, time.Time{}, nil
+	}
+	return c.count, c.expiresAt, nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+	return nil
+}
+
+// Parses a limit written as requests per window, such as `10/1m`. An empty
+// string, `0` or `off` turn the limit off.
+func ParseLimit(value string) (conlangdev.RateLimit, error) {
+	switch value {
+	case "", "0", "off":
+		return conlangdev.RateLimit{}, nil
+	}
+
+	requests, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return conlangdev.RateLimit{}, fmt.Errorf("rate limit %q should be written as requests/window, e.g 10/1m", value)
+	}
+	limit := conlangdev.RateLimit{}
+	var err error
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests < 0 {
+		return conlangdev.RateLimit{}, fmt.Errorf("rate limit %q has an invalid number of requests", value)
+	}
+	if limit.Window, err = time.ParseDuration(window); err != nil || limit.Window < 0 {
+		return conlangdev.RateLimit{}, fmt.Errorf("rate limit %q has an invalid window", value)
+	}
+	return limit, nil
+}
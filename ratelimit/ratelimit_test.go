@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+func TestMemoryStoreWindow(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	window := 50 * time.Millisecond
+
+	tests := []struct {
+		name  string
+		wait  time.Duration
+		count int
+	}{
+		{"first request starts a counter", 0, 1},
+		{"requests within the window add up", 0, 2},
+		{"still within the window", 10 * time.Millisecond, 3},
+		{"counter starts again once the window is up", window, 1},
+		{"new window counts on from there", 0, 2},
+	}
+	for _, test := range tests {
+		time.Sleep(test.wait)
+		count, expiresAt, err := store.Increment(ctx, "key", window)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if count != test.count {
+			t.Errorf("%s: got count %d, want %d", test.name, count, test.count)
+		}
+		if until := time.Until(expiresAt); until <= 0 || until > window {
+			t.Errorf("%s: counter expires in %s, want within %s", test.name, until, window)
+		}
+	}
+}
+
+func TestMemoryStoreGetAndReset(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	if count, _, _ := store.Get(ctx, "key"); count != 0 {
+		t.Errorf("missing counter: got %d, want 0", count)
+	}
+	store.Increment(ctx, "key", time.Minute)
+	store.Increment(ctx, "key", time.Minute)
+	if count, _, _ := store.Get(ctx, "key"); count != 2 {
+		t.Errorf("after two increments: got %d, want 2", count)
+	}
+	if count, _, _ := store.Get(ctx, "other"); count != 0 {
+		t.Errorf("other keys: got %d, want 0", count)
+	}
+	store.Reset(ctx, "key")
+	if count, _, _ := store.Get(ctx, "key"); count != 0 {
+		t.Errorf("after reset: got %d, want 0", count)
+	}
+
+	store.Increment(ctx, "short", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if count, _, _ := store.Get(ctx, "short"); count != 0 {
+		t.Errorf("expired counter: got %d, want 0", count)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		limit conlangdev.RateLimit
+		err   bool
+	}{
+		{"10/1m", conlangdev.RateLimit{Requests: 10, Window: time.Minute}, false},
+		{"600/30s", conlangdev.RateLimit{Requests: 600, Window: 30 * time.Second}, false},
+		{"", conlangdev.RateLimit{}, false},
+		{"0", conlangdev.RateLimit{}, false},
+		{"off", conlangdev.RateLimit{}, false},
+		{"10", conlangdev.RateLimit{}, true},
+		{"ten/1m", conlangdev.RateLimit{}, true},
+		{"-1/1m", conlangdev.RateLimit{}, true},
+		{"10/soon", conlangdev.RateLimit{}, true},
+	}
+	for _, test := range tests {
+		limit, err := ParseLimit(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("%q: got error %v, want error %t", test.value, err, test.err)
+		}
+		if limit != test.limit {
+			t.Errorf("%q: got %+v, want %+v", test.value, limit, test.limit)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package conlangdev
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+// An immutable snapshot of a word, taken whenever the word is created,
+// changed or deleted. Revisions are kept by the word's UID, so they outlive
+// the word itself.
+type WordRevision struct {
+	ID             uint      `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	LanguageID     uint      `json:"language_id"`
+	WordUID        uint64    `json:"word_uid"`
+	Revision       int       `json:"revision"`
+	Action         string    `json:"action"`
+	AuthorID       uint      `json:"author_id"`
+	AuthorUsername string    `json:"author_username"`
+	Headword       string    `json:"headword"`
+	PartOfSpeech   string    `json:"part_of_speech"`
+	Definition     string    `json:"definition"`
+	Pronunciation  string    `json:"pronunciation"`
+	GrammarClass   string    `json:"grammar_class"`
+	Gender         string    `json:"gender"`
+	Etymology      string    `json:"etymology"`
+	Notes          string    `json:"notes"`
+	Tags           []string  `json:"tags"`
+	// Custom field values as they were at the time of the revision.
+	CustomFields map[string]json.RawMessage `json:"custom_fields"`
+	Concepts     []string                   `json:"concepts"`
+}
+
+// A single field which differs between two revisions.
+type FieldDiff struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type revisionField struct {
+	name     string
+	from, to string
+}
+
+// Lists the fields which differ between two revisions of a word.
+func DiffWordRevisions(from *WordRevision, to *WordRevision) []*FieldDiff {
+	fields := []revisionField{
+		{"headword", from.Headword, to.Headword},
+		{"part_of_speech", from.PartOfSpeech, to.PartOfSpeech},
+		{"definition", from.Definition, to.Definition},
+		{"pronunciation", from.Pronunciation, to.Pronunciation},
+		{"grammar_class", from.GrammarClass, to.GrammarClass},
+		{"gender", from.Gender, to.Gender},
+		{"etymology", from.Etymology, to.Etymology},
+		{"notes", from.Notes, to.Notes},
+		{"tags", strings.Join(from.Tags, ", "), strings.Join(to.Tags, ", ")},
+		{"concepts", strings.Join(from.Concepts, ", "), strings.Join(to.Concepts, ", ")},
+	}
+	// Custom fields are compared one by one, in order of their names
+	names := make([]string, 0, len(from.CustomFields)+len(to.CustomFields))
+	for name := range from.CustomFields {
+		names = append(names, name)
+	}
+	for name := range to.CustomFields {
+		if _, ok := from.CustomFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields = append(fields, revisionField{
+			"custom_fields." + name,
+			string(from.CustomFields[name]), string(to.CustomFields[name]),
+		})
+	}
+
+	diffs := make([]*FieldDiff, 0)
+	for _, field := range fields {
+		if field.from != field.to {
+			diffs = append(diffs, &FieldDiff{field.name, field.from, field.to})
+		}
+	}
+	return diffs
+}
+
+// Revision services record the user in the context as the author of any
+// restored revision.
+type WordRevisionService interface {
+	FindRevisionsForWord(ctx context.Context, language *Language, uid uint64) ([]*WordRevision, error)
+	GetRevisionForWord(ctx context.Context, language *Language, uid uint64, revision int) (*WordRevision, error)
+	// Restores a word to the state of the given revision, recreating it
+	// with its original UID if it has since been deleted. The word is the
+	// one the caller checked the revision against, which must not have
+	// changed since, or nil if it is not in the language any more.
+	RestoreRevision(ctx context.Context, language *Language, word *Word, revision *WordRevision) (*Word, error)
+}
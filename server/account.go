@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/conlangdev/conlangdev/dataexport"
+	"github.com/gorilla/mux"
+)
+
+// Makes sure the user in the route is the authenticated user, as nobody
+// else may see their export or deletion.
+func requireSelf(r *http.Request, user *conlangdev.User) error {
+	if mux.Vars(r)["username"] != user.Username {
+		return &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "you can only do that for your own account",
+			StatusCode: http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
+func writeUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	response, err := json.Marshal(map[string]*conlangdev.User{
+		"user": user,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+func writeDeletion(w http.ResponseWriter, r *http.Request, deletion *conlangdev.AccountDeletion) {
+	response, err := json.Marshal(map[string]*conlangdev.AccountDeletion{
+		"deletion": deletion,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	var update conlangdev.UserUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.UserService.UpdateUser(r.Context(), user, update); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeUser(w, r, user)
+}
+
+// Exports the account of the user and every language they own as a zip
+// archive.
+func (s *Server) handleExportUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	if err := requireSelf(r, user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	archive := &dataexport.Archive{
+		CreatedAt: time.Now(),
+		User:      user,
+	}
+	var err error
+	if archive.Sessions, err = s.SessionService.FindSessionsForUser(r.Context(), user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if archive.APITokens, err = s.APITokenService.FindAPITokensForUser(r.Context(), user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if archive.Identities, err = s.OIDCService.FindOIDCIdentitiesForUser(r.Context(), user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	languages, err := s.LanguageService.FindLanguagesForUser(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	for _, language := range languages {
+		fields, err := s.FieldDefinitionService.FindFieldDefinitionsForLanguage(r.Context(), language)
+		if err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+		words, err := s.WordService.ListWordsForLanguage(r.Context(), language, conlangdev.WordFilter{})
+		if err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+		archive.Languages = append(archive.Languages, &dataexport.Language{
+			Language: language,
+			Fields:   fields,
+			Words:    words,
+		})
+	}
+
+	// Build the archive in memory first so that failures can still be
+	// reported as a JSON error response.
+	var buffer bytes.Buffer
+	if err := archive.Write(&buffer); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "conlangdev-" + user.Username + ".zip",
+	}))
+	w.Header().Set("Content-Length", strconv.Itoa(buffer.Len()))
+	w.Write(buffer.Bytes())
+}
+
+// Describes what deleting the account would remove, or what is going to be
+// removed if it is already being deleted.
+func (s *Server) handleViewUserDeletion(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	if err := requireSelf(r, user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	deletion, err := s.UserService.GetDeletionForUser(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeDeletion(w, r, deletion)
+}
+
+// Checks the user is who they say they are before a change to their account
+// which a stolen access token alone should not be enough for. This needs
+// their password and, if they have two-factor authentication, a code. Users
+// without a password, such as those who signed up with an identity
+// provider, need two-factor authentication or to set a password first.
+func (s *Server) reauthenticate(r *http.Request, user *conlangdev.User, password string, code string) error {
+	if user.PasswordHash != "" {
+		if err := s.UserService.CheckUserPassword(r.Context(), user, password); err != nil {
+			return &conlangdev.Error{
+				Code:       conlangdev.EUNAUTHORIZED,
+				Message:    "incorrect password",
+				StatusCode: http.StatusUnauthorized,
+			}
+		}
+	} else if !user.TwoFactorEnabled() {
+		return &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "set a password by resetting it before doing this",
+			StatusCode: http.StatusForbidden,
+		}
+	}
+	if user.TwoFactorEnabled() {
+		return s.TwoFactorService.CheckTwoFactorCode(r.Context(), user, code)
+	}
+	return nil
+}
+
+// Deletes the account of the user once the grace period is up, once they
+// have reauthenticated.
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	var deletePayload struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&deletePayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.reauthenticate(r, user, deletePayload.Password, deletePayload.Code); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.UserService.DeleteUser(r.Context(), user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	deletion, err := s.UserService.GetDeletionForUser(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeDeletion(w, r, deletion)
+}
+
+// Cancels the deletion of the account. Users can still log in during the
+// grace period in order to do this.
+func (s *Server) handleRestoreUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	if err := requireSelf(r, user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.UserService.RestoreUser(r.Context(), user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeUser(w, r, user)
+}
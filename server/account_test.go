@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+// Accepts "password" as the password of every user.
+type passwordUserService struct {
+	conlangdev.UserService
+}
+
+func (s *passwordUserService) CheckUserPassword(ctx context.Context, user *conlangdev.User, password string) error {
+	if password != "password" {
+		return errors.New("incorrect password")
+	}
+	return nil
+}
+
+// Accepts "123456" as the code of every user.
+type codeTwoFactorService struct {
+	conlangdev.TwoFactorService
+}
+
+func (s *codeTwoFactorService) CheckTwoFactorCode(ctx context.Context, user *conlangdev.User, code string) error {
+	if code != "123456" {
+		return &conlangdev.Error{Code: conlangdev.EUNAUTHORIZED, Message: "incorrect code", StatusCode: 401}
+	}
+	return nil
+}
+
+func TestReauthenticate(t *testing.T) {
+	s := NewServer().
+		WithUserService(&passwordUserService{}).
+		WithTwoFactorService(&codeTwoFactorService{})
+	enabledAt := time.Now()
+	withPassword := &conlangdev.User{PasswordHash: "hash"}
+	withTwoFactor := &conlangdev.User{PasswordHash: "hash", TwoFactorEnabledAt: &enabledAt}
+	passwordless := &conlangdev.User{}
+	passwordlessTwoFactor := &conlangdev.User{TwoFactorEnabledAt: &enabledAt}
+
+	tests := []struct {
+		name     string
+		user     *conlangdev.User
+		password string
+		code     string
+		ok       bool
+	}{
+		{"right password", withPassword, "password", "", true},
+		{"wrong password", withPassword, "wrong", "", false},
+		{"password and code", withTwoFactor, "password", "123456", true},
+		{"password without code", withTwoFactor, "password", "", false},
+		{"code without password", withTwoFactor, "", "123456", false},
+		{"no password to give", passwordless, "", "", false},
+		{"no password with code", passwordlessTwoFactor, "", "123456", true},
+		{"no password with wrong code", passwordlessTwoFactor, "", "000000", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("DELETE", "/user", nil)
+		err := s.reauthenticate(r, test.user, test.password, test.code)
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("%s: got error %v", test.name, err)
+		}
+	}
+}
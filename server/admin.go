@@ -0,0 +1,306 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+func (s *Server) registerAdminRoutes() {
+	s.router.Prefix("/admin", func(admin *Router) {
+		admin.Authorized(s.handleAdminIndexUser).GET("/users")
+		admin.Authorized(s.handleAdminViewUser).GET("/users/{username}")
+		admin.Authorized(s.handleAdminUpdateUser).PATCH("/users/{username}")
+		admin.Authorized(s.handleAdminSuspendUser).POST("/users/{username}/suspend")
+		admin.Authorized(s.handleAdminUnsuspendUser).POST("/users/{username}/unsuspend")
+		admin.Authorized(s.handleAdminResetTwoFactor).POST("/users/{username}/reset-2fa")
+		admin.Authorized(s.handleAdminHideProfile).POST("/users/{username}/hide-profile")
+		admin.Authorized(s.handleAdminShowProfile).POST("/users/{username}/show-profile")
+		admin.Authorized(s.handleAdminHideLanguage).POST("/languages/{username}/{language}/hide")
+		admin.Authorized(s.handleAdminShowLanguage).POST("/languages/{username}/{language}/show")
+	})
+}
+
+// Refuses users who are not administrators. Suspended users never get
+// this far, as they are stopped by authenticate.
+func requireAdmin(user *conlangdev.User) error {
+	if !user.IsAdmin() {
+		return &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "only administrators can do that",
+			StatusCode: http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
+func checkNotSuspended(user *conlangdev.User) error {
+	if user.Suspended() {
+		return &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "this account has been suspended",
+			StatusCode: http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
+// Finds the user named by the `username` route parameter for an
+// administrator, refusing anyone else.
+func (s *Server) findUserForAdmin(r *http.Request, admin *conlangdev.User) (*conlangdev.User, error) {
+	if err := requireAdmin(admin); err != nil {
+		return nil, err
+	}
+	return s.UserService.GetUserByUsername(r.Context(), mux.Vars(r)["username"])
+}
+
+// Decodes an optional request body giving the reason for a moderation
+// action.
+func decodeReasonPayload(r *http.Request) (string, error) {
+	var reasonPayload struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reasonPayload); err != nil && err != io.EOF {
+		return "", &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return reasonPayload.Reason, nil
+}
+
+// Lists users, narrowed down by the `q`, `role` and `suspended` query
+// parameters.
+func (s *Server) handleAdminIndexUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	if err := requireAdmin(user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	users, err := s.AdminService.FindUsers(r.Context(), conlangdev.UserFilter{
+		Query:     query.Get("q"),
+		Role:      query.Get("role"),
+		Suspended: query.Get("suspended") == "true",
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.User{
+		"users": users,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (s *Server) handleAdminViewUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	target, err := s.findUserForAdmin(r, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeUser(w, r, target)
+}
+
+// Changes the site-wide role of a user. Administrators cannot change their
+// own role, so that there is always someone left who can.
+func (s *Server) handleAdminUpdateUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	target, err := s.findUserForAdmin(r, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var updatePayload struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&updatePayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+	if target.ID == user.ID {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "you cannot change your own role",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.AdminService.SetUserRole(r.Context(), target, updatePayload.Role); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	log.Infof("%s set the role of %s to %s", user.Username, target.Username, target.Role)
+
+	writeUser(w, r, target)
+}
+
+func (s *Server) handleAdminSuspendUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	target, err := s.findUserForAdmin(r, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	reason, err := decodeReasonPayload(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.AdminService.SuspendUser(r.Context(), target, reason); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	log.Infof("%s suspended %s", user.Username, target.Username)
+
+	writeUser(w, r, target)
+}
+
+func (s *Server) handleAdminUnsuspendUser(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	target, err := s.findUserForAdmin(r, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.AdminService.UnsuspendUser(r.Context(), target); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	log.Infof("%s unsuspended %s", user.Username, target.Username)
+
+	writeUser(w, r, target)
+}
+
+// Turns off two-factor authentication for a user who has lost both their
+// authenticator app and their recovery codes.
+func (s *Server) handleAdminResetTwoFactor(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	target, err := s.findUserForAdmin(r, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if !target.TwoFactorEnabled() {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "two-factor authentication is not enabled",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.TwoFactorService.DisableTwoFactor(r.Context(), target); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	log.Infof("%s turned off two-factor authentication for %s", user.Username, target.Username)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAdminHideProfile(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	target, err := s.findUserForAdmin(r, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.AdminService.HideUserProfile(r.Context(), target); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	log.Infof("%s hid the profile of %s", user.Username, target.Username)
+
+	writeUser(w, r, target)
+}
+
+func (s *Server) handleAdminShowProfile(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	target, err := s.findUserForAdmin(r, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.AdminService.ShowUserProfile(r.Context(), target); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	log.Infof("%s showed the profile of %s again", user.Username, target.Username)
+
+	writeUser(w, r, target)
+}
+
+// Finds the language given by the route parameters for an administrator,
+// whether or not they could otherwise see it.
+func (s *Server) findLanguageForAdmin(r *http.Request, admin *conlangdev.User) (*conlangdev.Language, error) {
+	if err := requireAdmin(admin); err != nil {
+		return nil, err
+	}
+	return s.lookupLanguageFromParams(r)
+}
+
+func writeLanguage(w http.ResponseWriter, r *http.Request, language *conlangdev.Language) {
+	response, err := json.Marshal(map[string]*conlangdev.Language{
+		"language": language,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (s *Server) handleAdminHideLanguage(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageForAdmin(r, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	reason, err := decodeReasonPayload(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.AdminService.HideLanguage(r.Context(), language, reason); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	log.Infof("%s hid language %d (%s)", user.Username, language.ID, language.Slug)
+
+	writeLanguage(w, r, language)
+}
+
+func (s *Server) handleAdminShowLanguage(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageForAdmin(r, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.AdminService.ShowLanguage(r.Context(), language); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	log.Infof("%s showed language %d (%s) again", user.Username, language.ID, language.Slug)
+
+	writeLanguage(w, r, language)
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerAPITokenRoutes() {
+	s.router.Prefix("/auth/tokens", func(tokens *Router) {
+		tokens.Authorized(s.handleIndexAPIToken).GET("")
+		tokens.Authorized(s.handleCreateAPIToken).POST("")
+		tokens.Authorized(s.handleDeleteAPIToken).DELETE("/{token}")
+	})
+}
+
+func (s *Server) handleIndexAPIToken(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	tokens, err := s.APITokenService.FindAPITokensForUser(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.APIToken{
+		"api_tokens": tokens,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Creates an API token for the languages given by namespace and slug. The
+// user must already be allowed to do what the token gives access to. The
+// token itself is in the response, and cannot be seen again afterwards.
+func (s *Server) handleCreateAPIToken(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	var createPayload struct {
+		Name      string     `json:"name"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		Scopes    []struct {
+			Language string `json:"language"`
+			Access   string `json:"access"`
+		} `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&createPayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	create := conlangdev.APITokenCreate{
+		Name:      createPayload.Name,
+		ExpiresAt: createPayload.ExpiresAt,
+		Scopes:    make([]*conlangdev.APITokenScope, 0, len(createPayload.Scopes)),
+	}
+	for _, scope := range createPayload.Scopes {
+		parts := strings.Split(scope.Language, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			handleError(&conlangdev.FieldsError{
+				Code:       conlangdev.EVALIDFAIL,
+				Message:    "languages must be given as namespace/slug",
+				StatusCode: http.StatusBadRequest,
+				Fields:     []string{"Scopes"},
+			}).ServeHTTP(w, r)
+			return
+		}
+		language, err := s.LanguageService.GetLanguageByNamespaceAndSlug(r.Context(), parts[0], parts[1])
+		if err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+		action := conlangdev.ActionViewLanguage
+		if scope.Access == conlangdev.ScopeWrite {
+			action = conlangdev.ActionEditWords
+		}
+		if err := s.authorize(r, language, action); err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+		create.Scopes = append(create.Scopes, &conlangdev.APITokenScope{
+			LanguageID: language.ID,
+			Access:     scope.Access,
+		})
+	}
+
+	token, secret, err := s.APITokenService.CreateAPIToken(r.Context(), user, create)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"api_token": struct {
+			*conlangdev.APIToken
+			Token string `json:"token"`
+		}{token, secret},
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (s *Server) handleDeleteAPIToken(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	id, err := strconv.ParseUint(mux.Vars(r)["token"], 10, 32)
+	if err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "invalid API token ID",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	token, err := s.APITokenService.GetAPITokenForUser(r.Context(), user, uint(id))
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.APITokenService.RevokeAPIToken(r.Context(), token); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
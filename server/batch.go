@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+func (s *Server) registerBatchRoutes() {
+	s.router.Prefix("/word/{username}/{language}", func(word *Router) {
+		word.Scoped(s.handleWordBatch).POST("/batch")
+	})
+}
+
+type wordBatchOperationResponse struct {
+	*conlangdev.WordBatchOperationResult
+	Error map[string]interface{} `json:"error,omitempty"`
+}
+
+// Applies a batch of word operations. A batch which is rolled back takes
+// the status of the operation which failed, and its results show how far
+// the batch got.
+func (s *Server) handleWordBatch(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var batch conlangdev.WordBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	result, err := s.WordService.ApplyWordBatch(r.Context(), language, batch)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	status := http.StatusOK
+	results := make([]*wordBatchOperationResponse, 0, len(result.Results))
+	for _, opResult := range result.Results {
+		opResponse := &wordBatchOperationResponse{WordBatchOperationResult: opResult}
+		switch err := opResult.Error.(type) {
+		case *conlangdev.Error:
+			status = err.StatusCode
+			opResponse.Error = map[string]interface{}{
+				"code":    err.Code,
+				"message": err.Message,
+			}
+		case *conlangdev.FieldsError:
+			status = err.StatusCode
+			opResponse.Error = map[string]interface{}{
+				"code":    err.Code,
+				"message": err.Message,
+				"fields":  err.Fields,
+			}
+		}
+		results = append(results, opResponse)
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"committed": result.Committed,
+		"results":   results,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(response)
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerBranchRoutes() {
+	s.router.Prefix("/language/{username}/{language}", func(language *Router) {
+		language.Handle(s.handleIndexSnapshot).GET("/snapshots")
+		language.Scoped(s.handleCreateSnapshot).POST("/snapshots")
+		language.Handle(s.handleViewSnapshot).GET("/snapshots/{snapshot}")
+		language.Authorized(s.handleDeleteSnapshot).DELETE("/snapshots/{snapshot}")
+		language.Handle(s.handleIndexBranch).GET("/branches")
+		language.Scoped(s.handleCreateBranch).POST("/branches")
+		language.Handle(s.handleViewBranch).GET("/branches/{branch}")
+		language.Authorized(s.handleDeleteBranch).DELETE("/branches/{branch}")
+		language.Scoped(s.handleMergeBranch).POST("/branches/{branch}/merge")
+	})
+}
+
+// Selects the branch named by the `branch` query parameter for any word
+// operations made with the returned request. Without the parameter, or
+// when it names `main`, the request is returned as it is.
+func (s *Server) withBranchFromQuery(r *http.Request, language *conlangdev.Language) (*http.Request, error) {
+	name := r.URL.Query().Get("branch")
+	if name == "" || name == "main" {
+		return r, nil
+	}
+	branch, err := s.BranchService.GetBranchByName(r.Context(), language, name)
+	if err != nil {
+		return r, err
+	}
+	return r.WithContext(conlangdev.NewContextWithBranch(r.Context(), branch)), nil
+}
+
+func (s *Server) findSnapshotFromParams(r *http.Request, language *conlangdev.Language) (*conlangdev.Snapshot, error) {
+	return s.BranchService.GetSnapshotByName(r.Context(), language, mux.Vars(r)["snapshot"])
+}
+
+func (s *Server) findBranchFromParams(r *http.Request, language *conlangdev.Language) (*conlangdev.Branch, error) {
+	return s.BranchService.GetBranchByName(r.Context(), language, mux.Vars(r)["branch"])
+}
+
+func (s *Server) handleIndexSnapshot(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	snapshots, err := s.BranchService.FindSnapshotsForLanguage(r.Context(), language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.Snapshot{
+		"snapshots": snapshots,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleCreateSnapshot(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var create conlangdev.SnapshotCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	snapshot, err := s.BranchService.CreateSnapshot(r.Context(), language, create)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Snapshot{
+		"snapshot": snapshot,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleViewSnapshot(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	snapshot, err := s.findSnapshotFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	words, err := s.BranchService.ListWordsForSnapshot(r.Context(), snapshot)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"snapshot": snapshot,
+		"words":    words,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleDeleteSnapshot(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	snapshot, err := s.findSnapshotFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.BranchService.DeleteSnapshot(r.Context(), snapshot); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleIndexBranch(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	branches, err := s.BranchService.FindBranchesForLanguage(r.Context(), language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.Branch{
+		"branches": branches,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleCreateBranch(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var create conlangdev.BranchCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	branch, err := s.BranchService.CreateBranch(r.Context(), language, create)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Branch{
+		"branch": branch,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleViewBranch(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	branch, err := s.findBranchFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	ctx := conlangdev.NewContextWithBranch(r.Context(), branch)
+	words, err := s.WordService.FindWordsForLanguage(ctx, language, wordFilterFromQuery(r))
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"branch": branch,
+		"words":  words,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleDeleteBranch(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	branch, err := s.findBranchFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.BranchService.DeleteBranch(r.Context(), branch); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Merges a branch back into the main line. A merge which stops because of
+// conflicts is reported with a 409 status alongside the conflicts found.
+func (s *Server) handleMergeBranch(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	branch, err := s.findBranchFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var merge conlangdev.BranchMerge
+	if err := json.NewDecoder(r.Body).Decode(&merge); err != nil && err != io.EOF {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	result, err := s.BranchService.MergeBranch(r.Context(), branch, merge)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"branch": branch,
+		"merge":  result,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if !result.Merged && !merge.DryRun {
+		w.WriteHeader(http.StatusConflict)
+	}
+	w.Write(response)
+}
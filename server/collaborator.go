@@ -0,0 +1,291 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerCollaboratorRoutes() {
+	s.router.Prefix("/language", func(language *Router) {
+		language.Authorized(s.handleIndexInvite).GET("/invites")
+		language.Authorized(s.handleIndexCollaborator).GET("/{username}/{language}/collaborators")
+		language.Authorized(s.handleInviteCollaborator).POST("/{username}/{language}/collaborators")
+		language.Authorized(s.handleAcceptInvite).POST("/{username}/{language}/collaborators/accept")
+		language.Authorized(s.handleUpdateCollaborator).PATCH("/{username}/{language}/collaborators/{collaborator}")
+		language.Authorized(s.handleRemoveCollaborator).DELETE("/{username}/{language}/collaborators/{collaborator}")
+		language.Authorized(s.handleIndexAudit).GET("/{username}/{language}/audit")
+	})
+}
+
+// Finds the collaborator given by the `collaborator` route parameter on
+// the given language.
+func (s *Server) findCollaboratorFromParams(r *http.Request, language *conlangdev.Language) (*conlangdev.Collaborator, error) {
+	user, err := s.UserService.GetUserByUsername(r.Context(), mux.Vars(r)["collaborator"])
+	if err != nil {
+		return nil, err
+	}
+	return s.CollaboratorService.GetCollaboratorForLanguage(r.Context(), language, user)
+}
+
+func (s *Server) handleIndexInvite(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	invites, err := s.CollaboratorService.FindInvitesForUser(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	// Include each language so that invites can be linked to
+	results := make([]map[string]interface{}, 0, len(invites))
+	for _, invite := range invites {
+		language, err := s.LanguageService.GetLanguageByID(r.Context(), invite.LanguageID)
+		if err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+		ownerKind, ownerView, err := s.getOwnerViewForLanguage(r, language)
+		if err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+		results = append(results, map[string]interface{}{
+			"invite":   invite,
+			"language": language,
+			ownerKind:  ownerView,
+		})
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"invites": results,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleIndexCollaborator(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	collaborators, err := s.CollaboratorService.FindCollaboratorsForLanguage(r.Context(), language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.Collaborator{
+		"collaborators": collaborators,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleInviteCollaborator(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var invite conlangdev.CollaboratorInvite
+	if err := json.NewDecoder(r.Body).Decode(&invite); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	// Only owners may hand out the admin role
+	action := conlangdev.ActionManageLanguage
+	if invite.Role == conlangdev.RoleAdmin {
+		action = conlangdev.ActionManageAdmins
+	}
+	if err := s.authorize(r, language, action); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	collaborator, err := s.CollaboratorService.InviteCollaborator(r.Context(), language, invite)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Collaborator{
+		"collaborator": collaborator,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleAcceptInvite(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	// Invited users cannot see private languages until they accept, so the
+	// usual visibility check is skipped here.
+	language, err := s.lookupLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	collaborator, err := s.CollaboratorService.GetCollaboratorForLanguage(r.Context(), language, user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.CollaboratorService.AcceptInvite(r.Context(), collaborator); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"collaborator": collaborator,
+		"language":     language,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleUpdateCollaborator(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	collaborator, err := s.findCollaboratorFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var update conlangdev.CollaboratorUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	action := conlangdev.ActionManageLanguage
+	if update.Role == conlangdev.RoleAdmin || collaborator.Role == conlangdev.RoleAdmin {
+		action = conlangdev.ActionManageAdmins
+	}
+	if err := s.authorize(r, language, action); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.CollaboratorService.UpdateCollaborator(r.Context(), collaborator, update); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Collaborator{
+		"collaborator": collaborator,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+// Collaborators may always remove themselves, including declining an
+// invite to a language they cannot see yet. Anyone else must be able to
+// manage the language, and is told nothing about languages they cannot see.
+func (s *Server) handleRemoveCollaborator(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.lookupLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var collaborator *conlangdev.Collaborator
+	if strings.EqualFold(mux.Vars(r)["collaborator"], user.Username) {
+		collaborator, err = s.CollaboratorService.GetCollaboratorForLanguage(r.Context(), language, user)
+		if cd_err, ok := err.(*conlangdev.Error); ok && cd_err.Code == conlangdev.ENOTFOUND {
+			err = &conlangdev.Error{
+				Code:       conlangdev.ENOTFOUND,
+				Message:    "could not find that language",
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		if err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+	} else {
+		// Languages the user cannot see are reported as not found here
+		if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+		collaborator, err = s.findCollaboratorFromParams(r, language)
+		if err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+		if collaborator.Role == conlangdev.RoleAdmin {
+			if err := s.authorize(r, language, conlangdev.ActionManageAdmins); err != nil {
+				handleError(err).ServeHTTP(w, r)
+				return
+			}
+		}
+	}
+
+	if err := s.CollaboratorService.RemoveCollaborator(r.Context(), collaborator); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleIndexAudit(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	entries, err := s.CollaboratorService.FindAuditEntriesForLanguage(r.Context(), language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.AuditEntry{
+		"audit": entries,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+var errLanguageNotFound = &conlangdev.Error{
+	Code:       conlangdev.ENOTFOUND,
+	Message:    "could not find that language",
+	StatusCode: http.StatusNotFound,
+}
+
+// A single private language owned by user 1, with bob (user 2) invited to
+// it and carol (user 3) not.
+type privateLanguageServices struct {
+	conlangdev.LanguageService
+	conlangdev.UserService
+	conlangdev.CollaboratorService
+	conlangdev.AuthorizationService
+	removed bool
+}
+
+func (s *privateLanguageServices) GetLanguageByNamespaceAndSlug(ctx context.Context, namespace string, slug string) (*conlangdev.Language, error) {
+	if namespace != "alice" || slug != "secret" {
+		return nil, errLanguageNotFound
+	}
+	return &conlangdev.Language{ID: 1, UserID: 1, Visibility: conlangdev.VisibilityPrivate}, nil
+}
+
+func (s *privateLanguageServices) GetUserByUsername(ctx context.Context, username string) (*conlangdev.User, error) {
+	switch username {
+	case "bob":
+		return &conlangdev.User{ID: 2, Username: "bob"}, nil
+	case "carol":
+		return &conlangdev.User{ID: 3, Username: "carol"}, nil
+	}
+	return nil, &conlangdev.Error{Code: conlangdev.ENOTFOUND, Message: "could not find that user", StatusCode: http.StatusNotFound}
+}
+
+func (s *privateLanguageServices) GetCollaboratorForLanguage(ctx context.Context, language *conlangdev.Language, user *conlangdev.User) (*conlangdev.Collaborator, error) {
+	if user.ID != 2 {
+		return nil, &conlangdev.Error{Code: conlangdev.ENOTFOUND, Message: "could not find that collaborator", StatusCode: http.StatusNotFound}
+	}
+	return &conlangdev.Collaborator{LanguageID: 1, UserID: 2, Username: "bob", Role: conlangdev.RoleViewer}, nil
+}
+
+func (s *privateLanguageServices) RemoveCollaborator(ctx context.Context, collaborator *conlangdev.Collaborator) error {
+	s.removed = true
+	return nil
+}
+
+// Only the owner may do anything, as bob has not accepted his invite.
+func (s *privateLanguageServices) Authorize(ctx context.Context, user *conlangdev.User, language *conlangdev.Language, action string) error {
+	if user == nil || user.ID != 1 {
+		return errLanguageNotFound
+	}
+	return nil
+}
+
+func TestRemoveCollaborator(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         *conlangdev.User
+		language     string
+		collaborator string
+		status       int
+	}{
+		{"declining an invite", &conlangdev.User{ID: 2, Username: "bob"}, "secret", "bob", http.StatusNoContent},
+		{"owner removing", &conlangdev.User{ID: 1, Username: "alice"}, "secret", "bob", http.StatusNoContent},
+		{"stranger removing a collaborator", &conlangdev.User{ID: 3, Username: "carol"}, "secret", "bob", http.StatusNotFound},
+		{"stranger removing a non-collaborator", &conlangdev.User{ID: 3, Username: "carol"}, "secret", "dave", http.StatusNotFound},
+		{"stranger removing themselves", &conlangdev.User{ID: 3, Username: "carol"}, "secret", "carol", http.StatusNotFound},
+		{"stranger on a missing language", &conlangdev.User{ID: 3, Username: "carol"}, "missing", "bob", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		services := &privateLanguageServices{}
+		s := NewServer().
+			WithLanguageService(services).
+			WithUserService(services).
+			WithCollaboratorService(services).
+			WithAuthorizationService(services)
+
+		r := httptest.NewRequest("DELETE", "/language/alice/"+test.language+"/collaborators/"+test.collaborator, nil)
+		r = r.WithContext(conlangdev.NewContextWithUser(r.Context(), test.user))
+		r = mux.SetURLVars(r, map[string]string{
+			"username":     "alice",
+			"language":     test.language,
+			"collaborator": test.collaborator,
+		})
+		w := httptest.NewRecorder()
+		s.handleRemoveCollaborator(w, r, test.user)
+
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name, w.Code, test.status)
+		}
+		if removed := test.status == http.StatusNoContent; services.removed != removed {
+			t.Errorf("%s: removed %t, want %t", test.name, services.removed, removed)
+		}
+		// Everyone who cannot see the language gets the same answer
+		if w.Code == http.StatusNotFound && !strings.Contains(w.Body.String(), "could not find that language") {
+			t.Errorf("%s: got %s", test.name, w.Body)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/conlangdev/conlangdev/concepts"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerConceptRoutes() {
+	s.router.Prefix("/concepts", func(concept *Router) {
+		concept.Handle(s.handleIndexConceptList).GET("")
+		concept.Handle(s.handleViewConceptList).GET("/{list}")
+	})
+	s.router.Prefix("/language/{username}/{language}/coverage", func(coverage *Router) {
+		coverage.Handle(s.handleViewCoverage).GET("/{list}")
+		coverage.Scoped(s.handleCreateWordForConcept).POST("/{list}/{concept}")
+	})
+}
+
+func (s *Server) findConceptListFromParams(r *http.Request) (*conlangdev.ConceptList, error) {
+	return s.ConceptService.GetConceptList(r.Context(), mux.Vars(r)["list"])
+}
+
+func (s *Server) handleIndexConceptList(w http.ResponseWriter, r *http.Request) {
+	lists, err := s.ConceptService.FindConceptLists(r.Context())
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.ConceptList{
+		"lists": lists,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleViewConceptList(w http.ResponseWriter, r *http.Request) {
+	list, err := s.findConceptListFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.ConceptList{
+		"list": list,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+// Reports which concepts on a list the language has words for. The words
+// of a branch can be checked by giving its name as `branch`.
+func (s *Server) handleViewCoverage(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	list, err := s.findConceptListFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	coverage, err := s.ConceptService.GetCoverageForLanguage(r.Context(), language, list)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"language": language,
+		"coverage": coverage,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+// Coins a word for a concept on a list. The body is the same as when
+// creating any other word, except that the definition may be left out to
+// use the gloss of the concept.
+func (s *Server) handleCreateWordForConcept(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	list, err := s.findConceptListFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	concept := concepts.Find(list, mux.Vars(r)["concept"])
+	if concept == nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that concept on the list",
+			StatusCode: http.StatusNotFound,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	var create conlangdev.WordCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	word, err := s.ConceptService.CreateWordForConcept(r.Context(), language, concept, create)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Word{
+		"word": word,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+// Sends a link verifying the given address for a user, which is either
+// their current address or the one they want to change to.
+func (s *Server) sendVerificationMail(r *http.Request, user *conlangdev.User, email string) error {
+	token, err := s.EmailVerificationService.CreateEmailVerification(r.Context(), user, email)
+	if err != nil {
+		return err
+	}
+	return s.Mailer.Send(r.Context(), &conlangdev.Mail{
+		To:      email,
+		Subject: "Verify your conlangdev email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm that this is your email address using this within the next %d hours:\n\n%s\n\n"+
+				"If you didn't sign up for conlangdev, you can ignore this email.\n",
+			user.Username, int(conlangdev.EmailVerificationLifetime.Hours()),
+			s.publicLink("/verify-email", "token", token),
+		),
+	})
+}
+
+// Checks that a user has verified their email address before they do
+// something others can see, such as publishing a language.
+func requireVerifiedEmail(user *conlangdev.User) error {
+	if user.EmailVerified() {
+		return nil
+	}
+	return &conlangdev.Error{
+		Code:       conlangdev.EUNAUTHORIZED,
+		Message:    "verify your email address first",
+		StatusCode: http.StatusForbidden,
+	}
+}
+
+// Verifies an address using a token sent by sendVerificationMail. This
+// works without being logged in, since the link may be opened elsewhere.
+func (s *Server) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var verifyPayload struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&verifyPayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	user, err := s.EmailVerificationService.VerifyEmail(r.Context(), verifyPayload.Token)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.User{
+		"user": user,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Sends a new verification link to the current address of the user.
+func (s *Server) handleResendVerification(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	if user.EmailVerified() {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "your email address is already verified",
+			StatusCode: http.StatusConflict,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.sendVerificationMail(r, user, user.Email); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Starts changing the address of the user, once they have reauthenticated.
+// The new address replaces the old one once it has been verified.
+func (s *Server) handleChangeEmail(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	var changePayload struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&changePayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.reauthenticate(r, user, changePayload.Password, changePayload.Code); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.sendVerificationMail(r, user, changePayload.Email); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
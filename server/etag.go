@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+func languageETag(language *conlangdev.Language) string {
+	return fmt.Sprintf(`"language-%d-v%d"`, language.ID, language.Version)
+}
+
+func wordETag(word *conlangdev.Word) string {
+	return fmt.Sprintf(`"word-%d-v%d"`, word.UID, word.Version)
+}
+
+// Whether an If-Match or If-None-Match header matches the given entity
+// tag. Weak tags are treated the same as strong ones.
+func etagMatches(header string, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets the entity tag of a resource being read, then writes a 304 response
+// and returns true if the If-None-Match header shows the client already
+// has that version.
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// Checks that the If-Match header gives the current entity tag of a
+// resource being changed, so that changes made since the client loaded it
+// are not overwritten.
+func checkIfMatch(r *http.Request, tag string) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return &conlangdev.Error{
+			Code:       conlangdev.EPRECONDITIONREQUIRED,
+			Message:    "an If-Match header with the ETag of the version being changed is required",
+			StatusCode: http.StatusPreconditionRequired,
+		}
+	}
+	if !etagMatches(header, tag) {
+		return &conlangdev.Error{
+			Code:       conlangdev.EPRECONDITIONFAILED,
+			Message:    "that has been changed since it was loaded",
+			StatusCode: http.StatusPreconditionFailed,
+		}
+	}
+	return nil
+}
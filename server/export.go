@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/conlangdev/conlangdev/anki"
+)
+
+func (s *Server) registerExportRoutes() {
+	s.router.Prefix("/language/{username}/{language}/export", func(export *Router) {
+		export.Handle(s.handleExportAnki).GET("/anki")
+	})
+}
+
+// Exports the words of a language as an Anki deck. The deck name and card
+// templates can be given with the `deck`, `front` and `back` query
+// parameters, and words can be filtered in the same way as the word index.
+// Words are taken from the main line unless a `branch` is given.
+func (s *Server) handleExportAnki(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	fields, err := s.FieldDefinitionService.FindFieldDefinitionsForLanguage(r.Context(), language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	deck, err := anki.NewDeck(language, anki.Options{
+		DeckName:     query.Get("deck"),
+		Front:        query.Get("front"),
+		Back:         query.Get("back"),
+		CustomFields: fields,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	words, err := s.WordService.ListWordsForLanguage(r.Context(), language, wordFilterFromQuery(r))
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	// Build the deck in memory first so that failures can still be
+	// reported as a JSON error response.
+	var buffer bytes.Buffer
+	if err := deck.WriteAPKG(r.Context(), &buffer, words); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": language.Slug + ".apkg",
+	}))
+	w.Header().Set("Content-Length", strconv.Itoa(buffer.Len()))
+	w.Write(buffer.Bytes())
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerFieldRoutes() {
+	s.router.Prefix("/language/{username}/{language}/fields", func(field *Router) {
+		field.Handle(s.handleIndexField).GET("")
+		field.Authorized(s.handleCreateField).POST("")
+		field.Handle(s.handleViewField).GET("/{field}")
+		field.Authorized(s.handleUpdateField).PATCH("/{field}")
+		field.Authorized(s.handleDeleteField).DELETE("/{field}")
+	})
+}
+
+func (s *Server) findFieldFromParams(r *http.Request, language *conlangdev.Language) (*conlangdev.FieldDefinition, error) {
+	return s.FieldDefinitionService.GetFieldDefinitionByName(r.Context(), language, mux.Vars(r)["field"])
+}
+
+func (s *Server) handleIndexField(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	fields, err := s.FieldDefinitionService.FindFieldDefinitionsForLanguage(r.Context(), language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.FieldDefinition{
+		"fields": fields,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleCreateField(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var create conlangdev.FieldDefinitionCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	field, err := s.FieldDefinitionService.CreateFieldDefinition(r.Context(), language, create)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.FieldDefinition{
+		"field": field,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleViewField(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	field, err := s.findFieldFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.FieldDefinition{
+		"field": field,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleUpdateField(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	field, err := s.findFieldFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var update conlangdev.FieldDefinitionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.FieldDefinitionService.UpdateFieldDefinition(r.Context(), field, update); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.FieldDefinition{
+		"field": field,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleDeleteField(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	field, err := s.findFieldFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.FieldDefinitionService.DeleteFieldDefinition(r.Context(), field); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,15 +2,24 @@ package server
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 
 	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
 )
 
 func (s *Server) registerLanguageRoutes() {
 	s.router.Prefix("/language", func(language *Router) {
 		language.Authorized(s.handleCreateLanguage).POST("")
 		language.Authorized(s.handleIndexLanguage).GET("")
+		language.Handle(s.handleIndexPublicLanguage).GET("/public")
+		language.Handle(s.handleViewLanguage).GET("/{username}/{language}")
+		language.Authorized(s.handleUpdateLanguage).PATCH("/{username}/{language}")
+		language.Authorized(s.handleDeleteLanguage).DELETE("/{username}/{language}")
+		language.Authorized(s.handleTransferLanguage).POST("/{username}/{language}/transfer")
+		language.Authorized(s.handleForkLanguage).POST("/{username}/{language}/fork")
+		language.Handle(s.handleCompareUpstream).GET("/{username}/{language}/upstream")
 	})
 }
 
@@ -32,6 +41,120 @@ func (s *Server) handleIndexLanguage(w http.ResponseWriter, r *http.Request, use
 	w.Write(response)
 }
 
+func (s *Server) handleIndexPublicLanguage(w http.ResponseWriter, r *http.Request) {
+	languages, err := s.LanguageService.FindPublicLanguages(r.Context())
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.Language{
+		"languages": languages,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleViewLanguage(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if notModified(w, r, languageETag(language)) {
+		return
+	}
+
+	ownerKind, ownerView, err := s.getOwnerViewForLanguage(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		ownerKind:  ownerView,
+		"language": language,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleUpdateLanguage(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := checkIfMatch(r, languageETag(language)); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var update conlangdev.LanguageUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+	if update.Visibility != nil && *update.Visibility != conlangdev.VisibilityPrivate {
+		if err := requireVerifiedEmail(user); err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+	}
+
+	if err := s.LanguageService.UpdateLanguage(r.Context(), language, update); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("ETag", languageETag(language))
+
+	response, err := json.Marshal(map[string]*conlangdev.Language{
+		"language": language,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleDeleteLanguage(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionDeleteLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := checkIfMatch(r, languageETag(language)); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.LanguageService.DeleteLanguage(r.Context(), language); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s *Server) handleCreateLanguage(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
 	// Decode request body
 	var create conlangdev.LanguageCreate
@@ -43,8 +166,25 @@ func (s *Server) handleCreateLanguage(w http.ResponseWriter, r *http.Request, us
 		}).ServeHTTP(w, r)
 		return
 	}
-	// Create language
-	language, err := s.LanguageService.CreateLanguageForUser(r.Context(), user, create)
+	if create.Visibility != "" && create.Visibility != conlangdev.VisibilityPrivate {
+		if err := requireVerifiedEmail(user); err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+	}
+	// Create language, either for the user or for one of their teams
+	var language *conlangdev.Language
+	var err error
+	if create.Namespace == "" || create.Namespace == user.Username {
+		language, err = s.LanguageService.CreateLanguageForUser(r.Context(), user, create)
+	} else {
+		var team *conlangdev.Team
+		if team, err = s.TeamService.GetTeamBySlug(r.Context(), create.Namespace); err == nil {
+			if err = s.authorizeTeam(r, team, user, conlangdev.TeamRoleMember); err == nil {
+				language, err = s.LanguageService.CreateLanguageForTeam(r.Context(), team, create)
+			}
+		}
+	}
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
@@ -59,3 +199,194 @@ func (s *Server) handleCreateLanguage(w http.ResponseWriter, r *http.Request, us
 	}
 	w.Write(response)
 }
+
+// Moves a language to another namespace. Languages can be moved to the
+// requesting user's own namespace or to a team they administer.
+func (s *Server) handleTransferLanguage(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionTransferLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var transfer struct {
+		Namespace string `json:"namespace"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&transfer); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if transfer.Namespace == user.Username {
+		err = s.LanguageService.TransferLanguageToUser(r.Context(), language, user)
+	} else {
+		var team *conlangdev.Team
+		if team, err = s.TeamService.GetTeamBySlug(r.Context(), transfer.Namespace); err != nil {
+			err = &conlangdev.Error{
+				Code:       conlangdev.EBADREQUEST,
+				Message:    "languages can only be transferred to yourself or a team you administer",
+				StatusCode: http.StatusBadRequest,
+			}
+		} else if err = s.authorizeTeam(r, team, user, conlangdev.TeamRoleAdmin); err == nil {
+			err = s.LanguageService.TransferLanguageToTeam(r.Context(), language, team)
+		}
+	}
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Language{
+		"language": language,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+// Forks a language into the requesting user's namespace. The name and slug
+// of the fork may be given, otherwise the upstream language's are used.
+func (s *Server) handleForkLanguage(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	upstream, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var fork conlangdev.LanguageFork
+	if err := json.NewDecoder(r.Body).Decode(&fork); err != nil && err != io.EOF {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	language, err := s.LanguageService.ForkLanguageForUser(r.Context(), upstream, user, fork)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Language{
+		"language": language,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+// Lists the words added, changed or removed in a fork and in the language
+// it was forked from since the fork was made.
+func (s *Server) handleCompareUpstream(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if language.ForkedFromID == 0 {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "that language is not a fork",
+			StatusCode: http.StatusNotFound,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	upstream, err := s.LanguageService.GetLanguageByID(r.Context(), language.ForkedFromID)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, upstream, conlangdev.ActionViewLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	comparison, err := s.WordService.CompareWithUpstream(r.Context(), language, upstream)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"language":   language,
+		"upstream":   upstream,
+		"comparison": comparison,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+// Finds the language given by the `username` and `language` route
+// parameters without checking whether the requesting user may see it. The
+// `username` parameter may also be the slug of a team.
+func (s *Server) lookupLanguageFromParams(r *http.Request) (*conlangdev.Language, error) {
+	params := mux.Vars(r)
+	return s.LanguageService.GetLanguageByNamespaceAndSlug(r.Context(), params["username"], params["language"])
+}
+
+// Finds the language given by the `username` and `language` route
+// parameters.
+//
+// Every read of a language or its words should go through here, as
+// languages the requesting user may not see are reported as not found.
+func (s *Server) findLanguageFromParams(r *http.Request) (*conlangdev.Language, error) {
+	language, err := s.lookupLanguageFromParams(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(r, language, conlangdev.ActionViewLanguage); err != nil {
+		return nil, err
+	}
+
+	return language, nil
+}
+
+// Gives a public view of the user or team owning a language, along with
+// "user" or "team" to say which it is.
+func (s *Server) getOwnerViewForLanguage(r *http.Request, language *conlangdev.Language) (string, interface{}, error) {
+	if language.TeamID != 0 {
+		team, err := s.TeamService.GetTeamByID(r.Context(), language.TeamID)
+		return "team", team, err
+	}
+
+	owner, err := s.UserService.GetUserByID(r.Context(), language.UserID)
+	if err != nil {
+		return "", nil, err
+	}
+	ownerView, err := s.UserService.GetViewForUser(r.Context(), owner)
+	return "user", ownerView, err
+}
+
+// Checks that the requesting user may perform an action on a language.
+func (s *Server) authorize(r *http.Request, language *conlangdev.Language, action string) error {
+	user := conlangdev.GetUserFromContext(r.Context())
+	if err := s.AuthorizationService.Authorize(r.Context(), user, language, action); err != nil {
+		return err
+	}
+	if token := conlangdev.GetAPITokenFromContext(r.Context()); token != nil && !token.Allows(language, action) {
+		return &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "this API token does not allow that",
+			StatusCode: http.StatusForbidden,
+		}
+	}
+	return nil
+}
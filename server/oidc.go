@@ -0,0 +1,211 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+func (s *Server) registerOIDCRoutes() {
+	s.router.Prefix("/auth/oidc", func(oidc *Router) {
+		oidc.Handle(s.handleIndexOIDCProvider).GET("")
+		oidc.Guest(s.handleStartOIDCLogin).Use(s.limitByIP("login", &s.RateLimits.Login)).POST("/{provider}")
+		oidc.Guest(s.handleFinishOIDCLogin).Use(s.limitByIP("login", &s.RateLimits.Login)).POST("/{provider}/callback")
+	})
+	s.router.Prefix("/auth/identities", func(identities *Router) {
+		identities.Authorized(s.handleIndexOIDCIdentity).GET("")
+	})
+}
+
+func (s *Server) findOIDCProviderFromParams(r *http.Request) (conlangdev.OIDCProvider, error) {
+	provider, ok := s.OIDCProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that identity provider",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return provider, nil
+}
+
+func (s *Server) handleIndexOIDCProvider(w http.ResponseWriter, r *http.Request) {
+	type providerView struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+	}
+	providers := make([]providerView, 0, len(s.OIDCProviders))
+	for _, provider := range s.OIDCProviders {
+		providers = append(providers, providerView{provider.Name(), provider.DisplayName()})
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Name < providers[j].Name
+	})
+
+	response, err := json.Marshal(map[string]interface{}{
+		"providers": providers,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Checks the redirect URI a client asked for is one identity providers may
+// send users back to, which is the web app's callback page or one of those
+// configured. The web app's page is given when the client asks for none.
+func (s *Server) checkOIDCRedirectURI(provider conlangdev.OIDCProvider, redirectURI string) (string, error) {
+	allowed := make([]string, 0, len(s.OIDCRedirectURIs)+1)
+	if s.PublicURL != "" {
+		allowed = append(allowed, s.PublicURL+"/login/"+provider.Name()+"/callback")
+	}
+	for _, uri := range s.OIDCRedirectURIs {
+		allowed = append(allowed, strings.ReplaceAll(uri, "{provider}", provider.Name()))
+	}
+
+	if redirectURI == "" && len(allowed) > 0 {
+		return allowed[0], nil
+	}
+	for _, uri := range allowed {
+		if redirectURI == uri {
+			return uri, nil
+		}
+	}
+	return "", &conlangdev.Error{
+		Code:       conlangdev.EBADREQUEST,
+		Message:    "that redirect URI is not allowed",
+		StatusCode: http.StatusBadRequest,
+	}
+}
+
+// Starts signing in with an identity provider, giving the URL to send the
+// user to and the client token to finish signing in with. The provider
+// sends them back to the redirect URI, which is the web app's callback
+// page unless the client gives another allowed one.
+func (s *Server) handleStartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, err := s.findOIDCProviderFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var startPayload struct {
+		RedirectURI string `json:"redirect_uri"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&startPayload); err != nil && err != io.EOF {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+	redirectURI, err := s.checkOIDCRedirectURI(provider, startPayload.RedirectURI)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	login, err := s.OIDCService.CreateOIDCLogin(r.Context(), provider.Name(), redirectURI)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	authorizationURL, err := provider.AuthCodeURL(r.Context(), login)
+	if err != nil {
+		log.Errorf("could not reach identity provider %s: %v", provider.Name(), err)
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.ESERVER,
+			Message:    "could not reach the identity provider",
+			StatusCode: http.StatusBadGateway,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]string{
+		"authorization_url": authorizationURL,
+		"client_token":      login.ClientToken,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Finishes signing in with the code and state the identity provider sent
+// back, along with the client token from starting the sign in, starting a
+// session for the linked user. Users with two-factor authentication still
+// need to give their second factor.
+func (s *Server) handleFinishOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, err := s.findOIDCProviderFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var callbackPayload struct {
+		State       string `json:"state"`
+		Code        string `json:"code"`
+		ClientToken string `json:"client_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&callbackPayload); err != nil || callbackPayload.State == "" || callbackPayload.Code == "" || callbackPayload.ClientToken == "" {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	login, err := s.OIDCService.ConsumeOIDCLogin(r.Context(), provider.Name(), callbackPayload.State, callbackPayload.ClientToken)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	claims, err := provider.Exchange(r.Context(), login, callbackPayload.Code)
+	if err != nil {
+		log.Warnf("could not sign in with identity provider %s: %v", provider.Name(), err)
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "could not sign in with " + provider.DisplayName(),
+			StatusCode: http.StatusUnauthorized,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	user, err := s.OIDCService.GetUserForOIDCClaims(r.Context(), provider.Name(), claims)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	s.startSession(w, r, user)
+}
+
+func (s *Server) handleIndexOIDCIdentity(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	identities, err := s.OIDCService.FindOIDCIdentitiesForUser(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.OIDCIdentity{
+		"identities": identities,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/conlangdev/conlangdev/oidc"
+	"github.com/conlangdev/conlangdev/oidc/oidctest"
+)
+
+// Keeps sign ins and users in memory, linking identities with the same
+// decision as the database.
+type memoryOIDCService struct {
+	logins     map[string]*conlangdev.OIDCLogin
+	users      []*conlangdev.User
+	identities map[string]*conlangdev.User
+}
+
+func newMemoryOIDCService(users ...*conlangdev.User) *memoryOIDCService {
+	return &memoryOIDCService{
+		logins:     make(map[string]*conlangdev.OIDCLogin),
+		users:      users,
+		identities: make(map[string]*conlangdev.User),
+	}
+}
+
+func randomToken() string {
+	buffer := make([]byte, 24)
+	rand.Read(buffer)
+	return base64.RawURLEncoding.EncodeToString(buffer)
+}
+
+func (s *memoryOIDCService) CreateOIDCLogin(ctx context.Context, provider string, redirectURI string) (*conlangdev.OIDCLogin, error) {
+	login := &conlangdev.OIDCLogin{
+		Provider:    provider,
+		State:       randomToken(),
+		ClientToken: randomToken(),
+		Nonce:       randomToken(),
+		Verifier:    randomToken(),
+		RedirectURI: redirectURI,
+	}
+	s.logins[login.State] = login
+	return login, nil
+}
+
+func (s *memoryOIDCService) ConsumeOIDCLogin(ctx context.Context, provider string, state string, clientToken string) (*conlangdev.OIDCLogin, error) {
+	login, ok := s.logins[state]
+	delete(s.logins, state)
+	if !ok || login.Provider != provider || login.ClientToken != clientToken {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "that sign in is not valid or has expired, try again",
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	return login, nil
+}
+
+func (s *memoryOIDCService) GetUserForOIDCClaims(ctx context.Context, provider string, claims *conlangdev.OIDCClaims) (*conlangdev.User, error) {
+	if user, ok := s.identities[provider+":"+claims.Subject]; ok {
+		return user, nil
+	}
+
+	var existing *conlangdev.User
+	for _, u := range s.users {
+		if u.Email == claims.Email {
+			existing = u
+		}
+	}
+	user, create, err := conlangdev.UserForOIDCClaims(claims, existing, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if create {
+		user.ID = uint(len(s.users) + 1)
+		user.Username = claims.PreferredUsername
+		s.users = append(s.users, user)
+	}
+	s.identities[provider+":"+claims.Subject] = user
+	return user, nil
+}
+
+func (s *memoryOIDCService) FindOIDCIdentitiesForUser(ctx context.Context, user *conlangdev.User) ([]*conlangdev.OIDCIdentity, error) {
+	return nil, nil
+}
+
+// Starts a session for anyone, leaving the rest of the service out.
+type memorySessionService struct {
+	conlangdev.SessionService
+}
+
+func (s *memorySessionService) CreateSession(ctx context.Context, user *conlangdev.User, create conlangdev.SessionCreate) (*conlangdev.SessionTokens, error) {
+	return &conlangdev.SessionTokens{AccessToken: "access-" + user.Username}, nil
+}
+
+func servePOST(handler http.Handler, path string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(string(body))))
+	return w
+}
+
+// Signs in with the test provider through the server, giving what the
+// client sends back to finish signing in. The provider approves every sign
+// in, so the user is sent back without any redirects being followed.
+func authorizeOIDCLogin(t *testing.T, handler http.Handler) map[string]string {
+	t.Helper()
+	w := servePOST(handler, "/auth/oidc/test", map[string]string{
+		"redirect_uri": "https://app.invalid/login/test/callback",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("starting the sign in: got status %d: %s", w.Code, w.Body)
+	}
+	var start struct {
+		AuthorizationURL string `json:"authorization_url"`
+		ClientToken      string `json:"client_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &start); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	response, err := client.Get(start.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	location, err := response.Location()
+	if err != nil {
+		t.Fatalf("authorizing: got status %d without a redirect", response.StatusCode)
+	}
+	return map[string]string{
+		"state":        location.Query().Get("state"),
+		"code":         location.Query().Get("code"),
+		"client_token": start.ClientToken,
+	}
+}
+
+// Sets up a server which signs in with the given provider, under the name
+// "test".
+func newOIDCTestServer(provider *oidctest.Provider, service conlangdev.OIDCService) http.Handler {
+	s := NewServer().
+		WithPublicURL("https://app.invalid").
+		WithRateLimits(RateLimits{}).
+		WithOIDCService(service).
+		WithSessionService(&memorySessionService{}).
+		WithOIDCProvider(oidc.NewProvider(provider.Config("test")))
+	return s.router.GetHandler()
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider, err := oidctest.NewProvider("conlangdev", "conlangdev-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Close()
+
+	verifiedAt := time.Now()
+	newUser := oidctest.User{
+		Subject:           "new",
+		Email:             "new@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "newuser",
+	}
+	verifiedAlice := oidctest.User{
+		Subject:       "alice",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+	}
+	unverifiedAlice := verifiedAlice
+	unverifiedAlice.EmailVerified = false
+	verifiedBob := oidctest.User{
+		Subject:       "bob",
+		Email:         "bob@example.com",
+		EmailVerified: true,
+	}
+
+	tests := []struct {
+		name string
+		user oidctest.User
+		// Changes the stored login, or what the client sends back, before
+		// the sign in is finished
+		tamper   func(login *conlangdev.OIDCLogin, callback map[string]string)
+		status   int
+		username string
+	}{
+		{"new user", newUser, nil, http.StatusOK, "newuser"},
+		{"wrong PKCE verifier", newUser, func(login *conlangdev.OIDCLogin, callback map[string]string) {
+			login.Verifier = randomToken()
+		}, http.StatusUnauthorized, ""},
+		{"wrong state", newUser, func(login *conlangdev.OIDCLogin, callback map[string]string) {
+			callback["state"] = randomToken()
+		}, http.StatusUnauthorized, ""},
+		{"wrong client token", newUser, func(login *conlangdev.OIDCLogin, callback map[string]string) {
+			callback["client_token"] = randomToken()
+		}, http.StatusUnauthorized, ""},
+		{"wrong nonce", newUser, func(login *conlangdev.OIDCLogin, callback map[string]string) {
+			login.Nonce = randomToken()
+		}, http.StatusUnauthorized, ""},
+		{"links verified email", verifiedAlice, nil, http.StatusOK, "alice"},
+		{"refuses linking unverified email", unverifiedAlice, nil, http.StatusConflict, ""},
+		{"refuses linking to unverified account", verifiedBob, nil, http.StatusConflict, ""},
+	}
+	for _, test := range tests {
+		service := newMemoryOIDCService(
+			&conlangdev.User{ID: 1, Username: "alice", Email: "alice@example.com", EmailVerifiedAt: &verifiedAt},
+			&conlangdev.User{ID: 2, Username: "bob", Email: "bob@example.com"},
+		)
+		handler := newOIDCTestServer(provider, service)
+		provider.SetUser(test.user)
+
+		callback := authorizeOIDCLogin(t, handler)
+		if test.tamper != nil {
+			test.tamper(service.logins[callback["state"]], callback)
+		}
+		w := servePOST(handler, "/auth/oidc/test/callback", callback)
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d, want %d: %s", test.name, w.Code, test.status, w.Body)
+			continue
+		}
+		if test.username == "" {
+			continue
+		}
+		var response struct {
+			Authentication struct {
+				User conlangdev.User `json:"user"`
+			} `json:"authentication"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if username := response.Authentication.User.Username; username != test.username {
+			t.Errorf("%s: signed in as %q, want %q", test.name, username, test.username)
+		}
+	}
+}
+
+func TestOIDCLoginStateUsedOnce(t *testing.T) {
+	provider, err := oidctest.NewProvider("conlangdev", "conlangdev-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Close()
+
+	handler := newOIDCTestServer(provider, newMemoryOIDCService())
+
+	callback := authorizeOIDCLogin(t, handler)
+	if w := servePOST(handler, "/auth/oidc/test/callback", callback); w.Code != http.StatusOK {
+		t.Fatalf("first callback: got status %d: %s", w.Code, w.Body)
+	}
+	if w := servePOST(handler, "/auth/oidc/test/callback", callback); w.Code != http.StatusUnauthorized {
+		t.Errorf("replayed callback: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestOIDCRedirectURI(t *testing.T) {
+	s := NewServer().
+		WithPublicURL("https://app.invalid/").
+		WithOIDCRedirectURIs([]string{"http://localhost:3000/login/{provider}/callback"})
+	// Providers are not contacted until they are used
+	testProvider := oidc.NewProvider(oidc.Config{Name: "test"})
+
+	tests := []struct {
+		redirectURI string
+		allowed     string
+	}{
+		{"", "https://app.invalid/login/test/callback"},
+		{"https://app.invalid/login/test/callback", "https://app.invalid/login/test/callback"},
+		{"http://localhost:3000/login/test/callback", "http://localhost:3000/login/test/callback"},
+		{"https://attacker.invalid/login/test/callback", ""},
+		{"https://app.invalid/login/other/callback", ""},
+		{"http://localhost:3000/login/{provider}/callback", ""},
+	}
+	for _, test := range tests {
+		redirectURI, err := s.checkOIDCRedirectURI(testProvider, test.redirectURI)
+		if test.allowed == "" && err == nil {
+			t.Errorf("%q: allowed, want it refused", test.redirectURI)
+		} else if test.allowed != "" && redirectURI != test.allowed {
+			t.Errorf("%q: got %q (%v), want %q", test.redirectURI, redirectURI, err, test.allowed)
+		}
+	}
+}
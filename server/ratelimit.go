@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+// Rate limits applied by the server. Any of them can be turned off by
+// leaving them empty.
+type RateLimits struct {
+	// Requests from each IP address to log in, finish logging in with a
+	// second factor, or reset a password.
+	Login conlangdev.RateLimit
+	// Requests from each IP address to register.
+	Register conlangdev.RateLimit
+	// Requests from each authenticated user, however they authenticated.
+	Account conlangdev.RateLimit
+	// How logins to a single account are locked out after failing, however
+	// many addresses they come from.
+	Lockout conlangdev.LockoutPolicy
+}
+
+var DefaultRateLimits = RateLimits{
+	Login:    conlangdev.RateLimit{Requests: 10, Window: time.Minute},
+	Register: conlangdev.RateLimit{Requests: 5, Window: time.Hour},
+	Account:  conlangdev.RateLimit{Requests: 600, Window: time.Minute},
+	Lockout: conlangdev.LockoutPolicy{
+		Attempts: 5,
+		Window:   24 * time.Hour,
+		Delay:    30 * time.Second,
+		MaxDelay: time.Hour,
+	},
+}
+
+// Refuses a request for going over a rate limit, telling the client how
+// many seconds to wait before trying again.
+func handleRateLimited(retryAfter time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seconds := int((retryAfter + time.Second - 1) / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.ETOOMANYREQUESTS,
+			Message:    "too many requests, try again later",
+			StatusCode: http.StatusTooManyRequests,
+		}).ServeHTTP(w, r)
+	})
+}
+
+// Counts a request against a limit, giving how long to wait if it goes
+// over, or zero if it does not.
+func (s *Server) takeRateLimit(ctx context.Context, key string, limit conlangdev.RateLimit) (time.Duration, error) {
+	if !limit.Enabled() {
+		return 0, nil
+	}
+	count, expiresAt, err := s.RateLimitStore.Increment(ctx, key, limit.Window)
+	if err != nil {
+		return 0, err
+	}
+	if count > limit.Requests {
+		return time.Until(expiresAt), nil
+	}
+	return 0, nil
+}
+
+// Middleware limiting requests from each IP address, with counters kept
+// under the given name. The limit is read on each request, so that it can
+// be changed after routes are registered.
+func (s *Server) limitByIP(name string, limit *conlangdev.RateLimit) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			retryAfter, err := s.takeRateLimit(r.Context(), name+":ip:"+clientIP(r), *limit)
+			if err != nil {
+				handleError(err).ServeHTTP(w, r)
+				return
+			} else if retryAfter > 0 {
+				handleRateLimited(retryAfter).ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Middleware limiting requests from each authenticated user, which must
+// come after authenticate.
+func (s *Server) limitByAccount(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := conlangdev.GetUserFromContext(r.Context()); user != nil {
+			key := "account:user:" + strconv.FormatUint(uint64(user.ID), 10)
+			retryAfter, err := s.takeRateLimit(r.Context(), key, s.RateLimits.Account)
+			if err != nil {
+				handleError(err).ServeHTTP(w, r)
+				return
+			} else if retryAfter > 0 {
+				handleRateLimited(retryAfter).ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Lockouts are kept by the username given rather than by user, so that
+// unknown usernames are treated the same as known ones.
+func lockoutKey(username string) string {
+	return "lockout:username:" + strings.ToLower(username)
+}
+
+func failuresKey(username string) string {
+	return "failures:username:" + strings.ToLower(username)
+}
+
+// Gives how much longer logins to an account are locked out for, or zero
+// if they are not.
+func (s *Server) checkLoginLockout(ctx context.Context, username string) (time.Duration, error) {
+	if !s.RateLimits.Lockout.Enabled() {
+		return 0, nil
+	}
+	count, expiresAt, err := s.RateLimitStore.Get(ctx, lockoutKey(username))
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	return time.Until(expiresAt), nil
+}
+
+// Gives how long an account is locked for after a number of failed logins,
+// or zero if it is not locked yet.
+func lockoutDelay(policy conlangdev.LockoutPolicy, failures int) time.Duration {
+	if failures < policy.Attempts {
+		return 0
+	}
+	delay := policy.Delay
+	for i := policy.Attempts; i < failures && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	return delay
+}
+
+// Counts a failed login to an account, locking it out once there have been
+// too many. Each failure past the allowed attempts doubles the lockout.
+func (s *Server) recordLoginFailure(ctx context.Context, username string) error {
+	policy := s.RateLimits.Lockout
+	if !policy.Enabled() {
+		return nil
+	}
+	failures, _, err := s.RateLimitStore.Increment(ctx, failuresKey(username), policy.Window)
+	if err != nil {
+		return err
+	}
+	delay := lockoutDelay(policy, failures)
+	if delay == 0 {
+		return nil
+	}
+	if err := s.RateLimitStore.Reset(ctx, lockoutKey(username)); err != nil {
+		return err
+	}
+	_, _, err = s.RateLimitStore.Increment(ctx, lockoutKey(username), delay)
+	return err
+}
+
+// Forgets the failed logins to an account once somebody gets in.
+func (s *Server) clearLoginFailures(ctx context.Context, username string) error {
+	if !s.RateLimits.Lockout.Enabled() {
+		return nil
+	}
+	if err := s.RateLimitStore.Reset(ctx, failuresKey(username)); err != nil {
+		return err
+	}
+	return s.RateLimitStore.Reset(ctx, lockoutKey(username))
+}
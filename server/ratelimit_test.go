@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+func TestLockoutDelay(t *testing.T) {
+	policy := conlangdev.LockoutPolicy{
+		Attempts: 3,
+		Window:   time.Hour,
+		Delay:    time.Second,
+		MaxDelay: 10 * time.Second,
+	}
+	uncapped := policy
+	uncapped.MaxDelay = 0
+
+	tests := []struct {
+		name     string
+		policy   conlangdev.LockoutPolicy
+		failures int
+		delay    time.Duration
+	}{
+		{"first failure", policy, 1, 0},
+		{"below the attempts", policy, 2, 0},
+		{"reaching the attempts", policy, 3, time.Second},
+		{"doubles once", policy, 4, 2 * time.Second},
+		{"doubles again", policy, 5, 4 * time.Second},
+		{"doubles a third time", policy, 6, 8 * time.Second},
+		{"capped at the maximum", policy, 7, 10 * time.Second},
+		{"stays at the maximum", policy, 100, 10 * time.Second},
+		{"no maximum never grows", uncapped, 5, time.Second},
+	}
+	for _, test := range tests {
+		if delay := lockoutDelay(test.policy, test.failures); delay != test.delay {
+			t.Errorf("%s: got %s, want %s", test.name, delay, test.delay)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer().WithRateLimits(RateLimits{
+		Lockout: conlangdev.LockoutPolicy{
+			Attempts: 2,
+			Window:   time.Hour,
+			Delay:    time.Minute,
+			MaxDelay: time.Hour,
+		},
+	})
+
+	tests := []struct {
+		name   string
+		locked time.Duration
+	}{
+		{"one failure is allowed", 0},
+		{"locked once the attempts are used", time.Minute},
+		{"lockout doubles", 2 * time.Minute},
+	}
+	for _, test := range tests {
+		if err := s.recordLoginFailure(ctx, "Bob"); err != nil {
+			t.Fatal(err)
+		}
+		// Usernames are not case sensitive
+		locked, err := s.checkLoginLockout(ctx, "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked > test.locked || locked < test.locked-time.Second {
+			t.Errorf("%s: locked for %s, want %s", test.name, locked, test.locked)
+		}
+	}
+
+	if err := s.clearLoginFailures(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if locked, _ := s.checkLoginLockout(ctx, "bob"); locked != 0 {
+		t.Errorf("after logging in: locked for %s, want 0", locked)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		header     string
+	}{
+		{0, "1"},
+		{time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{time.Minute, "60"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handleRateLimited(test.retryAfter).ServeHTTP(w, httptest.NewRequest("POST", "/auth/login", nil))
+		if w.Code != 429 {
+			t.Errorf("%s: got status %d, want 429", test.retryAfter, w.Code)
+		}
+		if header := w.Header().Get("Retry-After"); header != test.header {
+			t.Errorf("%s: got Retry-After %q, want %q", test.retryAfter, header, test.header)
+		}
+	}
+}
+
+func TestLimitByIP(t *testing.T) {
+	s := NewServer().WithRateLimits(RateLimits{
+		Register: conlangdev.RateLimit{Requests: 2, Window: time.Minute},
+	})
+	handler := s.router.GetHandler()
+
+	tests := []struct {
+		remoteAddr string
+		status     int
+	}{
+		{"192.0.2.1:1234", 400},
+		{"192.0.2.1:1235", 400},
+		{"192.0.2.1:1236", 429},
+		{"192.0.2.2:1234", 400},
+	}
+	for i, test := range tests {
+		// A malformed body is refused once the request gets past the limit
+		r := httptest.NewRequest("POST", "/auth/register", nil)
+		r.RemoteAddr = test.remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("request %d from %s: got status %d, want %d", i+1, test.remoteAddr, w.Code, test.status)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerRevisionRoutes() {
+	s.router.Prefix("/word/{username}/{language}/{word}/history", func(history *Router) {
+		history.Handle(s.handleIndexRevision).GET("")
+		history.Handle(s.handleDiffRevision).GET("/diff")
+		history.Handle(s.handleViewRevision).GET("/{revision}")
+		history.Scoped(s.handleRestoreRevision).POST("/{revision}/restore")
+	})
+}
+
+// Parses a revision number, returning a bad request error naming the given
+// parameter if it is not valid.
+func parseRevision(value string, param string) (int, error) {
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision < 1 {
+		return 0, &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "invalid revision for " + param,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return revision, nil
+}
+
+// Finds the revision given by the `word` and `revision` route parameters
+// in the given language.
+func (s *Server) findRevisionFromParams(r *http.Request, language *conlangdev.Language) (*conlangdev.WordRevision, error) {
+	wordUID, err := wordUIDFromParams(r)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := parseRevision(mux.Vars(r)["revision"], "revision")
+	if err != nil {
+		return nil, err
+	}
+	return s.WordRevisionService.GetRevisionForWord(r.Context(), language, wordUID, revision)
+}
+
+func (s *Server) handleIndexRevision(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	wordUID, err := wordUIDFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	revisions, err := s.WordRevisionService.FindRevisionsForWord(r.Context(), language, wordUID)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.WordRevision{
+		"revisions": revisions,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleViewRevision(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	revision, err := s.findRevisionFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.WordRevision{
+		"revision": revision,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleDiffRevision(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	wordUID, err := wordUIDFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	fromRevision, err := parseRevision(query.Get("from"), "from")
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	toRevision, err := parseRevision(query.Get("to"), "to")
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	from, err := s.WordRevisionService.GetRevisionForWord(r.Context(), language, wordUID, fromRevision)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	to, err := s.WordRevisionService.GetRevisionForWord(r.Context(), language, wordUID, toRevision)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"from":  from,
+		"to":    to,
+		"diffs": conlangdev.DiffWordRevisions(from, to),
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleRestoreRevision(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	revision, err := s.findRevisionFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	// Words which have been deleted have nothing left to overwrite, so
+	// only words which still exist need the If-Match header
+	word, err := s.findWordFromParams(r, language)
+	if cd_err, ok := err.(*conlangdev.Error); ok && cd_err.Code == conlangdev.ENOTFOUND {
+		word = nil
+	} else if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	} else if err := checkIfMatch(r, wordETag(word)); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	word, err = s.WordRevisionService.RestoreRevision(r.Context(), language, word, revision)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("ETag", wordETag(word))
+
+	response, err := json.Marshal(map[string]*conlangdev.Word{
+		"word": word,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
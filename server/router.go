@@ -16,6 +16,8 @@ type RouteBuilder struct {
 	handlerFunc           func(http.ResponseWriter, *http.Request)
 	authorizedHandlerFunc func(http.ResponseWriter, *http.Request, *conlangdev.User)
 	requireGuest          bool
+	allowAPIToken         bool
+	middleware            []mux.MiddlewareFunc
 }
 
 func NewRouter() *Router {
@@ -40,34 +42,56 @@ func (r *Router) HandleNotFound(f func(http.ResponseWriter, *http.Request)) {
 }
 
 func (r *Router) Handle(f func(http.ResponseWriter, *http.Request)) *RouteBuilder {
-	return &RouteBuilder{r, f, nil, false}
+	return &RouteBuilder{r, f, nil, false, true, nil}
 }
 
 func (r *Router) Guest(f func(http.ResponseWriter, *http.Request)) *RouteBuilder {
-	return &RouteBuilder{r, f, nil, true}
+	return &RouteBuilder{r, f, nil, true, false, nil}
 }
 
+// Routes which need an authenticated user. These cannot be used with an API
+// token, see `Router.Scoped()`.
 func (r *Router) Authorized(f func(http.ResponseWriter, *http.Request, *conlangdev.User)) *RouteBuilder {
-	return &RouteBuilder{r, nil, f, false}
+	return &RouteBuilder{r, nil, f, false, false, nil}
 }
 
-func (r *RouteBuilder) buildAuthorized(path string) *mux.Route {
-	return r.router.router.HandleFunc(
-		path,
+// Routes which need an authenticated user and act on a single language,
+// which can also be used with an API token. The handler must check the
+// action with `Server.authorize()`, which applies the scopes of the token.
+func (r *Router) Scoped(f func(http.ResponseWriter, *http.Request, *conlangdev.User)) *RouteBuilder {
+	return &RouteBuilder{r, nil, f, false, true, nil}
+}
+
+// Adds middleware to just this route, which runs after the middleware of
+// the router and before any checks made by the route itself.
+func (r *RouteBuilder) Use(mwf ...mux.MiddlewareFunc) *RouteBuilder {
+	r.middleware = append(r.middleware, mwf...)
+	return r
+}
+
+func (r *RouteBuilder) buildAuthorized() http.Handler {
+	return http.HandlerFunc(
 		func(w http.ResponseWriter, rq *http.Request) {
 			user := conlangdev.GetUserFromContext(rq.Context())
 			if user == nil {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
+			if !r.allowAPIToken && conlangdev.GetAPITokenFromContext(rq.Context()) != nil {
+				handleError(&conlangdev.Error{
+					Code:       conlangdev.EUNAUTHORIZED,
+					Message:    "this cannot be done with an API token",
+					StatusCode: http.StatusForbidden,
+				}).ServeHTTP(w, rq)
+				return
+			}
 			r.authorizedHandlerFunc(w, rq, user)
 		},
 	)
 }
 
-func (r *RouteBuilder) buildGuest(path string) *mux.Route {
-	return r.router.router.HandleFunc(
-		path,
+func (r *RouteBuilder) buildGuest() http.Handler {
+	return http.HandlerFunc(
 		func(w http.ResponseWriter, rq *http.Request) {
 			user := conlangdev.GetUserFromContext(rq.Context())
 			if user != nil {
@@ -79,17 +103,20 @@ func (r *RouteBuilder) buildGuest(path string) *mux.Route {
 	)
 }
 
-func (r *RouteBuilder) buildGeneric(path string) *mux.Route {
-	return r.router.router.HandleFunc(path, r.handlerFunc)
-}
-
 func (r *RouteBuilder) build(path string) *mux.Route {
+	var handler http.Handler
 	if r.authorizedHandlerFunc != nil {
-		return r.buildAuthorized(path)
+		handler = r.buildAuthorized()
 	} else if r.requireGuest {
-		return r.buildGuest(path)
+		handler = r.buildGuest()
+	} else {
+		handler = http.HandlerFunc(r.handlerFunc)
+	}
+	// Wrap in reverse so that middleware runs in the order it was added
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
 	}
-	return r.buildGeneric(path)
+	return r.router.router.Handle(path, handler)
 }
 
 func (r *RouteBuilder) GET(path string) *mux.Route {
@@ -99,3 +126,11 @@ func (r *RouteBuilder) GET(path string) *mux.Route {
 func (r *RouteBuilder) POST(path string) *mux.Route {
 	return r.build(path).Methods("POST")
 }
+
+func (r *RouteBuilder) PATCH(path string) *mux.Route {
+	return r.build(path).Methods("PATCH")
+}
+
+func (r *RouteBuilder) DELETE(path string) *mux.Route {
+	return r.build(path).Methods("DELETE")
+}
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/conlangdev/conlangdev"
+	"github.com/conlangdev/conlangdev/ratelimit"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,28 +20,79 @@ type Server struct {
 	router   *Router
 
 	Addr string
+	// The address of the web app, which links in mail sent to users point
+	// to.
+	PublicURL string
+	Mailer    conlangdev.Mailer
 
-	UserService     conlangdev.UserService
-	LanguageService conlangdev.LanguageService
-	WordService     conlangdev.WordService
+	UserService              conlangdev.UserService
+	SessionService           conlangdev.SessionService
+	PasswordResetService     conlangdev.PasswordResetService
+	LanguageService          conlangdev.LanguageService
+	WordService              conlangdev.WordService
+	CollaboratorService      conlangdev.CollaboratorService
+	AuthorizationService     conlangdev.AuthorizationService
+	TeamService              conlangdev.TeamService
+	WordRevisionService      conlangdev.WordRevisionService
+	TrashService             conlangdev.TrashService
+	BranchService            conlangdev.BranchService
+	FieldDefinitionService   conlangdev.FieldDefinitionService
+	VocabularyService        conlangdev.VocabularyService
+	ConceptService           conlangdev.ConceptService
+	StatsService             conlangdev.StatsService
+	EmailVerificationService conlangdev.EmailVerificationService
+	APITokenService          conlangdev.APITokenService
+	OIDCService              conlangdev.OIDCService
+	TwoFactorService         conlangdev.TwoFactorService
+	AdminService             conlangdev.AdminService
+	// Identity providers users can sign in with, by name.
+	OIDCProviders map[string]conlangdev.OIDCProvider
+	// Redirect URIs clients may have identity providers send users back
+	// to, besides the web app's own callback page. Any `{provider}` is
+	// replaced with the name of the provider.
+	OIDCRedirectURIs []string
+
+	// Limits on requests, counted in the store, which is kept in memory
+	// unless another is given.
+	RateLimits     RateLimits
+	RateLimitStore conlangdev.RateLimitStore
 }
 
 func NewServer() *Server {
 	// Set up server object with base routers
 	server := &Server{
-		server: &http.Server{},
-		router: NewRouter(),
+		server:         &http.Server{},
+		router:         NewRouter(),
+		OIDCProviders:  make(map[string]conlangdev.OIDCProvider),
+		RateLimits:     DefaultRateLimits,
+		RateLimitStore: ratelimit.NewMemoryStore(),
 	}
 
 	// Add middleware
 	server.router.Use(contentTypeJSON)
 	server.router.Use(logger)
 	server.router.Use(server.authenticate)
+	server.router.Use(server.limitByAccount)
 
 	// Register routes
 	server.registerUserRoutes()
+	server.registerAPITokenRoutes()
+	server.registerOIDCRoutes()
+	server.registerTwoFactorRoutes()
 	server.registerLanguageRoutes()
 	server.registerWordRoutes()
+	server.registerExportRoutes()
+	server.registerCollaboratorRoutes()
+	server.registerTeamRoutes()
+	server.registerRevisionRoutes()
+	server.registerTrashRoutes()
+	server.registerBranchRoutes()
+	server.registerBatchRoutes()
+	server.registerFieldRoutes()
+	server.registerVocabularyRoutes()
+	server.registerConceptRoutes()
+	server.registerStatsRoutes()
+	server.registerAdminRoutes()
 
 	// Allocate handler to our router and return server
 	server.server.Handler = server.router.GetHandler()
@@ -124,17 +177,36 @@ func handleError(err error) http.Handler {
 
 // Middleware function which validates any user authentication in the
 // request and places the authenticated user into the context, if any.
+// Requests may be authenticated with either an access token or an API
+// token, which is placed into the context as well.
 //
 // This middleware does *not* specify whether a user must or must not
 // be authenticated to continue. For that, see the route-building
-// functions `Router.Authorized()` and `Router.Guest()`
+// functions `Router.Authorized()` and `Router.Guest()`. Requests from
+// suspended users are refused outright, whatever they are for.
 func (s *Server) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
-			jwt := strings.TrimPrefix(h, "Bearer ")
-			user, err := s.UserService.GetUserByJWT(r.Context(), jwt)
-			if err == nil && user != nil {
-				r = r.WithContext(conlangdev.NewContextWithUser(r.Context(), user))
+			bearer := strings.TrimPrefix(h, "Bearer ")
+			if strings.HasPrefix(bearer, conlangdev.APITokenPrefix) {
+				token, user, err := s.APITokenService.GetAPITokenByToken(r.Context(), bearer)
+				if err == nil && user != nil {
+					ctx := conlangdev.NewContextWithUser(r.Context(), user)
+					r = r.WithContext(conlangdev.NewContextWithAPIToken(ctx, token))
+				}
+			} else {
+				session, user, err := s.SessionService.GetSessionByAccessToken(r.Context(), bearer)
+				if err == nil && user != nil {
+					ctx := conlangdev.NewContextWithUser(r.Context(), user)
+					r = r.WithContext(conlangdev.NewContextWithSession(ctx, session))
+				}
+			}
+		}
+
+		if user := conlangdev.GetUserFromContext(r.Context()); user != nil {
+			if err := checkNotSuspended(user); err != nil {
+				handleError(err).ServeHTTP(w, r)
+				return
 			}
 		}
 
@@ -165,11 +237,85 @@ func (s *Server) WithAddr(addr string) *Server {
 	return s
 }
 
+func (s *Server) WithPublicURL(publicURL string) *Server {
+	s.PublicURL = strings.TrimSuffix(publicURL, "/")
+	return s
+}
+
+func (s *Server) WithMailer(m conlangdev.Mailer) *Server {
+	s.Mailer = m
+	return s
+}
+
+// Gives a link to a page of the web app with a single query parameter, or
+// just the value of the parameter if there is no public URL to link to.
+func (s *Server) publicLink(path string, key string, value string) string {
+	if s.PublicURL == "" {
+		return value
+	}
+	return s.PublicURL + path + "?" + url.Values{key: {value}}.Encode()
+}
+
 func (s *Server) WithUserService(us conlangdev.UserService) *Server {
 	s.UserService = us
 	return s
 }
 
+func (s *Server) WithSessionService(ss conlangdev.SessionService) *Server {
+	s.SessionService = ss
+	return s
+}
+
+func (s *Server) WithPasswordResetService(prs conlangdev.PasswordResetService) *Server {
+	s.PasswordResetService = prs
+	return s
+}
+
+func (s *Server) WithEmailVerificationService(evs conlangdev.EmailVerificationService) *Server {
+	s.EmailVerificationService = evs
+	return s
+}
+
+func (s *Server) WithAPITokenService(ats conlangdev.APITokenService) *Server {
+	s.APITokenService = ats
+	return s
+}
+
+func (s *Server) WithOIDCService(os conlangdev.OIDCService) *Server {
+	s.OIDCService = os
+	return s
+}
+
+func (s *Server) WithTwoFactorService(tfs conlangdev.TwoFactorService) *Server {
+	s.TwoFactorService = tfs
+	return s
+}
+
+func (s *Server) WithAdminService(as conlangdev.AdminService) *Server {
+	s.AdminService = as
+	return s
+}
+
+func (s *Server) WithRateLimits(limits RateLimits) *Server {
+	s.RateLimits = limits
+	return s
+}
+
+func (s *Server) WithRateLimitStore(store conlangdev.RateLimitStore) *Server {
+	s.RateLimitStore = store
+	return s
+}
+
+func (s *Server) WithOIDCProvider(provider conlangdev.OIDCProvider) *Server {
+	s.OIDCProviders[provider.Name()] = provider
+	return s
+}
+
+func (s *Server) WithOIDCRedirectURIs(uris []string) *Server {
+	s.OIDCRedirectURIs = uris
+	return s
+}
+
 func (s *Server) WithLanguageService(ls conlangdev.LanguageService) *Server {
 	s.LanguageService = ls
 	return s
@@ -179,3 +325,53 @@ func (s *Server) WithWordService(ws conlangdev.WordService) *Server {
 	s.WordService = ws
 	return s
 }
+
+func (s *Server) WithCollaboratorService(cs conlangdev.CollaboratorService) *Server {
+	s.CollaboratorService = cs
+	return s
+}
+
+func (s *Server) WithAuthorizationService(as conlangdev.AuthorizationService) *Server {
+	s.AuthorizationService = as
+	return s
+}
+
+func (s *Server) WithTeamService(ts conlangdev.TeamService) *Server {
+	s.TeamService = ts
+	return s
+}
+
+func (s *Server) WithWordRevisionService(wrs conlangdev.WordRevisionService) *Server {
+	s.WordRevisionService = wrs
+	return s
+}
+
+func (s *Server) WithTrashService(ts conlangdev.TrashService) *Server {
+	s.TrashService = ts
+	return s
+}
+
+func (s *Server) WithBranchService(bs conlangdev.BranchService) *Server {
+	s.BranchService = bs
+	return s
+}
+
+func (s *Server) WithFieldDefinitionService(fs conlangdev.FieldDefinitionService) *Server {
+	s.FieldDefinitionService = fs
+	return s
+}
+
+func (s *Server) WithVocabularyService(vs conlangdev.VocabularyService) *Server {
+	s.VocabularyService = vs
+	return s
+}
+
+func (s *Server) WithConceptService(cs conlangdev.ConceptService) *Server {
+	s.ConceptService = cs
+	return s
+}
+
+func (s *Server) WithStatsService(ss conlangdev.StatsService) *Server {
+	s.StatsService = ss
+	return s
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+// Gives the address a request came from, without its port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func sessionCreateFromRequest(r *http.Request) conlangdev.SessionCreate {
+	return conlangdev.SessionCreate{
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r),
+	}
+}
+
+// Writes the tokens of a new or refreshed session, along with its user.
+func writeAuthentication(w http.ResponseWriter, r *http.Request, tokens *conlangdev.SessionTokens, user *conlangdev.User) {
+	response, err := json.Marshal(map[string]interface{}{
+		"authentication": struct {
+			*conlangdev.SessionTokens
+			User *conlangdev.User `json:"user"`
+		}{tokens, user},
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Swaps a refresh token for a new access token and refresh token. This
+// works without an access token, since the old one has usually expired.
+func (s *Server) handleRefreshSession(w http.ResponseWriter, r *http.Request) {
+	var refreshPayload struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&refreshPayload); err != nil || refreshPayload.RefreshToken == "" {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	tokens, err := s.SessionService.RefreshSession(r.Context(), refreshPayload.RefreshToken, sessionCreateFromRequest(r))
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	user, err := s.UserService.GetUserByID(r.Context(), tokens.Session.UserID)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeAuthentication(w, r, tokens, user)
+}
+
+// Ends the session the request was made with.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	if session := conlangdev.GetSessionFromContext(r.Context()); session != nil {
+		if err := s.SessionService.RevokeSession(r.Context(), session); err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Ends every session of the user, including the one the request was made
+// with.
+func (s *Server) handleLogoutAll(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	if err := s.SessionService.RevokeSessionsForUser(r.Context(), user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Changes the password of the user. This ends every session, so a new
+// session is started for the client making the change.
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	var passwordPayload struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&passwordPayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.UserService.CheckUserPassword(r.Context(), user, passwordPayload.CurrentPassword); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "incorrect password",
+			StatusCode: http.StatusUnauthorized,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.UserService.UpdateUserPassword(r.Context(), user, passwordPayload.NewPassword); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	tokens, err := s.SessionService.CreateSession(r.Context(), user, sessionCreateFromRequest(r))
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeAuthentication(w, r, tokens, user)
+}
+
+func (s *Server) handleIndexSession(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	sessions, err := s.SessionService.FindSessionsForUser(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if current := conlangdev.GetSessionFromContext(r.Context()); current != nil {
+		for _, session := range sessions {
+			session.Current = session.ID == current.ID
+		}
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.Session{
+		"sessions": sessions,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	id, err := strconv.ParseUint(mux.Vars(r)["session"], 10, 32)
+	if err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "invalid session ID",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	session, err := s.SessionService.GetSessionForUser(r.Context(), user, uint(id))
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.SessionService.RevokeSession(r.Context(), session); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sends a password reset token to the given email address if it belongs to
+// a user. The response is the same either way, so that it cannot be used to
+// find out who has an account.
+func (s *Server) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var forgotPayload struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&forgotPayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	user, token, err := s.PasswordResetService.CreatePasswordReset(r.Context(), forgotPayload.Email)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if user != nil {
+		if err := s.Mailer.Send(r.Context(), &conlangdev.Mail{
+			To:      user.Email,
+			Subject: "Reset your conlangdev password",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nSomeone asked to reset the password for your account. "+
+					"If it was you, use this to choose a new password within the next %d minutes:\n\n%s\n\n"+
+					"If it wasn't you, you can ignore this email.\n",
+				user.Username, int(conlangdev.PasswordResetLifetime.Minutes()),
+				s.publicLink("/reset-password", "token", token),
+			),
+		}); err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Sets a new password using a token sent by handleForgotPassword.
+func (s *Server) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var resetPayload struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resetPayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if _, err := s.PasswordResetService.ResetPassword(r.Context(), resetPayload.Token, resetPayload.Password); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (s *Server) registerStatsRoutes() {
+	s.router.Prefix("/language/{username}/{language}/stats", func(stats *Router) {
+		stats.Handle(s.handleViewStats).GET("")
+	})
+}
+
+// Reports statistics on the words of a language. The words of a branch can
+// be looked at by giving its name as `branch`.
+func (s *Server) handleViewStats(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	stats, err := s.StatsService.GetStatsForLanguage(r.Context(), language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"language": language,
+		"stats":    stats,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
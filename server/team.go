@@ -0,0 +1,337 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerTeamRoutes() {
+	s.router.Prefix("/team", func(team *Router) {
+		team.Authorized(s.handleIndexTeam).GET("")
+		team.Authorized(s.handleCreateTeam).POST("")
+		team.Handle(s.handleViewTeam).GET("/{team}")
+		team.Authorized(s.handleUpdateTeam).PATCH("/{team}")
+		team.Authorized(s.handleDeleteTeam).DELETE("/{team}")
+		team.Handle(s.handleIndexTeamMember).GET("/{team}/members")
+		team.Authorized(s.handleAddTeamMember).POST("/{team}/members")
+		team.Authorized(s.handleUpdateTeamMember).PATCH("/{team}/members/{member}")
+		team.Authorized(s.handleRemoveTeamMember).DELETE("/{team}/members/{member}")
+	})
+}
+
+// Finds the team given by the `team` route parameter.
+func (s *Server) findTeamFromParams(r *http.Request) (*conlangdev.Team, error) {
+	return s.TeamService.GetTeamBySlug(r.Context(), mux.Vars(r)["team"])
+}
+
+// Finds the member given by the `member` route parameter on the given team.
+func (s *Server) findTeamMemberFromParams(r *http.Request, team *conlangdev.Team) (*conlangdev.TeamMember, error) {
+	user, err := s.UserService.GetUserByUsername(r.Context(), mux.Vars(r)["member"])
+	if err != nil {
+		return nil, err
+	}
+	return s.TeamService.GetMemberForTeam(r.Context(), team, user)
+}
+
+// Checks that the user holds at least the given role within a team.
+func (s *Server) authorizeTeam(r *http.Request, team *conlangdev.Team, user *conlangdev.User, role string) error {
+	member, err := s.TeamService.GetMemberForTeam(r.Context(), team, user)
+	if err != nil {
+		if cd_err, ok := err.(*conlangdev.Error); ok && cd_err.Code == conlangdev.ENOTFOUND {
+			return &conlangdev.Error{
+				Code:       conlangdev.EUNAUTHORIZED,
+				Message:    "you are not a member of that team",
+				StatusCode: http.StatusForbidden,
+			}
+		}
+		return err
+	}
+	if conlangdev.TeamRoleRank(member.Role) < conlangdev.TeamRoleRank(role) {
+		return &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "you do not have permission to do that",
+			StatusCode: http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleIndexTeam(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	teams, err := s.TeamService.FindTeamsForUser(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.Team{
+		"teams": teams,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleCreateTeam(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	var create conlangdev.TeamCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	team, err := s.TeamService.CreateTeam(r.Context(), user, create)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Team{
+		"team": team,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+// Shows a team along with the languages owned by it which the requesting
+// user may see.
+func (s *Server) handleViewTeam(w http.ResponseWriter, r *http.Request) {
+	team, err := s.findTeamFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	languages, err := s.LanguageService.FindLanguagesForTeam(r.Context(), team)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	visible := make([]*conlangdev.Language, 0, len(languages))
+	for _, language := range languages {
+		if err := s.authorize(r, language, conlangdev.ActionViewLanguage); err == nil {
+			visible = append(visible, language)
+		}
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"team":      team,
+		"languages": visible,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleUpdateTeam(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	team, err := s.findTeamFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorizeTeam(r, team, user, conlangdev.TeamRoleAdmin); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var update conlangdev.TeamUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.TeamService.UpdateTeam(r.Context(), team, update); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Team{
+		"team": team,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleDeleteTeam(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	team, err := s.findTeamFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorizeTeam(r, team, user, conlangdev.TeamRoleOwner); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.TeamService.DeleteTeam(r.Context(), team); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleIndexTeamMember(w http.ResponseWriter, r *http.Request) {
+	team, err := s.findTeamFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	members, err := s.TeamService.FindMembersForTeam(r.Context(), team)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.TeamMember{
+		"members": members,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleAddTeamMember(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	team, err := s.findTeamFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var create conlangdev.TeamMemberCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	// Only owners may make other owners
+	role := conlangdev.TeamRoleAdmin
+	if create.Role == conlangdev.TeamRoleOwner {
+		role = conlangdev.TeamRoleOwner
+	}
+	if err := s.authorizeTeam(r, team, user, role); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	member, err := s.TeamService.AddMember(r.Context(), team, create)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.TeamMember{
+		"member": member,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleUpdateTeamMember(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	team, err := s.findTeamFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	member, err := s.findTeamMemberFromParams(r, team)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var update conlangdev.TeamMemberUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	role := conlangdev.TeamRoleAdmin
+	if update.Role == conlangdev.TeamRoleOwner || member.Role == conlangdev.TeamRoleOwner {
+		role = conlangdev.TeamRoleOwner
+	}
+	if err := s.authorizeTeam(r, team, user, role); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.TeamService.UpdateMember(r.Context(), member, update); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.TeamMember{
+		"member": member,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleRemoveTeamMember(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	team, err := s.findTeamFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	member, err := s.findTeamMemberFromParams(r, team)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	// Members may always leave a team themselves
+	if member.UserID != user.ID {
+		role := conlangdev.TeamRoleAdmin
+		if member.Role == conlangdev.TeamRoleOwner {
+			role = conlangdev.TeamRoleOwner
+		}
+		if err := s.authorizeTeam(r, team, user, role); err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+	}
+
+	if err := s.TeamService.RemoveMember(r.Context(), member); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerTrashRoutes() {
+	s.router.Prefix("/language", func(language *Router) {
+		language.Authorized(s.handleIndexTrashedLanguage).GET("/trash")
+		language.Authorized(s.handleRestoreLanguage).POST("/{username}/{language}/restore")
+		language.Scoped(s.handleIndexTrashedWord).GET("/{username}/{language}/trash")
+		language.Scoped(s.handleRestoreWord).POST("/{username}/{language}/trash/{word}/restore")
+	})
+	s.router.Prefix("/team", func(team *Router) {
+		team.Authorized(s.handleIndexTeamTrash).GET("/{team}/trash")
+	})
+}
+
+func (s *Server) handleIndexTrashedLanguage(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	languages, err := s.TrashService.FindTrashedLanguagesForUser(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.TrashedLanguage{
+		"languages": languages,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleIndexTeamTrash(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	team, err := s.findTeamFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	// Only those who could have deleted a team's languages may see them
+	if err := s.authorizeTeam(r, team, user, conlangdev.TeamRoleOwner); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	languages, err := s.TrashService.FindTrashedLanguagesForTeam(r.Context(), team)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"team":      team,
+		"languages": languages,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleRestoreLanguage(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	params := mux.Vars(r)
+	trashed, err := s.TrashService.GetTrashedLanguageByNamespaceAndSlug(r.Context(), params["username"], params["language"])
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, trashed.Language, conlangdev.ActionDeleteLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.TrashService.RestoreLanguage(r.Context(), trashed.Language); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Language{
+		"language": trashed.Language,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleIndexTrashedWord(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	words, err := s.TrashService.FindTrashedWordsForLanguage(r.Context(), language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"language": language,
+		"words":    words,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleRestoreWord(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	wordUID, err := wordUIDFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	trashed, err := s.TrashService.GetTrashedWordByLanguageAndUID(r.Context(), language, wordUID)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.TrashService.RestoreWord(r.Context(), trashed.Word); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.Word{
+		"word": trashed.Word,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+func (s *Server) registerTwoFactorRoutes() {
+	s.router.Prefix("/auth/2fa", func(twoFactor *Router) {
+		twoFactor.Authorized(s.handleSetupTwoFactor).POST("/setup")
+		twoFactor.Authorized(s.handleEnableTwoFactor).POST("/enable")
+		twoFactor.Authorized(s.handleDisableTwoFactor).POST("/disable")
+		twoFactor.Authorized(s.handleRegenerateRecoveryCodes).POST("/recovery-codes")
+	})
+}
+
+// Starts a session for a user who has given their password or signed in
+// with an identity provider. Users with two-factor authentication get a
+// challenge to finish with handleCompleteTwoFactorLogin instead.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	if err := checkNotSuspended(user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if user.TwoFactorEnabled() {
+		challenge, err := s.TwoFactorService.CreateTwoFactorChallenge(r.Context(), user)
+		if err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+
+		response, err := json.Marshal(map[string]interface{}{
+			"authentication": map[string]interface{}{
+				"two_factor_required": true,
+				"challenge":           challenge,
+			},
+		})
+		if err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
+
+		w.Write(response)
+		return
+	}
+
+	tokens, err := s.SessionService.CreateSession(r.Context(), user, sessionCreateFromRequest(r))
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeAuthentication(w, r, tokens, user)
+}
+
+// Decodes a request body holding just a two-factor code.
+func decodeCodePayload(r *http.Request) (string, error) {
+	var codePayload struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&codePayload); err != nil {
+		return "", &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return codePayload.Code, nil
+}
+
+func writeRecoveryCodes(w http.ResponseWriter, r *http.Request, codes []string) {
+	response, err := json.Marshal(map[string][]string{
+		"recovery_codes": codes,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Finishes logging in with a code from an authenticator app or a recovery
+// code, given along with the challenge from the first step.
+func (s *Server) handleCompleteTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	var challengePayload struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&challengePayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	user, err := s.TwoFactorService.CompleteTwoFactorChallenge(r.Context(), challengePayload.Challenge, challengePayload.Code)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	tokens, err := s.SessionService.CreateSession(r.Context(), user, sessionCreateFromRequest(r))
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeAuthentication(w, r, tokens, user)
+}
+
+func (s *Server) handleSetupTwoFactor(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	setup, err := s.TwoFactorService.SetupTwoFactor(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.TwoFactorSetup{
+		"two_factor": setup,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.Write(response)
+}
+
+// Turns on two-factor authentication with a code from the app which was
+// just set up, giving the recovery codes. These are only shown this once.
+func (s *Server) handleEnableTwoFactor(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	code, err := decodeCodePayload(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	codes, err := s.TwoFactorService.EnableTwoFactor(r.Context(), user, code)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeRecoveryCodes(w, r, codes)
+}
+
+// Turns off two-factor authentication, which needs both the password of
+// the user, if they have one, and a code.
+func (s *Server) handleDisableTwoFactor(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	var disablePayload struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&disablePayload); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	// Users who only sign in with an identity provider have no password
+	if user.PasswordHash != "" {
+		if err := s.UserService.CheckUserPassword(r.Context(), user, disablePayload.Password); err != nil {
+			handleError(&conlangdev.Error{
+				Code:       conlangdev.EUNAUTHORIZED,
+				Message:    "incorrect password",
+				StatusCode: http.StatusUnauthorized,
+			}).ServeHTTP(w, r)
+			return
+		}
+	}
+	if !user.TwoFactorEnabled() {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "two-factor authentication is not enabled",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+	if err := s.TwoFactorService.CheckTwoFactorCode(r.Context(), user, disablePayload.Code); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.TwoFactorService.DisableTwoFactor(r.Context(), user); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	code, err := decodeCodePayload(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.TwoFactorService.CheckTwoFactorCode(r.Context(), user, code); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	codes, err := s.TwoFactorService.RegenerateRecoveryCodes(r.Context(), user)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	writeRecoveryCodes(w, r, codes)
+}
@@ -6,16 +6,34 @@ import (
 
 	"github.com/conlangdev/conlangdev"
 	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
 )
 
 func (s *Server) registerUserRoutes() {
 	s.router.Prefix("/user", func(user *Router) {
+		user.Authorized(s.handleUpdateUser).PATCH("")
+		user.Authorized(s.handleDeleteUser).DELETE("")
 		user.Handle(s.handleViewUser).GET("/{username}")
+		user.Authorized(s.handleExportUser).GET("/{username}/export")
+		user.Authorized(s.handleViewUserDeletion).GET("/{username}/deletion")
+		user.Authorized(s.handleRestoreUser).POST("/{username}/restore")
 	})
 	s.router.Prefix("/auth", func(auth *Router) {
 		auth.Handle(s.handleCheckAuth).GET("")
-		auth.Guest(s.handleLoginUser).POST("/login")
-		auth.Guest(s.handleRegisterUser).POST("/register")
+		auth.Guest(s.handleLoginUser).Use(s.limitByIP("login", &s.RateLimits.Login)).POST("/login")
+		auth.Guest(s.handleCompleteTwoFactorLogin).Use(s.limitByIP("login", &s.RateLimits.Login)).POST("/login/2fa")
+		auth.Guest(s.handleRegisterUser).Use(s.limitByIP("register", &s.RateLimits.Register)).POST("/register")
+		auth.Handle(s.handleRefreshSession).Use(s.limitByIP("login", &s.RateLimits.Login)).POST("/refresh")
+		auth.Authorized(s.handleLogout).POST("/logout")
+		auth.Authorized(s.handleLogoutAll).POST("/logout/all")
+		auth.Authorized(s.handleChangePassword).POST("/password")
+		auth.Handle(s.handleForgotPassword).Use(s.limitByIP("login", &s.RateLimits.Login)).POST("/password/forgot")
+		auth.Handle(s.handleResetPassword).Use(s.limitByIP("login", &s.RateLimits.Login)).POST("/password/reset")
+		auth.Handle(s.handleVerifyEmail).POST("/email/verify")
+		auth.Authorized(s.handleResendVerification).POST("/email/resend")
+		auth.Authorized(s.handleChangeEmail).POST("/email/change")
+		auth.Authorized(s.handleIndexSession).GET("/sessions")
+		auth.Authorized(s.handleDeleteSession).DELETE("/sessions/{session}")
 	})
 }
 
@@ -67,6 +85,10 @@ func (s *Server) handleRegisterUser(w http.ResponseWriter, r *http.Request) {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
+	// The account exists either way, and the link can be sent again
+	if err := s.sendVerificationMail(r, user, user.Email); err != nil {
+		log.WithField("user", user.Username).Errorf("could not send verification email: %v", err)
+	}
 
 	response, err := json.Marshal(map[string]*conlangdev.User{
 		"user": user,
@@ -92,17 +114,31 @@ func (s *Server) handleLoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := s.UserService.GetUserByUsername(r.Context(), loginPayload.Username)
-	if err != nil {
-		handleError(&conlangdev.Error{
-			Code:       conlangdev.EUNAUTHORIZED,
-			Message:    "incorrect username or password",
-			StatusCode: http.StatusUnauthorized,
-		}).ServeHTTP(w, r)
+	// Locked out accounts are refused before their password is checked, so
+	// that guessing costs nothing
+	if retryAfter, err := s.checkLoginLockout(r.Context(), loginPayload.Username); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	} else if retryAfter > 0 {
+		handleRateLimited(retryAfter).ServeHTTP(w, r)
 		return
 	}
 
-	if err := s.UserService.CheckUserPassword(r.Context(), user, loginPayload.Password); err != nil {
+	// Only unknown usernames and wrong passwords count towards a lockout,
+	// so that an outage does not lock everyone out
+	user, err := s.UserService.GetUserByUsername(r.Context(), loginPayload.Username)
+	if cd_err, ok := err.(*conlangdev.Error); err != nil && !(ok && cd_err.Code == conlangdev.ENOTFOUND) {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err == nil {
+		err = s.UserService.CheckUserPassword(r.Context(), user, loginPayload.Password)
+	}
+	if err != nil {
+		if err := s.recordLoginFailure(r.Context(), loginPayload.Username); err != nil {
+			handleError(err).ServeHTTP(w, r)
+			return
+		}
 		handleError(&conlangdev.Error{
 			Code:       conlangdev.EUNAUTHORIZED,
 			Message:    "incorrect username or password",
@@ -110,25 +146,12 @@ func (s *Server) handleLoginUser(w http.ResponseWriter, r *http.Request) {
 		}).ServeHTTP(w, r)
 		return
 	}
-
-	jwt, err := s.UserService.GenerateJWTForUser(r.Context(), user)
-	if err != nil {
+	if err := s.clearLoginFailures(r.Context(), loginPayload.Username); err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
 
-	response, err := json.Marshal(map[string]interface{}{
-		"authentication": map[string]interface{}{
-			"jwt":  jwt,
-			"user": user,
-		},
-	})
-	if err != nil {
-		handleError(err).ServeHTTP(w, r)
-		return
-	}
-
-	w.Write(response)
+	s.startSession(w, r, user)
 }
 
 func (s *Server) handleViewUser(w http.ResponseWriter, r *http.Request) {
@@ -138,14 +161,24 @@ func (s *Server) handleViewUser(w http.ResponseWriter, r *http.Request) {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
+	// Accounts being deleted are hidden as though they were already gone,
+	// as are suspended accounts
+	if user.DeletedAt != nil || user.Suspended() {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that user",
+			StatusCode: http.StatusNotFound,
+		}).ServeHTTP(w, r)
+		return
+	}
 
-	userView, err := s.UserService.GetViewForUser(r.Context(), user)
+	profile, err := s.UserService.GetProfileForUser(r.Context(), user)
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
 
-	response, err := json.Marshal(userView)
+	response, err := json.Marshal(profile)
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
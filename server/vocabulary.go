@@ -0,0 +1,206 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) registerVocabularyRoutes() {
+	s.router.Prefix("/language/{username}/{language}/vocabulary", func(vocabulary *Router) {
+		vocabulary.Handle(s.handleIndexVocabulary).GET("")
+		vocabulary.Authorized(s.handleCreateVocabularyTerm).POST("")
+		vocabulary.Scoped(s.handleNormalizeVocabulary).POST("/normalize")
+		vocabulary.Authorized(s.handleUpdateVocabularyTerm).PATCH("/{term}")
+		vocabulary.Authorized(s.handleDeleteVocabularyTerm).DELETE("/{term}")
+	})
+}
+
+// Finds the term given by the `term` route parameter in the given language.
+func (s *Server) findVocabularyTermFromParams(r *http.Request, language *conlangdev.Language) (*conlangdev.VocabularyTerm, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["term"], 10, 32)
+	if err != nil {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "invalid term ID",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return s.VocabularyService.GetTermByID(r.Context(), language, uint(id))
+}
+
+func (s *Server) handleIndexVocabulary(w http.ResponseWriter, r *http.Request) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	terms, err := s.VocabularyService.FindTermsForLanguage(r.Context(), language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string][]*conlangdev.VocabularyTerm{
+		"terms": terms,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleCreateVocabularyTerm(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var create conlangdev.VocabularyTermCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	term, err := s.VocabularyService.CreateTerm(r.Context(), language, create)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.VocabularyTerm{
+		"term": term,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleUpdateVocabularyTerm(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	term, err := s.findVocabularyTermFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var update conlangdev.VocabularyTermUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.VocabularyService.UpdateTerm(r.Context(), term, update); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]*conlangdev.VocabularyTerm{
+		"term": term,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
+
+func (s *Server) handleDeleteVocabularyTerm(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionManageLanguage); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	term, err := s.findVocabularyTermFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.VocabularyService.DeleteTerm(r.Context(), term); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Maps the variants found on the words of a language onto its terms. The
+// words of a branch can be normalised by giving its name as `branch`.
+func (s *Server) handleNormalizeVocabulary(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var normalize conlangdev.VocabularyNormalize
+	if err := json.NewDecoder(r.Body).Decode(&normalize); err != nil && err != io.EOF {
+		handleError(&conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "malformed request body",
+			StatusCode: http.StatusBadRequest,
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	result, err := s.VocabularyService.NormalizeWords(r.Context(), language, normalize)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"dry_run":   normalize.DryRun,
+		"normalize": result,
+	})
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	w.Write(response)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/conlangdev/conlangdev"
 	"github.com/gorilla/mux"
@@ -12,39 +13,39 @@ import (
 func (s *Server) registerWordRoutes() {
 	s.router.Prefix("/word/{username}/{language}", func(word *Router) {
 		word.Handle(s.handleIndexWord).GET("")
-		word.Authorized(s.handleCreateWord).POST("")
+		word.Scoped(s.handleCreateWord).POST("")
 		word.Handle(s.handleViewWord).GET("/{word}")
+		word.Scoped(s.handleUpdateWord).PATCH("/{word}")
+		word.Scoped(s.handleDeleteWord).DELETE("/{word}")
 	})
 }
 
 func (s *Server) handleIndexWord(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	userx, err := s.UserService.GetUserByUsername(r.Context(), params["username"])
+	language, err := s.findLanguageFromParams(r)
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
-
-	language, err := s.LanguageService.GetLanguageByUserAndSlug(r.Context(), userx, params["language"])
+	r, err = s.withBranchFromQuery(r, language)
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
 
-	words, err := s.WordService.FindWordsForLanguage(r.Context(), language)
+	words, err := s.WordService.FindWordsForLanguage(r.Context(), language, wordFilterFromQuery(r))
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
 
-	userView, err := s.UserService.GetViewForUser(r.Context(), userx)
+	ownerKind, ownerView, err := s.getOwnerViewForLanguage(r, language)
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
 
 	response, err := json.Marshal(map[string]interface{}{
-		"user":     userView,
+		ownerKind:  ownerView,
 		"language": language,
 		"words":    words,
 	})
@@ -57,23 +58,17 @@ func (s *Server) handleIndexWord(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleCreateWord(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
-	params := mux.Vars(r)
-	userx, err := s.UserService.GetUserByUsername(r.Context(), params["username"])
+	language, err := s.findLanguageFromParams(r)
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
-	if user.ID != userx.ID {
-		handleError(&conlangdev.Error{
-			Code:       conlangdev.EUNAUTHORIZED,
-			Message:    "you must be the owner of a language to add words",
-			StatusCode: http.StatusForbidden,
-		}).ServeHTTP(w, r)
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
 		return
 	}
-
-	language, err := s.LanguageService.GetLanguageByUserAndSlug(r.Context(), userx, params["language"])
-	if err != nil {
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
@@ -105,35 +100,120 @@ func (s *Server) handleCreateWord(w http.ResponseWriter, r *http.Request, user *
 	w.Write(response)
 }
 
+// Reads a word filter from the query parameters. Words can be filtered by
+// `part_of_speech`, `tag` and `concept`, and by custom fields with
+// `field.<name>`.
+func wordFilterFromQuery(r *http.Request) conlangdev.WordFilter {
+	query := r.URL.Query()
+	filter := conlangdev.WordFilter{
+		PartOfSpeech: query.Get("part_of_speech"),
+		Tag:          query.Get("tag"),
+		Concept:      query.Get("concept"),
+		CustomFields: make(map[string]string),
+	}
+	for key, values := range query {
+		if strings.HasPrefix(key, "field.") && len(values) > 0 {
+			filter.CustomFields[strings.TrimPrefix(key, "field.")] = values[0]
+		}
+	}
+	return filter
+}
+
+// Parses the word UID given by the `word` route parameter.
+func wordUIDFromParams(r *http.Request) (uint64, error) {
+	wordUID, err := strconv.ParseUint(mux.Vars(r)["word"], 10, 64)
+	if err != nil {
+		return 0, &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "invalid word ID",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return wordUID, nil
+}
+
+// Finds the word given by the `word` route parameter in the given language.
+func (s *Server) findWordFromParams(r *http.Request, language *conlangdev.Language) (*conlangdev.Word, error) {
+	wordUID, err := wordUIDFromParams(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.WordService.GetWordByLanguageAndUID(r.Context(), language, wordUID)
+}
+
 func (s *Server) handleViewWord(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	userx, err := s.UserService.GetUserByUsername(r.Context(), params["username"])
+	language, err := s.findLanguageFromParams(r)
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	word, err := s.findWordFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if notModified(w, r, wordETag(word)) {
+		return
+	}
 
-	language, err := s.LanguageService.GetLanguageByUserAndSlug(r.Context(), userx, params["language"])
+	response, err := json.Marshal(map[string]*conlangdev.Word{
+		"word": word,
+	})
 	if err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
 
-	wordUID, err := strconv.ParseUint(params["word"], 10, 64)
+	w.Write(response)
+}
+
+func (s *Server) handleUpdateWord(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
 	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	word, err := s.findWordFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := checkIfMatch(r, wordETag(word)); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	var update conlangdev.WordUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
 		handleError(&conlangdev.Error{
 			Code:       conlangdev.EBADREQUEST,
-			Message:    "invalid word ID",
+			Message:    "malformed request body",
 			StatusCode: http.StatusBadRequest,
 		}).ServeHTTP(w, r)
 		return
 	}
 
-	word, err := s.WordService.GetWordByLanguageAndUID(r.Context(), language, wordUID)
-	if err != nil {
+	if err := s.WordService.UpdateWord(r.Context(), word, update); err != nil {
 		handleError(err).ServeHTTP(w, r)
 		return
 	}
+	w.Header().Set("ETag", wordETag(word))
 
 	response, err := json.Marshal(map[string]*conlangdev.Word{
 		"word": word,
@@ -145,3 +225,37 @@ func (s *Server) handleViewWord(w http.ResponseWriter, r *http.Request) {
 
 	w.Write(response)
 }
+
+func (s *Server) handleDeleteWord(w http.ResponseWriter, r *http.Request, user *conlangdev.User) {
+	language, err := s.findLanguageFromParams(r)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	r, err = s.withBranchFromQuery(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := s.authorize(r, language, conlangdev.ActionEditWords); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	word, err := s.findWordFromParams(r, language)
+	if err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+	if err := checkIfMatch(r, wordETag(word)); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	if err := s.WordService.DeleteWord(r.Context(), word); err != nil {
+		handleError(err).ServeHTTP(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,64 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+// How long access tokens and refresh tokens last. Refresh tokens are
+// replaced with a new one each time they are used, which also extends the
+// session they belong to.
+const (
+	AccessTokenLifetime  = 15 * time.Minute
+	RefreshTokenLifetime = 30 * 24 * time.Hour
+)
+
+// A login on a device. Every access token is issued for a session and
+// stops working as soon as the session is revoked, whether by logging out
+// or by changing the password of the user.
+type Session struct {
+	ID         uint       `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UserID     uint       `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Whether this is the session of the request it was loaded for.
+	Current bool `json:"current"`
+}
+
+// Details of the client a session is being created or refreshed for.
+type SessionCreate struct {
+	UserAgent string
+	IPAddress string
+}
+
+// The tokens handed out when logging in or refreshing a session. The
+// refresh token is only ever given out here, as only a hash of it is kept.
+type SessionTokens struct {
+	AccessToken           string    `json:"jwt"`
+	AccessTokenExpiresAt  time.Time `json:"jwt_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+	Session               *Session  `json:"session"`
+}
+
+type SessionService interface {
+	CreateSession(ctx context.Context, user *User, create SessionCreate) (*SessionTokens, error)
+	// Swaps a refresh token for new tokens. Using a refresh token which
+	// has already been swapped revokes the session, as it may have been
+	// stolen.
+	RefreshSession(ctx context.Context, refreshToken string, create SessionCreate) (*SessionTokens, error)
+	// Gives the session an access token was issued for and its user, as
+	// long as the session is still active.
+	GetSessionByAccessToken(ctx context.Context, accessToken string) (*Session, *User, error)
+	FindSessionsForUser(ctx context.Context, user *User) ([]*Session, error)
+	GetSessionForUser(ctx context.Context, user *User, id uint) (*Session, error)
+	RevokeSession(ctx context.Context, session *Session) error
+	RevokeSessionsForUser(ctx context.Context, user *User) error
+	// Deletes sessions which have expired or been revoked, giving how many
+	// were deleted.
+	PurgeSessions(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,256 @@
+package sql
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AdminService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+func NewAdminService(db *DB, validate *validator.Validate) *AdminService {
+	return &AdminService{db, validate}
+}
+
+// Escapes the wildcards of a LIKE pattern so that it matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *AdminService) FindUsers(ctx context.Context, filter conlangdev.UserFilter) ([]*conlangdev.User, error) {
+	if err := validateStruct(s.validate, &filter); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE 1 = 1`
+	var args []interface{}
+	if filter.Query != "" {
+		query += ` AND (username LIKE ? OR email LIKE ?)`
+		pattern := likeEscaper.Replace(filter.Query) + "%"
+		args = append(args, pattern, pattern)
+	}
+	if filter.Role != "" {
+		query += ` AND role = ?`
+		args = append(args, filter.Role)
+	}
+	if filter.Suspended {
+		query += ` AND suspended_at IS NOT NULL`
+	}
+	query += ` ORDER BY username`
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*conlangdev.User, 0)
+	for rows.Next() {
+		var user conlangdev.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Runs an update on a single user and reads them back.
+func (s *AdminService) updateUser(ctx context.Context, user *conlangdev.User, query string, args ...interface{}) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, append(args, user.ID)...); err != nil {
+		return err
+	}
+	current, err := getUserByID(ctx, tx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	*user = *current
+	return nil
+}
+
+// Creates a user with their role and verified email address in one go, so
+// that a failure part way through does not leave behind a user without
+// them.
+func (s *AdminService) CreateUser(ctx context.Context, create conlangdev.UserCreate, role string, verified bool) (*conlangdev.User, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, err
+	}
+	if err := s.validate.Var(role, "oneof=user admin"); err != nil {
+		return nil, &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "validation failed",
+			StatusCode: http.StatusBadRequest,
+			Fields:     []string{"Role"},
+		}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(create.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := insertUser(ctx, tx, create, string(hash), role, verified)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *AdminService) SetUserRole(ctx context.Context, user *conlangdev.User, role string) error {
+	if err := s.validate.Var(role, "oneof=user admin"); err != nil {
+		return &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "validation failed",
+			StatusCode: http.StatusBadRequest,
+			Fields:     []string{"Role"},
+		}
+	}
+	if role == conlangdev.UserRoleAdmin && user.Suspended() {
+		return &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "suspended users cannot be made administrators",
+			StatusCode: http.StatusConflict,
+		}
+	}
+	return s.updateUser(ctx, user,
+		`UPDATE users SET updated_at = NOW(), role = ? WHERE id = ?`,
+		role,
+	)
+}
+
+func (s *AdminService) SuspendUser(ctx context.Context, user *conlangdev.User, reason string) error {
+	if user.IsAdmin() {
+		return &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "administrators cannot be suspended",
+			StatusCode: http.StatusConflict,
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET
+			updated_at = NOW(),
+			suspended_at = COALESCE(suspended_at, NOW()),
+			suspension_reason = ?
+		WHERE id = ?`,
+		reason, user.ID,
+	); err != nil {
+		return err
+	}
+	// API tokens are kept, as they stop working while the user is
+	// suspended anyway and can be used again if they are unsuspended
+	if err := revokeSessionsForUser(ctx, tx, user.ID); err != nil {
+		return err
+	}
+	current, err := getUserByID(ctx, tx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	*user = *current
+	return nil
+}
+
+func (s *AdminService) UnsuspendUser(ctx context.Context, user *conlangdev.User) error {
+	return s.updateUser(ctx, user,
+		`UPDATE users SET
+			updated_at = NOW(), suspended_at = NULL, suspension_reason = ''
+		WHERE id = ?`,
+	)
+}
+
+func (s *AdminService) HideUserProfile(ctx context.Context, user *conlangdev.User) error {
+	return s.updateUser(ctx, user,
+		`UPDATE users SET profile_hidden_at = COALESCE(profile_hidden_at, NOW())
+		WHERE id = ?`,
+	)
+}
+
+func (s *AdminService) ShowUserProfile(ctx context.Context, user *conlangdev.User) error {
+	return s.updateUser(ctx, user,
+		`UPDATE users SET profile_hidden_at = NULL WHERE id = ?`,
+	)
+}
+
+// Runs an update on a single language and reads it back. The version is
+// left alone, as hiding a language does not change what is in it.
+func (s *AdminService) updateLanguage(ctx context.Context, language *conlangdev.Language, query string, args ...interface{}) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, append(args, language.ID)...); err != nil {
+		return err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE id = ? LIMIT 1`,
+		language.ID,
+	)
+	if err := scanLanguage(row, language); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *AdminService) HideLanguage(ctx context.Context, language *conlangdev.Language, reason string) error {
+	return s.updateLanguage(ctx, language,
+		`UPDATE languages SET
+			hidden_at = COALESCE(hidden_at, NOW()), hidden_reason = ?
+		WHERE id = ?`,
+		reason,
+	)
+}
+
+func (s *AdminService) ShowLanguage(ctx context.Context, language *conlangdev.Language) error {
+	return s.updateLanguage(ctx, language,
+		`UPDATE languages SET hidden_at = NULL, hidden_reason = '' WHERE id = ?`,
+	)
+}
@@ -0,0 +1,261 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+)
+
+type APITokenService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+func NewAPITokenService(db *DB, validate *validator.Validate) *APITokenService {
+	return &APITokenService{db, validate}
+}
+
+// Column list matching the order expected by scanAPIToken.
+const apiTokenColumns = `id, created_at, last_used_at, expires_at, user_id, name`
+
+func scanAPIToken(row rowScanner, token *conlangdev.APIToken) error {
+	return row.Scan(
+		&token.ID, &token.CreatedAt, &token.LastUsedAt, &token.ExpiresAt,
+		&token.UserID, &token.Name,
+	)
+}
+
+// Loads the scopes of an API token into it.
+func loadAPITokenScopes(ctx context.Context, tx *sql.Tx, token *conlangdev.APIToken) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT language_id, access FROM api_token_scopes
+		WHERE api_token_id = ? ORDER BY language_id`,
+		token.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	token.Scopes = make([]*conlangdev.APITokenScope, 0)
+	for rows.Next() {
+		var scope conlangdev.APITokenScope
+		if err := rows.Scan(&scope.LanguageID, &scope.Access); err != nil {
+			return err
+		}
+		token.Scopes = append(token.Scopes, &scope)
+	}
+	return rows.Err()
+}
+
+func (s *APITokenService) CreateAPIToken(ctx context.Context, user *conlangdev.User, create conlangdev.APITokenCreate) (*conlangdev.APIToken, string, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, "", err
+	}
+	if create.ExpiresAt != nil && !create.ExpiresAt.After(time.Now()) {
+		return nil, "", &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "the expiry date must be in the future",
+			StatusCode: http.StatusBadRequest,
+			Fields:     []string{"ExpiresAt"},
+		}
+	}
+	seen := make(map[uint]bool)
+	for _, scope := range create.Scopes {
+		if seen[scope.LanguageID] {
+			return nil, "", &conlangdev.FieldsError{
+				Code:       conlangdev.EVALIDFAIL,
+				Message:    "each language can only be given one scope",
+				StatusCode: http.StatusBadRequest,
+				Fields:     []string{"Scopes"},
+			}
+		}
+		seen[scope.LanguageID] = true
+	}
+
+	secret, err := generateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	secret = conlangdev.APITokenPrefix + secret
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	var token conlangdev.APIToken
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO api_tokens (
+			created_at, expires_at, user_id, name, token_hash
+		) VALUES (NOW(), ?, ?, ?, ?)
+		RETURNING `+apiTokenColumns,
+		create.ExpiresAt, user.ID, create.Name, hashToken(secret),
+	)
+	if err := scanAPIToken(row, &token); err != nil {
+		return nil, "", err
+	}
+	for _, scope := range create.Scopes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO api_token_scopes (api_token_id, language_id, access)
+			VALUES (?, ?, ?)`,
+			token.ID, scope.LanguageID, scope.Access,
+		); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := loadAPITokenScopes(ctx, tx, &token); err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+
+	return &token, secret, nil
+}
+
+func (s *APITokenService) GetAPITokenByToken(ctx context.Context, secret string) (*conlangdev.APIToken, *conlangdev.User, error) {
+	if !strings.HasPrefix(secret, conlangdev.APITokenPrefix) {
+		return nil, nil, errors.New("not an API token")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var token conlangdev.APIToken
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE token_hash = ? AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > NOW())
+		LIMIT 1`,
+		hashToken(secret),
+	)
+	if err := scanAPIToken(row, &token); err != nil {
+		return nil, nil, errors.New("API token could not be found")
+	}
+	if err := loadAPITokenScopes(ctx, tx, &token); err != nil {
+		return nil, nil, err
+	}
+	user, err := getUserByID(ctx, tx, token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE api_tokens SET last_used_at = NOW() WHERE id = ?`,
+		token.ID,
+	); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return &token, user, nil
+}
+
+// Lists the usable API tokens of a user, newest first.
+func (s *APITokenService) FindAPITokensForUser(ctx context.Context, user *conlangdev.User) ([]*conlangdev.APIToken, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE user_id = ? AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC, id DESC`,
+		user.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*conlangdev.APIToken, 0)
+	for rows.Next() {
+		var token conlangdev.APIToken
+		if err := scanAPIToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, token := range tokens {
+		if err := loadAPITokenScopes(ctx, tx, token); err != nil {
+			return nil, err
+		}
+	}
+
+	return tokens, nil
+}
+
+func (s *APITokenService) GetAPITokenForUser(ctx context.Context, user *conlangdev.User, id uint) (*conlangdev.APIToken, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var token conlangdev.APIToken
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > NOW())
+		LIMIT 1`,
+		id, user.ID,
+	)
+	if err := scanAPIToken(row, &token); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that API token",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if err := loadAPITokenScopes(ctx, tx, &token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (s *APITokenService) RevokeAPIToken(ctx context.Context, token *conlangdev.APIToken) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE api_tokens SET revoked_at = NOW()
+		WHERE id = ? AND revoked_at IS NULL`,
+		token.ID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+type AuthorizationService struct {
+	db *DB
+}
+
+func NewAuthorizationService(db *DB) *AuthorizationService {
+	return &AuthorizationService{db}
+}
+
+func (s *AuthorizationService) GetRoleForUser(ctx context.Context, user *conlangdev.User, language *conlangdev.Language) (string, error) {
+	if user == nil {
+		return "", nil
+	}
+	if language.UserID != 0 && user.ID == language.UserID {
+		return conlangdev.RoleOwner, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Invites only grant a role once they have been accepted
+	var role string
+	if err := tx.QueryRowContext(ctx,
+		`SELECT role FROM language_collaborators
+		WHERE language_id = ? AND user_id = ? AND accepted_at IS NOT NULL
+		LIMIT 1`,
+		language.ID, user.ID,
+	).Scan(&role); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	// Members of an owning team get a role from their team role, unless
+	// they have been given a better one as a collaborator
+	if language.TeamID != 0 {
+		var teamRole string
+		if err := tx.QueryRowContext(ctx,
+			`SELECT role FROM team_members
+			WHERE team_id = ? AND user_id = ?
+			LIMIT 1`,
+			language.TeamID, user.ID,
+		).Scan(&teamRole); err != nil && err != sql.ErrNoRows {
+			return "", err
+		}
+		if teamLanguageRole := conlangdev.LanguageRoleForTeamRole(teamRole); conlangdev.RoleRank(teamLanguageRole) > conlangdev.RoleRank(role) {
+			role = teamLanguageRole
+		}
+	}
+
+	return role, nil
+}
+
+func (s *AuthorizationService) Authorize(ctx context.Context, user *conlangdev.User, language *conlangdev.Language, action string) error {
+	role, err := s.GetRoleForUser(ctx, user, language)
+	if err != nil {
+		return err
+	}
+
+	// Anyone may view public and unlisted languages, unless an
+	// administrator has hidden them
+	if role == "" && language.Visibility != conlangdev.VisibilityPrivate && !language.Hidden() {
+		role = conlangdev.RoleViewer
+		if user == nil && action != conlangdev.ActionViewLanguage {
+			return &conlangdev.Error{
+				Code:       conlangdev.EUNAUTHORIZED,
+				Message:    "you must be logged in to do that",
+				StatusCode: http.StatusUnauthorized,
+			}
+		}
+	}
+
+	if role == "" {
+		return &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that language",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	if conlangdev.RoleRank(role) < conlangdev.RoleRank(conlangdev.RoleForAction(action)) {
+		return &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "you do not have permission to do that",
+			StatusCode: http.StatusForbidden,
+		}
+	}
+
+	return nil
+}
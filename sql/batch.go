@@ -0,0 +1,189 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+// Applies a batch of word operations in one transaction, stopping at the
+// first operation which fails. Failures caused by the operations
+// themselves are reported in the result, while any other error aborts the
+// batch outright.
+func (s *WordService) ApplyWordBatch(ctx context.Context, language *conlangdev.Language, batch conlangdev.WordBatch) (*conlangdev.WordBatchResult, error) {
+	if err := validateStruct(s.validate, &batch); err != nil {
+		return nil, err
+	}
+	branchID, err := branchIDForLanguage(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &conlangdev.WordBatchResult{
+		Results: make([]*conlangdev.WordBatchOperationResult, 0, len(batch.Operations)),
+	}
+	for i, op := range batch.Operations {
+		opResult := &conlangdev.WordBatchOperationResult{Index: i}
+		if op != nil {
+			opResult.Op = op.Op
+		}
+		result.Results = append(result.Results, opResult)
+
+		if err := s.applyWordBatchOperation(ctx, tx, language, branchID, op, opResult); err != nil {
+			switch err.(type) {
+			case *conlangdev.Error, *conlangdev.FieldsError:
+				opResult.Error = err
+				return result, nil
+			}
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.Committed = true
+
+	return result, nil
+}
+
+func (s *WordService) applyWordBatchOperation(ctx context.Context, tx *sql.Tx, language *conlangdev.Language, branchID interface{}, op *conlangdev.WordBatchOperation, result *conlangdev.WordBatchOperationResult) error {
+	if op == nil {
+		return &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "operation must not be empty",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if err := validateStruct(s.validate, op); err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case conlangdev.BatchCreate:
+		word, err := createWord(ctx, tx, language, branchID, *op.Create)
+		if err != nil {
+			return err
+		}
+		result.UID = word.UID
+		result.Words = []*conlangdev.Word{word}
+		result.Affected = 1
+
+	case conlangdev.BatchUpdate:
+		word, err := getBatchWord(ctx, tx, language, branchID, op)
+		if err != nil {
+			return err
+		}
+		if err := updateWord(ctx, tx, word, *op.Update); err != nil {
+			return err
+		}
+		result.UID = word.UID
+		result.Words = []*conlangdev.Word{word}
+		result.Affected = 1
+
+	case conlangdev.BatchDelete:
+		word, err := getBatchWord(ctx, tx, language, branchID, op)
+		if err != nil {
+			return err
+		}
+		if err := deleteWord(ctx, tx, word); err != nil {
+			return err
+		}
+		result.UID = word.UID
+		result.Affected = 1
+
+	case conlangdev.BatchUpdateWhere:
+		words, err := findMatchingWords(ctx, tx, language, branchID, op.Where)
+		if err != nil {
+			return err
+		}
+		for _, word := range words {
+			if err := updateWord(ctx, tx, word, *op.Update); err != nil {
+				return err
+			}
+		}
+		result.Words = words
+		result.Affected = len(words)
+	}
+
+	return nil
+}
+
+// Loads the word named by an update or delete operation, checking it is
+// still at the version the operation was based on if one was given.
+func getBatchWord(ctx context.Context, tx *sql.Tx, language *conlangdev.Language, branchID interface{}, op *conlangdev.WordBatchOperation) (*conlangdev.Word, error) {
+	word, err := getWordByUID(ctx, tx, language, branchID, op.UID)
+	if err != nil {
+		return nil, err
+	}
+	if op.Version != 0 && op.Version != word.Version {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EPRECONDITIONFAILED,
+			Message:    "that word has been changed since it was loaded",
+			StatusCode: http.StatusPreconditionFailed,
+		}
+	}
+	return word, nil
+}
+
+// Finds the words matching every field given, locking them until the
+// transaction ends. At least one field must be given so that a batch
+// cannot update an entire language by accident.
+func findMatchingWords(ctx context.Context, tx *sql.Tx, language *conlangdev.Language, branchID interface{}, match *conlangdev.WordMatch) ([]*conlangdev.Word, error) {
+	query := `SELECT ` + wordColumns + `
+		FROM words WHERE language_id = ? AND branch_id <=> ? AND deleted_at IS NULL`
+	args := []interface{}{language.ID, branchID}
+	conditions := []struct {
+		column string
+		value  *string
+	}{
+		{"headword", match.Headword},
+		{"part_of_speech", match.PartOfSpeech},
+		{"grammar_class", match.GrammarClass},
+		{"gender", match.Gender},
+	}
+	matched := false
+	for _, condition := range conditions {
+		if condition.value != nil {
+			query += ` AND ` + condition.column + ` = ?`
+			args = append(args, *condition.value)
+			matched = true
+		}
+	}
+	if !matched {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "update_where must match on at least one field",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	query += ` ORDER BY headword, uid FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := make([]*conlangdev.Word, 0)
+	for rows.Next() {
+		var word conlangdev.Word
+		if err := scanWord(rows, &word); err != nil {
+			return nil, err
+		}
+		words = append(words, &word)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
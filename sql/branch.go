@@ -0,0 +1,892 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+)
+
+type BranchService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+// Column list matching the order expected by scanSnapshot. Queries using
+// it must select from language_snapshots as `s`.
+const snapshotColumns = `s.id, s.created_at, s.language_id, s.name,
+	s.description, COALESCE(s.author_id, 0), s.author_username,
+	(SELECT COUNT(*) FROM snapshot_words sw WHERE sw.snapshot_id = s.id)`
+
+// Column list matching the order expected by scanBranch.
+const branchColumns = `id, created_at, updated_at, language_id, name,
+	snapshot_id, COALESCE(author_id, 0), author_username, merged_at`
+
+func NewBranchService(db *DB, validate *validator.Validate) *BranchService {
+	return &BranchService{db, validate}
+}
+
+func scanSnapshot(row rowScanner, snapshot *conlangdev.Snapshot) error {
+	return row.Scan(
+		&snapshot.ID, &snapshot.CreatedAt, &snapshot.LanguageID, &snapshot.Name,
+		&snapshot.Description, &snapshot.AuthorID, &snapshot.AuthorUsername,
+		&snapshot.WordCount,
+	)
+}
+
+func scanBranch(row rowScanner, branch *conlangdev.Branch) error {
+	return row.Scan(
+		&branch.ID, &branch.CreatedAt, &branch.UpdatedAt, &branch.LanguageID,
+		&branch.Name, &branch.SnapshotID, &branch.AuthorID,
+		&branch.AuthorUsername, &branch.MergedAt,
+	)
+}
+
+func (s *BranchService) FindSnapshotsForLanguage(ctx context.Context, language *conlangdev.Language) ([]*conlangdev.Snapshot, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+snapshotColumns+`
+		FROM language_snapshots s WHERE s.language_id = ?
+		ORDER BY s.created_at DESC, s.id DESC`,
+		language.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := make([]*conlangdev.Snapshot, 0)
+	for rows.Next() {
+		var snapshot conlangdev.Snapshot
+		if err := scanSnapshot(rows, &snapshot); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+func (s *BranchService) GetSnapshotByName(ctx context.Context, language *conlangdev.Language, name string) (*conlangdev.Snapshot, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return getSnapshot(ctx, tx, `s.language_id = ? AND s.name = ?`, language.ID, name)
+}
+
+func getSnapshot(ctx context.Context, tx *sql.Tx, condition string, args ...interface{}) (*conlangdev.Snapshot, error) {
+	var snapshot conlangdev.Snapshot
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+snapshotColumns+`
+		FROM language_snapshots s WHERE `+condition+` LIMIT 1`,
+		args...,
+	)
+	if err := scanSnapshot(row, &snapshot); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that snapshot",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// Lists the words in a snapshot as they were when it was taken. The words
+// have the UIDs of the main words they were copied from, but no IDs.
+func (s *BranchService) ListWordsForSnapshot(ctx context.Context, snapshot *conlangdev.Snapshot) ([]*conlangdev.Word, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return querySnapshotWords(ctx, tx, snapshot)
+}
+
+func querySnapshotWords(ctx context.Context, tx *sql.Tx, snapshot *conlangdev.Snapshot) ([]*conlangdev.Word, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT
+			word_uid, headword, part_of_speech, definition, pronunciation,
+			grammar_class, gender, etymology, notes, tags, custom_fields, concepts
+		FROM snapshot_words WHERE snapshot_id = ?
+		ORDER BY headword, word_uid`,
+		snapshot.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := make([]*conlangdev.Word, 0)
+	for rows.Next() {
+		word := conlangdev.Word{
+			CreatedAt:  snapshot.CreatedAt,
+			UpdatedAt:  snapshot.CreatedAt,
+			LanguageID: snapshot.LanguageID,
+		}
+		var tags, customFields, concepts []byte
+		if err := rows.Scan(
+			&word.UID, &word.Headword, &word.PartOfSpeech, &word.Definition,
+			&word.Pronunciation, &word.GrammarClass, &word.Gender,
+			&word.Etymology, &word.Notes, &tags, &customFields, &concepts,
+		); err != nil {
+			return nil, err
+		}
+		if word.Tags, err = decodeTags(tags); err != nil {
+			return nil, err
+		}
+		if word.CustomFields, err = decodeCustomFields(customFields); err != nil {
+			return nil, err
+		}
+		if word.Concepts, err = decodeTags(concepts); err != nil {
+			return nil, err
+		}
+		words = append(words, &word)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
+
+// Takes a snapshot of the main line of a language.
+func (s *BranchService) CreateSnapshot(ctx context.Context, language *conlangdev.Language, create conlangdev.SnapshotCreate) (*conlangdev.Snapshot, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	authorID, authorUsername := authorFromContext(ctx)
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO language_snapshots (
+			created_at, language_id, name, description, author_id, author_username
+		) VALUES (NOW(), ?, ?, ?, ?, ?)`,
+		language.ID, create.Name, create.Description, authorID, authorUsername,
+	)
+	if err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "there is already a snapshot with that name",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO snapshot_words (
+			snapshot_id, word_uid, headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes, tags,
+			custom_fields, concepts
+		) SELECT
+			?, uid, headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes, tags,
+			custom_fields, concepts
+		FROM words
+		WHERE language_id = ? AND branch_id IS NULL AND deleted_at IS NULL`,
+		id, language.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	snapshot, err := getSnapshot(ctx, tx, `s.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Deletes a snapshot, as long as no branch was started from it.
+func (s *BranchService) DeleteSnapshot(ctx context.Context, snapshot *conlangdev.Snapshot) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var branches int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM language_branches WHERE snapshot_id = ?`,
+		snapshot.ID,
+	).Scan(&branches); err != nil {
+		return err
+	}
+	if branches > 0 {
+		return &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "branches were started from that snapshot, delete them first",
+			StatusCode: http.StatusConflict,
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM language_snapshots WHERE id = ?`,
+		snapshot.ID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *BranchService) FindBranchesForLanguage(ctx context.Context, language *conlangdev.Language) ([]*conlangdev.Branch, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+branchColumns+`
+		FROM language_branches WHERE language_id = ?
+		ORDER BY created_at DESC, id DESC`,
+		language.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	branches := make([]*conlangdev.Branch, 0)
+	for rows.Next() {
+		var branch conlangdev.Branch
+		if err := scanBranch(rows, &branch); err != nil {
+			return nil, err
+		}
+		branches = append(branches, &branch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return branches, nil
+}
+
+func (s *BranchService) GetBranchByName(ctx context.Context, language *conlangdev.Language, name string) (*conlangdev.Branch, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var branch conlangdev.Branch
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+branchColumns+`
+		FROM language_branches WHERE language_id = ? AND name = ?
+		LIMIT 1`,
+		language.ID, name,
+	)
+	if err := scanBranch(row, &branch); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that branch",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &branch, nil
+}
+
+// Starts a branch from a snapshot, copying the words in the snapshot onto
+// the branch with new UIDs.
+func (s *BranchService) CreateBranch(ctx context.Context, language *conlangdev.Language, create conlangdev.BranchCreate) (*conlangdev.Branch, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	snapshot, err := getSnapshot(ctx, tx, `s.language_id = ? AND s.name = ?`, language.ID, create.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	authorID, authorUsername := authorFromContext(ctx)
+	branch := &conlangdev.Branch{}
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO language_branches (
+			created_at, updated_at, language_id, name, snapshot_id,
+			author_id, author_username
+		) VALUES (
+			NOW(), NOW(), ?, ?, ?, ?, ?
+		) RETURNING `+branchColumns,
+		language.ID, create.Name, snapshot.ID, authorID, authorUsername,
+	)
+	if err := scanBranch(row, branch); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "there is already a branch with that name",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO words (
+			created_at, updated_at, headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes, tags,
+			custom_fields, concepts, language_id, branch_id, origin_uid
+		) SELECT
+			NOW(), NOW(), headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes, tags,
+			custom_fields, concepts, ?, ?, word_uid
+		FROM snapshot_words WHERE snapshot_id = ?`,
+		language.ID, branch.ID, snapshot.ID,
+	); err != nil {
+		return nil, err
+	}
+	// Word references point at the copies on the branch
+	if err := remapWordReferences(ctx, tx, language.ID, branch.ID,
+		`t.branch_id = ?`, "origin_uid", "uid", branch.ID,
+	); err != nil {
+		return nil, err
+	}
+	if err := insertInitialWordRevisions(ctx, tx, `branch_id = ?`, branch.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return branch, nil
+}
+
+// Deletes a branch along with its words and their history.
+func (s *BranchService) DeleteBranch(ctx context.Context, branch *conlangdev.Branch) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE r FROM word_revisions r
+		INNER JOIN words w ON w.uid = r.word_uid
+		WHERE w.branch_id = ?`,
+		branch.ID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM language_branches WHERE id = ?`,
+		branch.ID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Runs a query selecting wordColumns and maps the resulting words by UID,
+// also returning them in order.
+func queryWordsByUID(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]*conlangdev.Word, map[uint64]*conlangdev.Word, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	words := make([]*conlangdev.Word, 0)
+	byUID := make(map[uint64]*conlangdev.Word)
+	for rows.Next() {
+		var word conlangdev.Word
+		if err := scanWord(rows, &word); err != nil {
+			return nil, nil, err
+		}
+		words = append(words, &word)
+		byUID[word.UID] = &word
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return words, byUID, nil
+}
+
+// Replaces the word references in the custom fields of a word using the
+// given map of UIDs, leaving any UIDs not in the map as they are.
+func translateWordReferences(word *conlangdev.Word, fields []*conlangdev.FieldDefinition, uids map[uint64]uint64) {
+	for _, field := range fields {
+		if field.Kind != conlangdev.FieldKindWord {
+			continue
+		}
+		value, ok := word.CustomFields[field.Name]
+		if !ok {
+			continue
+		}
+		var uid uint64
+		if err := json.Unmarshal(value, &uid); err != nil {
+			continue
+		}
+		if translated, ok := uids[uid]; ok {
+			word.CustomFields[field.Name] = json.RawMessage(strconv.FormatUint(translated, 10))
+		}
+	}
+}
+
+// Whether two words have the same value in every field.
+func sameWordFields(a *conlangdev.Word, b *conlangdev.Word) bool {
+	aValues, bValues := mergeValues(a), mergeValues(b)
+	if len(aValues) != len(bValues) {
+		return false
+	}
+	for name, value := range aValues {
+		if bValues[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Gives the values of a word which a merge works with, by name: each text
+// field, the tags and concepts as a whole and each custom field that is
+// set, with tags, concepts and custom fields encoded as JSON.
+func mergeValues(word *conlangdev.Word) map[string]string {
+	values := make(map[string]string)
+	for _, field := range word.Fields() {
+		values[field.Name] = *field.Value
+	}
+	values["tags"] = encodeTags(word.Tags)
+	values["concepts"] = encodeTags(word.Concepts)
+	for name, value := range word.CustomFields {
+		encoded, _ := json.Marshal(value)
+		values["custom_fields."+name] = string(encoded)
+	}
+	return values
+}
+
+// Lists the names of the values of all the given words, with the text
+// fields first in their usual order and then the rest sorted.
+func mergeValueNames(values ...map[string]string) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, field := range (&conlangdev.Word{}).Fields() {
+		names = append(names, field.Name)
+		seen[field.Name] = true
+	}
+	rest := make([]string, 0)
+	for _, wordValues := range values {
+		for name := range wordValues {
+			if !seen[name] {
+				rest = append(rest, name)
+				seen[name] = true
+			}
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// Sets a value worked out by a merge on a word. An empty custom field
+// value is one which none of the sides have, or which has been removed.
+func setMergeValue(word *conlangdev.Word, name string, value string) {
+	if name == "tags" {
+		if tags, err := decodeTags([]byte(value)); err == nil {
+			word.Tags = tags
+		}
+		return
+	}
+	if name == "concepts" {
+		if concepts, err := decodeTags([]byte(value)); err == nil {
+			word.Concepts = concepts
+		}
+		return
+	}
+	if strings.HasPrefix(name, "custom_fields.") {
+		field := strings.TrimPrefix(name, "custom_fields.")
+		if value == "" {
+			delete(word.CustomFields, field)
+		} else {
+			word.CustomFields[field] = json.RawMessage(value)
+		}
+		return
+	}
+	for _, field := range word.Fields() {
+		if field.Name == name {
+			*field.Value = value
+		}
+	}
+}
+
+// A change to the main line worked out while merging, to be applied once
+// the whole merge is known to succeed.
+type mergeChange struct {
+	action string
+	word   *conlangdev.Word
+	// UID of the main word changed, which for added words is filled in
+	// once they exist unless an old UID is being brought back.
+	uid      uint64
+	reported *conlangdev.MergeChange
+}
+
+// Merges a branch into the main line three ways, using the snapshot the
+// branch was started from as the common ancestor.
+//
+// Each word changed only on one side takes that side's changes, and words
+// changed on both sides are merged field by field. Fields changed to
+// different values on both sides, and words deleted on one side but
+// changed on the other, are conflicts. Unless the merge says which side
+// should win, any conflict stops the merge without changing anything.
+func (s *BranchService) MergeBranch(ctx context.Context, branch *conlangdev.Branch, merge conlangdev.BranchMerge) (*conlangdev.MergeResult, error) {
+	if err := validateStruct(s.validate, &merge); err != nil {
+		return nil, err
+	}
+	if branch.MergedAt != nil {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "that branch has already been merged",
+			StatusCode: http.StatusConflict,
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	snapshot, err := getSnapshot(ctx, tx, `s.id = ?`, branch.SnapshotID)
+	if err != nil {
+		return nil, err
+	}
+	baseWords, err := querySnapshotWords(ctx, tx, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	_, mainByUID, err := queryWordsByUID(ctx, tx,
+		`SELECT `+wordColumns+`
+		FROM words
+		WHERE language_id = ? AND branch_id IS NULL AND deleted_at IS NULL`,
+		branch.LanguageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	branchWords, _, err := queryWordsByUID(ctx, tx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE branch_id = ? AND deleted_at IS NULL
+		ORDER BY headword, uid`,
+		branch.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// Branch words refer to each other by their UIDs on the branch, which
+	// are compared with the main line as the UIDs they were copied from
+	fields, err := queryFieldDefinitions(ctx, tx, branch.LanguageID)
+	if err != nil {
+		return nil, err
+	}
+	originUIDs := make(map[uint64]uint64, len(branchWords))
+	for _, word := range branchWords {
+		if word.OriginUID != 0 {
+			originUIDs[word.UID] = word.OriginUID
+		}
+	}
+	for _, word := range branchWords {
+		translateWordReferences(word, fields, originUIDs)
+	}
+	baseByUID := make(map[uint64]*conlangdev.Word, len(baseWords))
+	for _, word := range baseWords {
+		baseByUID[word.UID] = word
+	}
+
+	result := &conlangdev.MergeResult{
+		Added:     make([]*conlangdev.MergeChange, 0),
+		Updated:   make([]*conlangdev.MergeChange, 0),
+		Deleted:   make([]*conlangdev.MergeChange, 0),
+		Conflicts: make([]*conlangdev.MergeConflict, 0),
+	}
+	changes := make([]*mergeChange, 0)
+	kept := make(map[uint64]bool, len(branchWords))
+
+	for _, branchWord := range branchWords {
+		base, inBase := baseByUID[branchWord.OriginUID]
+		if branchWord.OriginUID == 0 || !inBase {
+			reported := &conlangdev.MergeChange{
+				BranchUID: branchWord.UID,
+				Headword:  branchWord.Headword,
+			}
+			result.Added = append(result.Added, reported)
+			changes = append(changes, &mergeChange{
+				action:   conlangdev.RevisionCreate,
+				word:     branchWord,
+				reported: reported,
+			})
+			continue
+		}
+		kept[base.UID] = true
+
+		mainWord, inMain := mainByUID[base.UID]
+		if !inMain {
+			// Deleted from the main line, which only matters if the branch
+			// has changed the word since
+			if sameWordFields(branchWord, base) {
+				continue
+			}
+			result.Conflicts = append(result.Conflicts, &conlangdev.MergeConflict{
+				UID:       base.UID,
+				BranchUID: branchWord.UID,
+				Headword:  branchWord.Headword,
+				DeletedIn: conlangdev.MergeResolveMain,
+			})
+			if merge.Resolve == conlangdev.MergeResolveBranch {
+				result.Added = append(result.Added, &conlangdev.MergeChange{
+					UID:       base.UID,
+					BranchUID: branchWord.UID,
+					Headword:  branchWord.Headword,
+				})
+				changes = append(changes, &mergeChange{
+					action: conlangdev.RevisionRestore,
+					word:   branchWord,
+					uid:    base.UID,
+				})
+			}
+			continue
+		}
+
+		merged := *mainWord
+		merged.CustomFields = make(map[string]json.RawMessage, len(mainWord.CustomFields))
+		for name, value := range mainWord.CustomFields {
+			merged.CustomFields[name] = value
+		}
+		baseValues, mainValues, branchValues := mergeValues(base), mergeValues(mainWord), mergeValues(branchWord)
+		for _, name := range mergeValueNames(baseValues, mainValues, branchValues) {
+			value, ok := conlangdev.MergeValue(baseValues[name], mainValues[name], branchValues[name])
+			if !ok {
+				result.Conflicts = append(result.Conflicts, &conlangdev.MergeConflict{
+					UID:       mainWord.UID,
+					BranchUID: branchWord.UID,
+					Headword:  mainWord.Headword,
+					Field:     name,
+					Base:      baseValues[name],
+					Main:      mainValues[name],
+					Branch:    branchValues[name],
+				})
+				if merge.Resolve == conlangdev.MergeResolveBranch {
+					value = branchValues[name]
+				}
+			}
+			setMergeValue(&merged, name, value)
+		}
+		if !sameWordFields(&merged, mainWord) {
+			result.Updated = append(result.Updated, &conlangdev.MergeChange{
+				UID:       mainWord.UID,
+				BranchUID: branchWord.UID,
+				Headword:  merged.Headword,
+			})
+			changes = append(changes, &mergeChange{action: conlangdev.RevisionUpdate, word: &merged})
+		}
+	}
+
+	// Words in the snapshot with no copy left on the branch were deleted
+	// there, which only matters if they still exist in the main line
+	for _, base := range baseWords {
+		mainWord, inMain := mainByUID[base.UID]
+		if kept[base.UID] || !inMain {
+			continue
+		}
+		if !sameWordFields(mainWord, base) {
+			result.Conflicts = append(result.Conflicts, &conlangdev.MergeConflict{
+				UID:       mainWord.UID,
+				Headword:  mainWord.Headword,
+				DeletedIn: conlangdev.MergeResolveBranch,
+			})
+			if merge.Resolve != conlangdev.MergeResolveBranch {
+				continue
+			}
+		}
+		result.Deleted = append(result.Deleted, &conlangdev.MergeChange{
+			UID:      mainWord.UID,
+			Headword: mainWord.Headword,
+		})
+		changes = append(changes, &mergeChange{action: conlangdev.RevisionDelete, word: mainWord})
+	}
+
+	if merge.DryRun || (len(result.Conflicts) > 0 && merge.Resolve == "") {
+		return result, nil
+	}
+
+	for _, change := range changes {
+		if err := applyMergeChange(ctx, tx, branch.LanguageID, change); err != nil {
+			return nil, err
+		}
+		if change.reported != nil {
+			change.reported.UID = change.uid
+		}
+	}
+	// References to words which were only just added to the main line
+	// still hold their UIDs on the branch
+	if err := remapWordReferences(ctx, tx, branch.LanguageID, nil,
+		`t.branch_id = ?`, "uid", "origin_uid", branch.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE language_branches SET
+			updated_at = NOW(), merged_at = NOW()
+		WHERE id = ?`,
+		branch.ID,
+	); err != nil {
+		return nil, err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+branchColumns+`
+		FROM language_branches WHERE id = ?`,
+		branch.ID,
+	)
+	if err := scanBranch(row, branch); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	result.Merged = true
+	return result, nil
+}
+
+// Applies a single change worked out by a merge to the main line,
+// recording it in the history of the main word. Added words have the UID
+// they were given stored in the change.
+func applyMergeChange(ctx context.Context, tx *sql.Tx, languageID uint, change *mergeChange) error {
+	word := change.word
+	switch change.action {
+	case conlangdev.RevisionDelete:
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE words SET
+				deleted_at = NOW(), version = version + 1
+			WHERE id = ?`,
+			word.ID,
+		); err != nil {
+			return err
+		}
+		return insertWordRevision(ctx, tx, word, conlangdev.RevisionDelete)
+
+	case conlangdev.RevisionUpdate, conlangdev.RevisionRestore:
+		// Restored words may still be in the trash, otherwise they were
+		// purged and are recreated with their old UID below
+		condition, arg := `id = ?`, interface{}(word.ID)
+		if change.action == conlangdev.RevisionRestore {
+			condition, arg = `uid = ? AND branch_id IS NULL`, change.uid
+		}
+		result, err := tx.ExecContext(ctx,
+			`UPDATE words SET
+				updated_at = NOW(), version = version + 1, deleted_at = NULL, headword = ?,
+				part_of_speech = ?, definition = ?, pronunciation = ?,
+				grammar_class = ?, gender = ?, etymology = ?, notes = ?, tags = ?,
+				custom_fields = ?, concepts = ?
+			WHERE `+condition,
+			word.Headword, word.PartOfSpeech, word.Definition, word.Pronunciation,
+			word.GrammarClass, word.Gender, word.Etymology, word.Notes,
+			encodeTags(word.Tags), encodeCustomFields(word.CustomFields),
+			encodeTags(word.Concepts), arg,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n > 0 {
+			updated := &conlangdev.Word{}
+			row := tx.QueryRowContext(ctx,
+				`SELECT `+wordColumns+`
+				FROM words WHERE `+condition+` LIMIT 1`,
+				arg,
+			)
+			if err := scanWord(row, updated); err != nil {
+				return err
+			}
+			return insertWordRevision(ctx, tx, updated, change.action)
+		}
+		if change.action == conlangdev.RevisionUpdate {
+			return sql.ErrNoRows
+		}
+	}
+
+	// Anything else is a new word in the main line, or a restored word
+	// which has to be recreated
+	var uid interface{}
+	if change.uid != 0 {
+		uid = change.uid
+	}
+	added := &conlangdev.Word{}
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO words (
+			uid, created_at, updated_at, headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes, tags,
+			custom_fields, concepts, language_id
+		) VALUES (
+			COALESCE(?, UUID_SHORT()), NOW(), NOW(), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		) RETURNING `+wordColumns,
+		uid, word.Headword, word.PartOfSpeech, word.Definition, word.Pronunciation,
+		word.GrammarClass, word.Gender, word.Etymology, word.Notes,
+		encodeTags(word.Tags), encodeCustomFields(word.CustomFields),
+		encodeTags(word.Concepts), languageID,
+	)
+	if err := scanWord(row, added); err != nil {
+		return err
+	}
+	change.uid = added.UID
+
+	// Branch words added to the main line now originate from their copy
+	// there, so that references to them can be pointed at it
+	if change.action == conlangdev.RevisionCreate {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE words SET origin_uid = ? WHERE id = ?`,
+			added.UID, word.ID,
+		); err != nil {
+			return err
+		}
+	}
+
+	return insertWordRevision(ctx, tx, added, change.action)
+}
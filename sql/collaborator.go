@@ -0,0 +1,375 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+)
+
+type CollaboratorService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+// Column list matching the order expected by scanCollaborator. Queries
+// using it must join the collaborating user as `u`.
+const collaboratorColumns = `c.id, c.created_at, c.updated_at, c.language_id,
+	c.user_id, u.username, c.role, COALESCE(c.invited_by_id, 0), c.accepted_at`
+
+func NewCollaboratorService(db *DB, validate *validator.Validate) *CollaboratorService {
+	return &CollaboratorService{db, validate}
+}
+
+func scanCollaborator(row rowScanner, collaborator *conlangdev.Collaborator) error {
+	return row.Scan(
+		&collaborator.ID, &collaborator.CreatedAt, &collaborator.UpdatedAt,
+		&collaborator.LanguageID, &collaborator.UserID, &collaborator.Username,
+		&collaborator.Role, &collaborator.InvitedByID, &collaborator.AcceptedAt,
+	)
+}
+
+func queryCollaborators(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]*conlangdev.Collaborator, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collaborators := make([]*conlangdev.Collaborator, 0)
+	for rows.Next() {
+		var collaborator conlangdev.Collaborator
+		if err := scanCollaborator(rows, &collaborator); err != nil {
+			return nil, err
+		}
+		collaborators = append(collaborators, &collaborator)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collaborators, nil
+}
+
+// Records an audited change to who can access a language, attributing it
+// to the user in the context.
+func insertAuditEntry(ctx context.Context, tx *sql.Tx, languageID uint, action string, subjectID uint, subjectUsername string, details string) error {
+	var actorID, subject interface{}
+	if subjectID != 0 {
+		subject = subjectID
+	}
+	actorUsername := ""
+	if actor := conlangdev.GetUserFromContext(ctx); actor != nil {
+		actorID, actorUsername = actor.ID, actor.Username
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO language_audit_log (
+			created_at, language_id, actor_id, actor_username,
+			action, subject_id, subject_username, details
+		) VALUES (NOW(), ?, ?, ?, ?, ?, ?, ?)`,
+		languageID, actorID, actorUsername, action, subject, subjectUsername, details,
+	)
+	return err
+}
+
+func (s *CollaboratorService) GetCollaboratorForLanguage(ctx context.Context, language *conlangdev.Language, user *conlangdev.User) (*conlangdev.Collaborator, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var collaborator conlangdev.Collaborator
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+collaboratorColumns+`
+		FROM language_collaborators c
+		INNER JOIN users u ON u.id = c.user_id
+		WHERE c.language_id = ? AND c.user_id = ?
+		LIMIT 1`,
+		language.ID, user.ID,
+	)
+	if err := scanCollaborator(row, &collaborator); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that collaborator",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &collaborator, nil
+}
+
+func (s *CollaboratorService) FindCollaboratorsForLanguage(ctx context.Context, language *conlangdev.Language) ([]*conlangdev.Collaborator, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return queryCollaborators(ctx, tx,
+		`SELECT `+collaboratorColumns+`
+		FROM language_collaborators c
+		INNER JOIN users u ON u.id = c.user_id
+		WHERE c.language_id = ?
+		ORDER BY c.created_at`,
+		language.ID,
+	)
+}
+
+// Finds the invites a user has been sent but not yet accepted.
+func (s *CollaboratorService) FindInvitesForUser(ctx context.Context, user *conlangdev.User) ([]*conlangdev.Collaborator, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return queryCollaborators(ctx, tx,
+		`SELECT `+collaboratorColumns+`
+		FROM language_collaborators c
+		INNER JOIN users u ON u.id = c.user_id
+		INNER JOIN languages l ON l.id = c.language_id
+		WHERE c.user_id = ? AND c.accepted_at IS NULL AND l.deleted_at IS NULL
+		ORDER BY c.created_at`,
+		user.ID,
+	)
+}
+
+// Invites a user to collaborate on a language. The invite has no effect
+// until it is accepted by the invited user.
+func (s *CollaboratorService) InviteCollaborator(ctx context.Context, language *conlangdev.Language, invite conlangdev.CollaboratorInvite) (*conlangdev.Collaborator, error) {
+	if err := validateStruct(s.validate, &invite); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userID uint
+	if err := tx.QueryRowContext(ctx,
+		`SELECT id FROM users WHERE username = ? LIMIT 1`,
+		invite.Username,
+	).Scan(&userID); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that user",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if userID == language.UserID {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "the owner of a language cannot be invited to it",
+			StatusCode: http.StatusConflict,
+		}
+	}
+
+	var invitedByID interface{}
+	if actor := conlangdev.GetUserFromContext(ctx); actor != nil {
+		invitedByID = actor.ID
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO language_collaborators (
+			created_at, updated_at, language_id, user_id, role, invited_by_id
+		) VALUES (NOW(), NOW(), ?, ?, ?, ?)`,
+		language.ID, userID, invite.Role, invitedByID,
+	)
+	if err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "that user has already been invited",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	collaborator := &conlangdev.Collaborator{}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+collaboratorColumns+`
+		FROM language_collaborators c
+		INNER JOIN users u ON u.id = c.user_id
+		WHERE c.id = ?`,
+		id,
+	)
+	if err := scanCollaborator(row, collaborator); err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEntry(ctx, tx, language.ID, conlangdev.AuditInvite,
+		collaborator.UserID, collaborator.Username,
+		fmt.Sprintf("invited as %s", collaborator.Role),
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return collaborator, nil
+}
+
+func (s *CollaboratorService) AcceptInvite(ctx context.Context, collaborator *conlangdev.Collaborator) error {
+	if collaborator.AcceptedAt != nil {
+		return &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "that invite has already been accepted",
+			StatusCode: http.StatusConflict,
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE language_collaborators SET
+			updated_at = NOW(), accepted_at = NOW()
+		WHERE id = ?`,
+		collaborator.ID,
+	); err != nil {
+		return err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+collaboratorColumns+`
+		FROM language_collaborators c
+		INNER JOIN users u ON u.id = c.user_id
+		WHERE c.id = ?`,
+		collaborator.ID,
+	)
+	if err := scanCollaborator(row, collaborator); err != nil {
+		return err
+	}
+
+	if err := insertAuditEntry(ctx, tx, collaborator.LanguageID, conlangdev.AuditAccept,
+		collaborator.UserID, collaborator.Username,
+		fmt.Sprintf("accepted invite as %s", collaborator.Role),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *CollaboratorService) UpdateCollaborator(ctx context.Context, collaborator *conlangdev.Collaborator, update conlangdev.CollaboratorUpdate) error {
+	if err := validateStruct(s.validate, &update); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	previous := collaborator.Role
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE language_collaborators SET
+			updated_at = NOW(), role = ?
+		WHERE id = ?`,
+		update.Role, collaborator.ID,
+	); err != nil {
+		return err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+collaboratorColumns+`
+		FROM language_collaborators c
+		INNER JOIN users u ON u.id = c.user_id
+		WHERE c.id = ?`,
+		collaborator.ID,
+	)
+	if err := scanCollaborator(row, collaborator); err != nil {
+		return err
+	}
+
+	if err := insertAuditEntry(ctx, tx, collaborator.LanguageID, conlangdev.AuditRoleChange,
+		collaborator.UserID, collaborator.Username,
+		fmt.Sprintf("role changed from %s to %s", previous, collaborator.Role),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *CollaboratorService) RemoveCollaborator(ctx context.Context, collaborator *conlangdev.Collaborator) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM language_collaborators WHERE id = ?`,
+		collaborator.ID,
+	); err != nil {
+		return err
+	}
+
+	if err := insertAuditEntry(ctx, tx, collaborator.LanguageID, conlangdev.AuditRemove,
+		collaborator.UserID, collaborator.Username,
+		fmt.Sprintf("removed from role %s", collaborator.Role),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *CollaboratorService) FindAuditEntriesForLanguage(ctx context.Context, language *conlangdev.Language) ([]*conlangdev.AuditEntry, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT
+			id, created_at, language_id, COALESCE(actor_id, 0), actor_username,
+			action, COALESCE(subject_id, 0), subject_username, details
+		FROM language_audit_log WHERE language_id = ?
+		ORDER BY created_at DESC, id DESC`,
+		language.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*conlangdev.AuditEntry, 0)
+	for rows.Next() {
+		var entry conlangdev.AuditEntry
+		if err := rows.Scan(
+			&entry.ID, &entry.CreatedAt, &entry.LanguageID, &entry.ActorID,
+			&entry.ActorUsername, &entry.Action, &entry.SubjectID,
+			&entry.SubjectUsername, &entry.Details,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,166 @@
+package sql
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/conlangdev/conlangdev/concepts"
+	"github.com/go-playground/validator/v10"
+)
+
+// Works out coverage of the built-in concept lists from the concepts words
+// are linked to.
+type ConceptService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+func NewConceptService(db *DB, validate *validator.Validate) *ConceptService {
+	return &ConceptService{db, validate}
+}
+
+// Checks that every concept a word is linked to is on one of the built-in
+// lists.
+func checkConcepts(ids []string) error {
+	for _, id := range ids {
+		if !concepts.Exists(id) {
+			return &conlangdev.FieldsError{
+				Code:       conlangdev.EVALIDFAIL,
+				Message:    "concepts must be on one of the reference lists",
+				StatusCode: http.StatusBadRequest,
+				Fields:     []string{"Concepts"},
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ConceptService) FindConceptLists(ctx context.Context) ([]*conlangdev.ConceptList, error) {
+	return concepts.Lists(), nil
+}
+
+func (s *ConceptService) GetConceptList(ctx context.Context, id string) (*conlangdev.ConceptList, error) {
+	list := concepts.Get(id)
+	if list == nil {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that concept list",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return list, nil
+}
+
+func (s *ConceptService) GetCoverageForLanguage(ctx context.Context, language *conlangdev.Language, list *conlangdev.ConceptList) (*conlangdev.ConceptCoverage, error) {
+	branchID, err := branchIDForLanguage(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT
+			id, uid, headword, definition, tags, concepts
+		FROM words
+		WHERE language_id = ? AND branch_id <=> ? AND deleted_at IS NULL
+			AND JSON_LENGTH(concepts) > 0
+		ORDER BY headword, uid`,
+		language.ID, branchID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wordsByConcept := make(map[string][]*conlangdev.WordIndex)
+	for rows.Next() {
+		var word conlangdev.WordIndex
+		var tags, linked []byte
+		if err := rows.Scan(
+			&word.ID, &word.UID, &word.Headword, &word.Definition, &tags, &linked,
+		); err != nil {
+			return nil, err
+		}
+		if word.Tags, err = decodeTags(tags); err != nil {
+			return nil, err
+		}
+		ids, err := decodeTags(linked)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			wordsByConcept[id] = append(wordsByConcept[id], &word)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	coverage := &conlangdev.ConceptCoverage{
+		List: &conlangdev.ConceptList{
+			ID:          list.ID,
+			Name:        list.Name,
+			Description: list.Description,
+		},
+		Total:   len(list.Concepts),
+		Coined:  make([]*conlangdev.ConceptCoverageEntry, 0),
+		Missing: make([]*conlangdev.Concept, 0),
+	}
+	for _, concept := range list.Concepts {
+		words, ok := wordsByConcept[concept.ID]
+		if !ok {
+			coverage.Missing = append(coverage.Missing, concept)
+			continue
+		}
+		coverage.Coined = append(coverage.Coined, &conlangdev.ConceptCoverageEntry{
+			Concept: concept,
+			Words:   words,
+		})
+	}
+	coverage.Covered = len(coverage.Coined)
+
+	return coverage, nil
+}
+
+func (s *ConceptService) CreateWordForConcept(ctx context.Context, language *conlangdev.Language, concept *conlangdev.Concept, create conlangdev.WordCreate) (*conlangdev.Word, error) {
+	if create.Definition == "" {
+		create.Definition = concept.Gloss
+	}
+	linked := false
+	for _, id := range create.Concepts {
+		linked = linked || id == concept.ID
+	}
+	if !linked {
+		create.Concepts = append(create.Concepts, concept.ID)
+	}
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, err
+	}
+	branchID, err := branchIDForLanguage(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	word, err := createWord(ctx, tx, language, branchID, create)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return word, nil
+}
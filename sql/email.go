@@ -0,0 +1,134 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+)
+
+type EmailVerificationService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+func NewEmailVerificationService(db *DB, validate *validator.Validate) *EmailVerificationService {
+	return &EmailVerificationService{db, validate}
+}
+
+// The error given when an address belongs to someone else.
+var errEmailTaken = &conlangdev.Error{
+	Code:       conlangdev.ECONFLICT,
+	Message:    "that email address is already in use",
+	StatusCode: http.StatusConflict,
+}
+
+func (s *EmailVerificationService) CreateEmailVerification(ctx context.Context, user *conlangdev.User, email string) (string, error) {
+	if err := s.validate.Var(email, "required,email,max=255"); err != nil {
+		return "", &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "validation failed",
+			StatusCode: http.StatusBadRequest,
+			Fields:     []string{"Email"},
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM users WHERE email = ? AND id != ?`,
+		email, user.ID,
+	).Scan(&n); err != nil {
+		return "", err
+	} else if n > 0 {
+		return "", errEmailTaken
+	}
+
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM email_verifications WHERE user_id = ?`,
+		user.ID,
+	); err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO email_verifications (
+			created_at, expires_at, user_id, email, token_hash
+		) VALUES (NOW(), NOW() + INTERVAL ? SECOND, ?, ?, ?)`,
+		int64(conlangdev.EmailVerificationLifetime/time.Second), user.ID, email, hashToken(token),
+	); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *EmailVerificationService) VerifyEmail(ctx context.Context, token string) (*conlangdev.User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var verificationID, userID uint
+	var email string
+	if err := tx.QueryRowContext(ctx,
+		`SELECT id, user_id, email FROM email_verifications
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW()
+		LIMIT 1 FOR UPDATE`,
+		hashToken(token),
+	).Scan(&verificationID, &userID, &email); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "that verification token is not valid or has expired",
+			StatusCode: http.StatusBadRequest,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET
+			updated_at = NOW(), email = ?, email_verified_at = NOW()
+		WHERE id = ?`,
+		email, userID,
+	); err != nil {
+		// Someone else may have taken the address since the token was sent
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, errEmailTaken
+		}
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE email_verifications SET used_at = NOW() WHERE id = ?`,
+		verificationID,
+	); err != nil {
+		return nil, err
+	}
+	user, err := getUserByID(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
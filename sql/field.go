@@ -0,0 +1,452 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+)
+
+type FieldDefinitionService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+// Column list matching the order expected by scanFieldDefinition.
+const fieldDefinitionColumns = `id, created_at, updated_at, language_id, name,
+	label, kind, options, required`
+
+var fieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+func NewFieldDefinitionService(db *DB, validate *validator.Validate) *FieldDefinitionService {
+	return &FieldDefinitionService{db, validate}
+}
+
+func scanFieldDefinition(row rowScanner, field *conlangdev.FieldDefinition) error {
+	var options []byte
+	if err := row.Scan(
+		&field.ID, &field.CreatedAt, &field.UpdatedAt, &field.LanguageID,
+		&field.Name, &field.Label, &field.Kind, &options, &field.Required,
+	); err != nil {
+		return err
+	}
+	return json.Unmarshal(options, &field.Options)
+}
+
+func queryFieldDefinitions(ctx context.Context, tx *sql.Tx, languageID uint) ([]*conlangdev.FieldDefinition, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+fieldDefinitionColumns+`
+		FROM language_fields WHERE language_id = ?
+		ORDER BY id`,
+		languageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := make([]*conlangdev.FieldDefinition, 0)
+	for rows.Next() {
+		var field conlangdev.FieldDefinition
+		if err := scanFieldDefinition(rows, &field); err != nil {
+			return nil, err
+		}
+		fields = append(fields, &field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+func (s *FieldDefinitionService) FindFieldDefinitionsForLanguage(ctx context.Context, language *conlangdev.Language) ([]*conlangdev.FieldDefinition, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return queryFieldDefinitions(ctx, tx, language.ID)
+}
+
+func (s *FieldDefinitionService) GetFieldDefinitionByName(ctx context.Context, language *conlangdev.Language, name string) (*conlangdev.FieldDefinition, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var field conlangdev.FieldDefinition
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+fieldDefinitionColumns+`
+		FROM language_fields WHERE language_id = ? AND name = ? LIMIT 1`,
+		language.ID, name,
+	)
+	if err := scanFieldDefinition(row, &field); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that field",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &field, nil
+}
+
+func (s *FieldDefinitionService) CreateFieldDefinition(ctx context.Context, language *conlangdev.Language, create conlangdev.FieldDefinitionCreate) (*conlangdev.FieldDefinition, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, err
+	}
+	if !fieldNamePattern.MatchString(create.Name) {
+		return nil, &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "field names may only contain lowercase letters, digits and underscores",
+			StatusCode: http.StatusBadRequest,
+			Fields:     []string{"Name"},
+		}
+	}
+	if create.Kind != conlangdev.FieldKindEnum {
+		create.Options = nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	field := &conlangdev.FieldDefinition{}
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO language_fields (
+			created_at, updated_at, language_id, name, label, kind, options, required
+		) VALUES (
+			NOW(), NOW(), ?, ?, ?, ?, ?, ?
+		) RETURNING `+fieldDefinitionColumns,
+		language.ID, create.Name, create.Label, create.Kind,
+		encodeTags(create.Options), create.Required,
+	)
+	if err := scanFieldDefinition(row, field); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "there is already a field with that name",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
+
+// Updating a field does not go back over the values words already have,
+// so narrowing the options of an enum or making a field required only
+// applies to words as they are next changed.
+func (s *FieldDefinitionService) UpdateFieldDefinition(ctx context.Context, field *conlangdev.FieldDefinition, update conlangdev.FieldDefinitionUpdate) error {
+	if err := validateStruct(s.validate, &update); err != nil {
+		return err
+	}
+	var options interface{}
+	if update.Options != nil {
+		if field.Kind != conlangdev.FieldKindEnum {
+			return &conlangdev.FieldsError{
+				Code:       conlangdev.EVALIDFAIL,
+				Message:    "only enum fields have options",
+				StatusCode: http.StatusBadRequest,
+				Fields:     []string{"Options"},
+			}
+		}
+		options = encodeTags(*update.Options)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE language_fields SET
+			updated_at = NOW(),
+			label = COALESCE(?, label),
+			options = COALESCE(?, options),
+			required = COALESCE(?, required)
+		WHERE id = ?`,
+		update.Label, options, update.Required, field.ID,
+	); err != nil {
+		return err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+fieldDefinitionColumns+`
+		FROM language_fields WHERE id = ?`,
+		field.ID,
+	)
+	if err := scanFieldDefinition(row, field); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Deletes a field definition, removing its values from every word in the
+// language and recording a revision for each word that had one.
+func (s *FieldDefinitionService) DeleteFieldDefinition(ctx context.Context, field *conlangdev.FieldDefinition) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	path := customFieldPath(field.Name)
+	words, _, err := queryWordsByUID(ctx, tx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE language_id = ? AND JSON_CONTAINS_PATH(custom_fields, 'one', ?)
+		FOR UPDATE`,
+		field.LanguageID, path,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE words SET
+			updated_at = NOW(), version = version + 1,
+			custom_fields = JSON_REMOVE(custom_fields, ?)
+		WHERE language_id = ? AND JSON_CONTAINS_PATH(custom_fields, 'one', ?)`,
+		path, field.LanguageID, path,
+	); err != nil {
+		return err
+	}
+	for _, word := range words {
+		delete(word.CustomFields, field.Name)
+		if err := insertWordRevision(ctx, tx, word, conlangdev.RevisionUpdate); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM language_fields WHERE id = ?`,
+		field.ID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Gives the JSON path of a custom field within the custom_fields column.
+func customFieldPath(name string) string {
+	return `$."` + name + `"`
+}
+
+// Encodes tags for a JSON column, storing a missing list as an empty one.
+func encodeTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	encoded, _ := json.Marshal(tags)
+	return string(encoded)
+}
+
+func decodeTags(data []byte) ([]string, error) {
+	tags := make([]string, 0)
+	if len(data) == 0 {
+		return tags, nil
+	}
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Encodes custom fields for a JSON column. Keys are sorted and values
+// compacted, so that equal fields always encode the same way.
+func encodeCustomFields(fields map[string]json.RawMessage) string {
+	if fields == nil {
+		return "{}"
+	}
+	encoded, _ := json.Marshal(fields)
+	return string(encoded)
+}
+
+func decodeCustomFields(data []byte) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if len(data) == 0 {
+		return fields, nil
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// Trims duplicate tags, keeping the first of each.
+func uniqueTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	unique := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+// Checks the custom fields of a word against the definitions of its
+// language, returning them with their values normalised. Word references
+// must name a word on the same line of the language.
+func checkCustomFields(ctx context.Context, tx *sql.Tx, languageID uint, branchID interface{}, fields map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	definitions, err := queryFieldDefinitions(ctx, tx, languageID)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*conlangdev.FieldDefinition, len(definitions))
+	for _, definition := range definitions {
+		byName[definition.Name] = definition
+	}
+
+	// Null values leave a field unset, as if it was not given at all
+	checked := make(map[string]json.RawMessage, len(fields))
+	invalid := make([]string, 0)
+	for name, value := range fields {
+		if string(value) == "null" {
+			continue
+		}
+		definition, ok := byName[name]
+		if !ok {
+			invalid = append(invalid, "custom_fields."+name)
+			continue
+		}
+		normalised, err := checkCustomFieldValue(ctx, tx, languageID, branchID, definition, value)
+		if err != nil {
+			return nil, err
+		} else if normalised == nil {
+			invalid = append(invalid, "custom_fields."+name)
+			continue
+		}
+		checked[name] = normalised
+	}
+	for _, definition := range definitions {
+		if value, ok := fields[definition.Name]; definition.Required && (!ok || string(value) == "null") {
+			invalid = append(invalid, "custom_fields."+definition.Name)
+		}
+	}
+
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return nil, &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "custom fields do not match the fields defined for this language",
+			StatusCode: http.StatusBadRequest,
+			Fields:     invalid,
+		}
+	}
+	return checked, nil
+}
+
+// Checks a single custom field value, giving nil if it is not valid.
+func checkCustomFieldValue(ctx context.Context, tx *sql.Tx, languageID uint, branchID interface{}, definition *conlangdev.FieldDefinition, value json.RawMessage) (json.RawMessage, error) {
+	switch definition.Kind {
+	case conlangdev.FieldKindText, conlangdev.FieldKindEnum:
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil || text == "" {
+			return nil, nil
+		}
+		if definition.Kind == conlangdev.FieldKindEnum {
+			allowed := false
+			for _, option := range definition.Options {
+				if option == text {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return nil, nil
+			}
+		}
+		encoded, _ := json.Marshal(text)
+		return encoded, nil
+
+	case conlangdev.FieldKindNumber:
+		var number float64
+		if err := json.Unmarshal(value, &number); err != nil {
+			return nil, nil
+		}
+		encoded, _ := json.Marshal(number)
+		return encoded, nil
+
+	case conlangdev.FieldKindBoolean:
+		var boolean bool
+		if err := json.Unmarshal(value, &boolean); err != nil {
+			return nil, nil
+		}
+		encoded, _ := json.Marshal(boolean)
+		return encoded, nil
+
+	case conlangdev.FieldKindWord:
+		var uid uint64
+		if err := json.Unmarshal(value, &uid); err != nil {
+			return nil, nil
+		}
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (
+				SELECT 1 FROM words
+				WHERE uid = ? AND language_id = ? AND branch_id <=> ?
+					AND deleted_at IS NULL
+			)`,
+			uid, languageID, branchID,
+		).Scan(&exists); err != nil {
+			return nil, err
+		} else if !exists {
+			return nil, nil
+		}
+		return json.RawMessage(strconv.FormatUint(uid, 10)), nil
+	}
+	return nil, nil
+}
+
+// Points the word references in the custom fields of one line of a
+// language at other words, after words have been copied in bulk. Each
+// reference is looked up by the matchColumn of a word `t` meeting the
+// lookup condition, and replaced by the valueColumn of that word.
+// References which cannot be looked up are left as they are.
+func remapWordReferences(ctx context.Context, tx *sql.Tx, languageID uint, branchID interface{}, lookup string, matchColumn string, valueColumn string, args ...interface{}) error {
+	fields, err := queryFieldDefinitions(ctx, tx, languageID)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if field.Kind != conlangdev.FieldKindWord {
+			continue
+		}
+		path := customFieldPath(field.Name)
+		queryArgs := append([]interface{}{path}, args...)
+		queryArgs = append(queryArgs, path, languageID, branchID)
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE words w INNER JOIN words t
+				ON t.`+matchColumn+` = CAST(JSON_UNQUOTE(JSON_EXTRACT(w.custom_fields, ?)) AS UNSIGNED)
+				AND `+lookup+`
+			SET w.custom_fields = JSON_SET(w.custom_fields, ?, t.`+valueColumn+`)
+			WHERE w.language_id = ? AND w.branch_id <=> ?`,
+			queryArgs...,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -15,10 +15,51 @@ type LanguageService struct {
 	validate *validator.Validate
 }
 
+// Column list matching the order expected by scanLanguage.
+const languageColumns = `id, created_at, updated_at, version, name,
+	slug, endonym, visibility, COALESCE(user_id, 0), COALESCE(team_id, 0),
+	COALESCE(forked_from_id, 0), forked_at, COALESCE(forked_word_id, 0),
+	hidden_at, hidden_reason,
+	deleted_at`
+
 func NewLanguageService(db *DB, validate *validator.Validate) *LanguageService {
 	return &LanguageService{db, validate}
 }
 
+// Scans a row selected using languageColumns into the given language.
+func scanLanguage(row rowScanner, language *conlangdev.Language) error {
+	return row.Scan(
+		&language.ID, &language.CreatedAt, &language.UpdatedAt, &language.Version,
+		&language.Name,
+		&language.Slug, &language.Endonym, &language.Visibility, &language.UserID,
+		&language.TeamID, &language.ForkedFromID, &language.ForkedAt,
+		&language.ForkedWordID, &language.HiddenAt, &language.HiddenReason, &language.DeletedAt,
+	)
+}
+
+// Runs a query selecting languageColumns and scans every resulting row.
+func queryLanguages(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]*conlangdev.Language, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	languages := make([]*conlangdev.Language, 0)
+	for rows.Next() {
+		var language conlangdev.Language
+		if err := scanLanguage(rows, &language); err != nil {
+			return nil, err
+		}
+		languages = append(languages, &language)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return languages, nil
+}
+
 func (s *LanguageService) GetLanguageByID(ctx context.Context, id uint) (*conlangdev.Language, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -27,16 +68,12 @@ func (s *LanguageService) GetLanguageByID(ctx context.Context, id uint) (*conlan
 	defer tx.Rollback()
 
 	var language conlangdev.Language
-	if err := tx.QueryRowContext(ctx,
-		`SELECT
-			id, created_at, updated_at, name,
-			slug, endonym, user_id
-		FROM languages WHERE id = ? LIMIT 1`,
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE id = ? AND deleted_at IS NULL LIMIT 1`,
 		id,
-	).Scan(
-		&language.ID, &language.CreatedAt, &language.UpdatedAt,
-		&language.Name, &language.Slug, &language.Endonym, &language.UserID,
-	); err == sql.ErrNoRows {
+	)
+	if err := scanLanguage(row, &language); err == sql.ErrNoRows {
 		return nil, &conlangdev.Error{
 			Code:       conlangdev.ENOTFOUND,
 			Message:    "could not find that language",
@@ -57,18 +94,45 @@ func (s *LanguageService) GetLanguageByUserAndSlug(ctx context.Context, user *co
 	defer tx.Rollback()
 
 	var language conlangdev.Language
-	if err := tx.QueryRowContext(ctx,
-		`SELECT
-			id, created_at, updated_at, name,
-			slug, endonym, user_id
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+languageColumns+`
 		FROM languages WHERE
-			slug = ? AND user_id = ?
+			slug = ? AND user_id = ? AND deleted_at IS NULL
 		LIMIT 1`,
 		slug, user.ID,
-	).Scan(
-		&language.ID, &language.CreatedAt, &language.UpdatedAt,
-		&language.Name, &language.Slug, &language.Endonym, &language.UserID,
-	); err == sql.ErrNoRows {
+	)
+	if err := scanLanguage(row, &language); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that language",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &language, nil
+}
+
+// Retrieves a language by its slug within a namespace, which may be either
+// a username or a team slug.
+func (s *LanguageService) GetLanguageByNamespaceAndSlug(ctx context.Context, namespace string, slug string) (*conlangdev.Language, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var language conlangdev.Language
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE slug = ? AND (
+			user_id = (SELECT user_id FROM namespaces WHERE name = ?) OR
+			team_id = (SELECT team_id FROM namespaces WHERE name = ?)
+		) AND deleted_at IS NULL LIMIT 1`,
+		slug, namespace, namespace,
+	)
+	if err := scanLanguage(row, &language); err == sql.ErrNoRows {
 		return nil, &conlangdev.Error{
 			Code:       conlangdev.ENOTFOUND,
 			Message:    "could not find that language",
@@ -88,52 +152,62 @@ func (s *LanguageService) FindLanguagesForUser(ctx context.Context, user *conlan
 	}
 	defer tx.Rollback()
 
-	rows, err := tx.QueryContext(ctx,
-		`SELECT
-			id, created_at, updated_at, name,
-			slug, endonym, user_id
-		FROM languages WHERE user_id = ?`,
+	return queryLanguages(ctx, tx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE user_id = ? AND deleted_at IS NULL`,
 		user.ID,
 	)
+}
+
+func (s *LanguageService) FindLanguagesForTeam(ctx context.Context, team *conlangdev.Team) ([]*conlangdev.Language, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	languages := make([]*conlangdev.Language, 0)
-	for rows.Next() {
-		var language conlangdev.Language
-		if err := rows.Scan(
-			&language.ID, &language.CreatedAt, &language.UpdatedAt,
-			&language.Name, &language.Slug, &language.Endonym, &language.UserID,
-		); err != nil {
-			return nil, err
-		}
-		languages = append(languages, &language)
-	}
-	if err := rows.Err(); err != nil {
+	return queryLanguages(ctx, tx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE team_id = ? AND deleted_at IS NULL`,
+		team.ID,
+	)
+}
+
+// Finds every language which has been made public, newest first. Unlisted
+// languages are deliberately left out.
+func (s *LanguageService) FindPublicLanguages(ctx context.Context) ([]*conlangdev.Language, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return languages, nil
+	return queryLanguages(ctx, tx,
+		`SELECT `+languageColumns+`
+		FROM languages
+		WHERE visibility = ? AND hidden_at IS NULL AND deleted_at IS NULL
+		ORDER BY created_at DESC`,
+		conlangdev.VisibilityPublic,
+	)
 }
 
 func (s *LanguageService) CreateLanguageForUser(ctx context.Context, user *conlangdev.User, create conlangdev.LanguageCreate) (*conlangdev.Language, error) {
-	if err := s.validate.Struct(&create); err != nil {
-		if val_err, ok := err.(validator.ValidationErrors); ok {
-			var fields []string
-			for _, field := range val_err {
-				fields = append(fields, field.Field())
-			}
-			return nil, &conlangdev.FieldsError{
-				Code:       conlangdev.EVALIDFAIL,
-				Message:    "validation failed",
-				StatusCode: http.StatusBadRequest,
-				Fields:     fields,
-			}
-		}
+	return s.createLanguage(ctx, user.ID, nil, create)
+}
+
+func (s *LanguageService) CreateLanguageForTeam(ctx context.Context, team *conlangdev.Team, create conlangdev.LanguageCreate) (*conlangdev.Language, error) {
+	return s.createLanguage(ctx, nil, team.ID, create)
+}
+
+// Creates a language owned by either a user or a team, given as IDs where
+// exactly one of the two is nil.
+func (s *LanguageService) createLanguage(ctx context.Context, userID interface{}, teamID interface{}, create conlangdev.LanguageCreate) (*conlangdev.Language, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
 		return nil, err
 	}
+	if create.Visibility == "" {
+		create.Visibility = conlangdev.VisibilityPrivate
+	}
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -142,31 +216,27 @@ func (s *LanguageService) CreateLanguageForUser(ctx context.Context, user *conla
 	defer tx.Rollback()
 
 	language := &conlangdev.Language{}
-	if err := tx.QueryRowContext(ctx,
+	row := tx.QueryRowContext(ctx,
 		`INSERT INTO languages (
 			created_at, updated_at, name,
-			slug, endonym, user_id
+			slug, endonym, visibility, user_id, team_id
 		) VALUES (
-			NOW(), NOW(), ?, ?, ?, ?
-		) RETURNING
-			id, created_at, updated_at, name,
-			slug, endonym, user_id`,
-		create.Name, create.Slug, create.Endonym, user.ID,
-	).Scan(
-		&language.ID, &language.CreatedAt, &language.UpdatedAt, &language.Name,
-		&language.Slug, &language.Endonym, &language.UserID,
-	); err != nil {
+			NOW(), NOW(), ?, ?, ?, ?, ?, ?
+		) RETURNING `+languageColumns,
+		create.Name, create.Slug, create.Endonym, create.Visibility, userID, teamID,
+	)
+	if err := scanLanguage(row, language); err != nil {
 		if sql_err, ok := err.(*mysql.MySQLError); ok {
 			if sql_err.Number == 1062 {
 				return nil, &conlangdev.Error{
 					Code:       conlangdev.ECONFLICT,
-					Message:    "you already have a language with that slug",
+					Message:    "there is already a language with that slug",
 					StatusCode: http.StatusConflict,
 				}
 			} else if sql_err.Number == 1452 {
 				return nil, &conlangdev.Error{
 					Code:       conlangdev.ENOTFOUND,
-					Message:    "user with that ID does not exist",
+					Message:    "owner of the language does not exist",
 					StatusCode: http.StatusNotFound,
 				}
 			}
@@ -181,18 +251,237 @@ func (s *LanguageService) CreateLanguageForUser(ctx context.Context, user *conla
 	return language, nil
 }
 
+// Updates the given fields of a language. Fields left as nil in the update
+// are not changed. The language is updated in place on success.
 func (s *LanguageService) UpdateLanguage(ctx context.Context, language *conlangdev.Language, update conlangdev.LanguageUpdate) error {
-	return &conlangdev.Error{
-		Code:       conlangdev.ENOTIMPLEMENTED,
-		Message:    "not implemented",
-		StatusCode: http.StatusInternalServerError,
+	if err := validateStruct(s.validate, &update); err != nil {
+		return err
 	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE languages SET
+			updated_at = NOW(),
+			version = version + 1,
+			name = COALESCE(?, name),
+			endonym = COALESCE(?, endonym),
+			visibility = COALESCE(?, visibility)
+		WHERE id = ? AND version = ?`,
+		update.Name, update.Endonym, update.Visibility, language.ID, language.Version,
+	)
+	if err != nil {
+		return err
+	}
+	if err := checkVersionUpdated(result, "language"); err != nil {
+		return err
+	}
+
+	// MariaDB has no UPDATE ... RETURNING, so read the language back
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE id = ? LIMIT 1`,
+		language.ID,
+	)
+	if err := scanLanguage(row, language); err == sql.ErrNoRows {
+		return &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that language",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *LanguageService) TransferLanguageToUser(ctx context.Context, language *conlangdev.Language, user *conlangdev.User) error {
+	return s.transferLanguage(ctx, language, user.ID, nil, user.Username)
+}
+
+func (s *LanguageService) TransferLanguageToTeam(ctx context.Context, language *conlangdev.Language, team *conlangdev.Team) error {
+	return s.transferLanguage(ctx, language, nil, team.ID, team.Slug)
+}
+
+// Moves a language into another namespace, recording the transfer in the
+// language's audit log.
+func (s *LanguageService) transferLanguage(ctx context.Context, language *conlangdev.Language, userID interface{}, teamID interface{}, namespace string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE languages SET
+			updated_at = NOW(), version = version + 1, user_id = ?, team_id = ?
+		WHERE id = ?`,
+		userID, teamID, language.ID,
+	); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "there is already a language with that slug in " + namespace,
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return err
+	}
+
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE id = ? LIMIT 1`,
+		language.ID,
+	)
+	if err := scanLanguage(row, language); err != nil {
+		return err
+	}
+
+	if err := insertAuditEntry(ctx, tx, language.ID, conlangdev.AuditTransfer,
+		0, "", "transferred to "+namespace,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// Creates a private copy of a language and all of its words in the user's
+// namespace. Copied words are given new UIDs, and remember which upstream
+// word they came from so the fork can later be compared with its upstream.
+func (s *LanguageService) ForkLanguageForUser(ctx context.Context, upstream *conlangdev.Language, user *conlangdev.User, fork conlangdev.LanguageFork) (*conlangdev.Language, error) {
+	if fork.Name == "" {
+		fork.Name = upstream.Name
+	}
+	if fork.Slug == "" {
+		fork.Slug = upstream.Slug
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	language := &conlangdev.Language{}
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO languages (
+			created_at, updated_at, name, slug, endonym,
+			visibility, user_id, forked_from_id, forked_at, forked_word_id
+		) SELECT
+			NOW(), NOW(), ?, ?, ?, ?, ?, ?, NOW(), MAX(id)
+		FROM words WHERE language_id = ?
+		RETURNING `+languageColumns,
+		fork.Name, fork.Slug, upstream.Endonym,
+		conlangdev.VisibilityPrivate, user.ID, upstream.ID, upstream.ID,
+	)
+	if err := scanLanguage(row, language); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "you already have a language with that slug",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO language_fields (
+			created_at, updated_at, language_id, name, label, kind, options, required
+		) SELECT
+			NOW(), NOW(), ?, name, label, kind, options, required
+		FROM language_fields WHERE language_id = ?`,
+		language.ID, upstream.ID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO language_vocabulary (
+			created_at, updated_at, language_id, category, value, label,
+			abbreviation, aliases
+		) SELECT
+			NOW(), NOW(), ?, category, value, label, abbreviation, aliases
+		FROM language_vocabulary WHERE language_id = ?`,
+		language.ID, upstream.ID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO words (
+			created_at, updated_at, headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes, tags,
+			custom_fields, concepts, language_id, upstream_uid, upstream_updated_at
+		) SELECT
+			NOW(), NOW(), headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes, tags,
+			custom_fields, concepts, ?, uid, updated_at
+		FROM words
+		WHERE language_id = ? AND branch_id IS NULL AND deleted_at IS NULL`,
+		language.ID, upstream.ID,
+	); err != nil {
+		return nil, err
+	}
+	// Word references point at the copies of the words they referenced
+	if err := remapWordReferences(ctx, tx, language.ID, nil,
+		`t.language_id = ? AND t.branch_id IS NULL`, "upstream_uid", "uid", language.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	// Copied words start their own history rather than inheriting the
+	// upstream revisions
+	if err := insertInitialWordRevisions(ctx, tx, `language_id = ?`, language.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return language, nil
+}
+
+// Moves a language to the trash. Its words are left as they are, and are
+// hidden along with the language until it is either restored or purged.
 func (s *LanguageService) DeleteLanguage(ctx context.Context, language *conlangdev.Language) error {
-	return &conlangdev.Error{
-		Code:       conlangdev.ENOTIMPLEMENTED,
-		Message:    "not implemented",
-		StatusCode: http.StatusInternalServerError,
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE languages SET
+			deleted_at = NOW(), version = version + 1
+		WHERE id = ? AND version = ?`,
+		language.ID, language.Version,
+	)
+	if err != nil {
+		return err
+	}
+	if err := checkVersionUpdated(result, "language"); err != nil {
+		return err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE id = ? LIMIT 1`,
+		language.ID,
+	)
+	if err := scanLanguage(row, language); err != nil {
+		return err
+	}
+
+	if err := insertAuditEntry(ctx, tx, language.ID, conlangdev.AuditTrash,
+		0, "", "moved to the trash",
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
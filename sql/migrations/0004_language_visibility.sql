@@ -0,0 +1,3 @@
+ALTER TABLE languages
+    ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'private' AFTER endonym;
+CREATE INDEX idx_languages_visibility ON languages (visibility)
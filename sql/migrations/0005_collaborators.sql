@@ -0,0 +1,29 @@
+CREATE TABLE language_collaborators (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    language_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    invited_by_id INT,
+    accepted_at DATETIME,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_language_user UNIQUE(language_id, user_id),
+    FOREIGN KEY (language_id) REFERENCES languages(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by_id) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE TABLE language_audit_log (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    language_id INT NOT NULL,
+    actor_id INT,
+    actor_username VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    subject_id INT,
+    subject_username VARCHAR(255) NOT NULL,
+    details TEXT NOT NULL,
+    PRIMARY KEY(id),
+    INDEX idx_audit_language (language_id, created_at),
+    FOREIGN KEY (language_id) REFERENCES languages(id) ON DELETE CASCADE
+)
@@ -0,0 +1,40 @@
+CREATE TABLE teams (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_team_slug UNIQUE(slug)
+);
+CREATE TABLE team_members (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    team_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_team_user UNIQUE(team_id, user_id),
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE namespaces (
+    name VARCHAR(255) NOT NULL,
+    user_id INT,
+    team_id INT,
+    PRIMARY KEY(name),
+    CONSTRAINT uc_namespace_user UNIQUE(user_id),
+    CONSTRAINT uc_namespace_team UNIQUE(team_id),
+    CONSTRAINT chk_namespace_owner CHECK ((user_id IS NULL) <> (team_id IS NULL)),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+INSERT INTO namespaces (name, user_id) SELECT username, id FROM users;
+ALTER TABLE languages
+    MODIFY user_id INTEGER NULL,
+    ADD COLUMN team_id INT NULL AFTER user_id,
+    ADD CONSTRAINT uc_team_slug UNIQUE(slug, team_id),
+    ADD CONSTRAINT chk_language_owner CHECK ((user_id IS NULL) <> (team_id IS NULL)),
+    ADD FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
@@ -0,0 +1,7 @@
+ALTER TABLE languages
+    ADD COLUMN forked_from_id INT NULL AFTER team_id,
+    ADD COLUMN forked_at DATETIME NULL AFTER forked_from_id,
+    ADD FOREIGN KEY (forked_from_id) REFERENCES languages(id) ON DELETE SET NULL;
+ALTER TABLE words
+    ADD COLUMN upstream_uid BIGINT UNSIGNED NULL AFTER language_id,
+    ADD COLUMN upstream_updated_at DATETIME NULL AFTER upstream_uid
@@ -0,0 +1,32 @@
+CREATE TABLE word_revisions (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    language_id INT NOT NULL,
+    word_uid BIGINT UNSIGNED NOT NULL,
+    revision INT NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    author_id INT,
+    author_username VARCHAR(255) NOT NULL,
+    headword VARCHAR(255) NOT NULL,
+    part_of_speech VARCHAR(255) NOT NULL,
+    definition TEXT NOT NULL,
+    pronunciation VARCHAR(255),
+    grammar_class VARCHAR(255),
+    gender VARCHAR(255),
+    etymology TEXT,
+    notes TEXT,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_word_revision UNIQUE(word_uid, revision),
+    INDEX idx_revisions_language (language_id, word_uid),
+    FOREIGN KEY (language_id) REFERENCES languages(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE SET NULL
+);
+INSERT INTO word_revisions (
+    created_at, language_id, word_uid, revision, action, author_username,
+    headword, part_of_speech, definition, pronunciation,
+    grammar_class, gender, etymology, notes
+) SELECT
+    created_at, language_id, uid, 1, 'create', '',
+    headword, part_of_speech, definition, pronunciation,
+    grammar_class, gender, etymology, notes
+FROM words
@@ -0,0 +1,6 @@
+ALTER TABLE languages
+    ADD COLUMN deleted_at DATETIME NULL AFTER forked_at,
+    ADD INDEX idx_languages_deleted (deleted_at);
+ALTER TABLE words
+    ADD COLUMN deleted_at DATETIME NULL AFTER upstream_updated_at,
+    ADD INDEX idx_words_deleted (deleted_at)
@@ -0,0 +1,47 @@
+CREATE TABLE language_snapshots (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    language_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    author_id INT,
+    author_username VARCHAR(255) NOT NULL,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_snapshot_name UNIQUE(language_id, name),
+    FOREIGN KEY (language_id) REFERENCES languages(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE TABLE snapshot_words (
+    snapshot_id INT NOT NULL,
+    word_uid BIGINT UNSIGNED NOT NULL,
+    headword VARCHAR(255) NOT NULL,
+    part_of_speech VARCHAR(255) NOT NULL,
+    definition TEXT NOT NULL,
+    pronunciation VARCHAR(255),
+    grammar_class VARCHAR(255),
+    gender VARCHAR(255),
+    etymology TEXT,
+    notes TEXT,
+    PRIMARY KEY(snapshot_id, word_uid),
+    FOREIGN KEY (snapshot_id) REFERENCES language_snapshots(id) ON DELETE CASCADE
+);
+CREATE TABLE language_branches (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    language_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    snapshot_id INT NOT NULL,
+    author_id INT,
+    author_username VARCHAR(255) NOT NULL,
+    merged_at DATETIME NULL,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_branch_name UNIQUE(language_id, name),
+    FOREIGN KEY (language_id) REFERENCES languages(id) ON DELETE CASCADE,
+    FOREIGN KEY (snapshot_id) REFERENCES language_snapshots(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE SET NULL
+);
+ALTER TABLE words
+    ADD COLUMN branch_id INT NULL AFTER upstream_updated_at,
+    ADD COLUMN origin_uid BIGINT UNSIGNED NULL AFTER branch_id,
+    ADD FOREIGN KEY (branch_id) REFERENCES language_branches(id) ON DELETE CASCADE
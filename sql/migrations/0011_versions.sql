@@ -0,0 +1,4 @@
+ALTER TABLE languages
+    ADD COLUMN version INT NOT NULL DEFAULT 1 AFTER updated_at;
+ALTER TABLE words
+    ADD COLUMN version INT NOT NULL DEFAULT 1 AFTER updated_at
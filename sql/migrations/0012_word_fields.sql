@@ -0,0 +1,23 @@
+ALTER TABLE words
+    ADD COLUMN tags JSON NOT NULL DEFAULT '[]' AFTER notes,
+    ADD COLUMN custom_fields JSON NOT NULL DEFAULT '{}' AFTER tags;
+ALTER TABLE word_revisions
+    ADD COLUMN tags JSON NOT NULL DEFAULT '[]' AFTER notes,
+    ADD COLUMN custom_fields JSON NOT NULL DEFAULT '{}' AFTER tags;
+ALTER TABLE snapshot_words
+    ADD COLUMN tags JSON NOT NULL DEFAULT '[]' AFTER notes,
+    ADD COLUMN custom_fields JSON NOT NULL DEFAULT '{}' AFTER tags;
+CREATE TABLE language_fields (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    language_id INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    label VARCHAR(255) NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    options JSON NOT NULL DEFAULT '[]',
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_language_field UNIQUE(language_id, name),
+    FOREIGN KEY (language_id) REFERENCES languages(id) ON DELETE CASCADE
+)
@@ -0,0 +1,14 @@
+CREATE TABLE language_vocabulary (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    language_id INT NOT NULL,
+    category VARCHAR(32) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    label VARCHAR(255) NOT NULL,
+    abbreviation VARCHAR(255) NOT NULL,
+    aliases JSON NOT NULL DEFAULT '[]',
+    PRIMARY KEY(id),
+    CONSTRAINT uc_vocabulary_value UNIQUE(language_id, category, value),
+    FOREIGN KEY (language_id) REFERENCES languages(id) ON DELETE CASCADE
+)
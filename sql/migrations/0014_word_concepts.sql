@@ -0,0 +1,6 @@
+ALTER TABLE words
+    ADD COLUMN concepts JSON NOT NULL DEFAULT '[]' AFTER custom_fields;
+ALTER TABLE word_revisions
+    ADD COLUMN concepts JSON NOT NULL DEFAULT '[]' AFTER custom_fields;
+ALTER TABLE snapshot_words
+    ADD COLUMN concepts JSON NOT NULL DEFAULT '[]' AFTER custom_fields
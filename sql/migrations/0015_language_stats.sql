@@ -0,0 +1,10 @@
+CREATE TABLE language_stats (
+    language_id INT NOT NULL,
+    branch_id INT NOT NULL DEFAULT 0,
+    revision_id INT NOT NULL,
+    revision_count INT NOT NULL,
+    generated_at DATETIME NOT NULL,
+    data JSON NOT NULL,
+    PRIMARY KEY(language_id, branch_id),
+    FOREIGN KEY (language_id) REFERENCES languages(id) ON DELETE CASCADE
+)
@@ -0,0 +1,16 @@
+CREATE TABLE sessions (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    user_id INT NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(64) NOT NULL,
+    refresh_token_hash CHAR(64) NOT NULL,
+    previous_token_hash CHAR(64),
+    revoked_at DATETIME,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_refresh_token UNIQUE(refresh_token_hash),
+    INDEX idx_sessions_previous_token (previous_token_hash),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+)
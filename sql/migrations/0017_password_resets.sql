@@ -0,0 +1,11 @@
+CREATE TABLE password_resets (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    used_at DATETIME,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_password_reset_token UNIQUE(token_hash),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+)
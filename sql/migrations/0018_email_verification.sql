@@ -0,0 +1,15 @@
+ALTER TABLE users
+    ADD COLUMN email_verified_at DATETIME NULL AFTER email;
+UPDATE users SET email_verified_at = created_at;
+CREATE TABLE email_verifications (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    user_id INT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    used_at DATETIME,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_email_verification_token UNIQUE(token_hash),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+)
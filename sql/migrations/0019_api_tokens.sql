@@ -0,0 +1,22 @@
+CREATE TABLE api_tokens (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME,
+    expires_at DATETIME,
+    user_id INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    revoked_at DATETIME,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_api_token UNIQUE(token_hash),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE api_token_scopes (
+    api_token_id INT NOT NULL,
+    language_id INT NOT NULL,
+    access VARCHAR(16) NOT NULL,
+    PRIMARY KEY(api_token_id, language_id),
+    FOREIGN KEY (api_token_id) REFERENCES api_tokens(id) ON DELETE CASCADE,
+    FOREIGN KEY (language_id) REFERENCES languages(id) ON DELETE CASCADE
+)
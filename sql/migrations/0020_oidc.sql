@@ -0,0 +1,24 @@
+CREATE TABLE oidc_logins (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    provider VARCHAR(64) NOT NULL,
+    state_hash CHAR(64) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    verifier VARCHAR(128) NOT NULL,
+    redirect_uri VARCHAR(1024) NOT NULL,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_oidc_login_state UNIQUE(state_hash)
+);
+
+CREATE TABLE oidc_identities (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    user_id INT NOT NULL,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_oidc_identity UNIQUE(provider, subject),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+)
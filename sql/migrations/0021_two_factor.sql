@@ -0,0 +1,30 @@
+ALTER TABLE users
+    ADD COLUMN two_factor_enabled_at DATETIME NULL;
+CREATE TABLE two_factor_secrets (
+    user_id INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY(user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE recovery_codes (
+    id INT NOT NULL AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME,
+    PRIMARY KEY(id),
+    INDEX idx_recovery_codes_user (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE two_factor_challenges (
+    id INT NOT NULL AUTO_INCREMENT,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    PRIMARY KEY(id),
+    CONSTRAINT uc_two_factor_challenge UNIQUE(token_hash),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+)
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+    ADD COLUMN links JSON NOT NULL DEFAULT '[]',
+    ADD COLUMN deleted_at DATETIME NULL
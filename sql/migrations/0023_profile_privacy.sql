@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN avatar_url VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN privacy JSON NOT NULL DEFAULT '{}'
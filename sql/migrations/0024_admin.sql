@@ -0,0 +1,8 @@
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user',
+    ADD COLUMN profile_hidden_at DATETIME NULL,
+    ADD COLUMN suspended_at DATETIME NULL,
+    ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE languages
+    ADD COLUMN hidden_at DATETIME NULL,
+    ADD COLUMN hidden_reason TEXT NOT NULL DEFAULT ''
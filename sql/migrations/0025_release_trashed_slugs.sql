@@ -0,0 +1,6 @@
+ALTER TABLE languages
+    ADD COLUMN active_slug VARCHAR(255) AS (IF(deleted_at IS NULL, slug, NULL)) PERSISTENT AFTER slug,
+    DROP INDEX uc_user_slug,
+    DROP INDEX uc_team_slug,
+    ADD CONSTRAINT uc_user_slug UNIQUE(active_slug, user_id),
+    ADD CONSTRAINT uc_team_slug UNIQUE(active_slug, team_id)
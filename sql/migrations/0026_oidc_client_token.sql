@@ -0,0 +1,3 @@
+DELETE FROM oidc_logins;
+ALTER TABLE oidc_logins
+    ADD COLUMN client_token_hash CHAR(64) NOT NULL AFTER state_hash
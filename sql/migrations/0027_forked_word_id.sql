@@ -0,0 +1,7 @@
+ALTER TABLE languages
+    ADD COLUMN forked_word_id INT NULL AFTER forked_at;
+UPDATE languages SET forked_word_id = COALESCE(
+    (SELECT MIN(w.id) - 1 FROM words w WHERE w.language_id = languages.id AND w.upstream_uid IS NOT NULL),
+    (SELECT MAX(w.id) FROM words w WHERE w.language_id = languages.forked_from_id AND w.created_at <= languages.forked_at),
+    0
+) WHERE forked_at IS NOT NULL
@@ -0,0 +1,291 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-sql-driver/mysql"
+)
+
+type OIDCService struct {
+	db *DB
+}
+
+func NewOIDCService(db *DB) *OIDCService {
+	return &OIDCService{db}
+}
+
+// Column list matching the order expected by scanOIDCIdentity.
+const oidcIdentityColumns = `id, created_at, user_id, provider, subject, email`
+
+func scanOIDCIdentity(row rowScanner, identity *conlangdev.OIDCIdentity) error {
+	return row.Scan(
+		&identity.ID, &identity.CreatedAt, &identity.UserID, &identity.Provider,
+		&identity.Subject, &identity.Email,
+	)
+}
+
+func (s *OIDCService) CreateOIDCLogin(ctx context.Context, provider string, redirectURI string) (*conlangdev.OIDCLogin, error) {
+	login := &conlangdev.OIDCLogin{
+		Provider:    provider,
+		RedirectURI: redirectURI,
+	}
+	var err error
+	if login.State, err = generateToken(32); err != nil {
+		return nil, err
+	}
+	if login.ClientToken, err = generateToken(32); err != nil {
+		return nil, err
+	}
+	if login.Nonce, err = generateToken(32); err != nil {
+		return nil, err
+	}
+	if login.Verifier, err = generateToken(48); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Clear out logins which were never finished
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM oidc_logins WHERE expires_at < NOW()`,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO oidc_logins (
+			created_at, expires_at, provider, state_hash, client_token_hash,
+			nonce, verifier, redirect_uri
+		) VALUES (NOW(), NOW() + INTERVAL ? SECOND, ?, ?, ?, ?, ?, ?)`,
+		int64(conlangdev.OIDCLoginLifetime/time.Second), provider,
+		hashToken(login.State), hashToken(login.ClientToken), login.Nonce,
+		login.Verifier, redirectURI,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return login, nil
+}
+
+// Logins are used up even when the client token is wrong, as the state
+// has then been seen by somebody other than the client it was given to.
+func (s *OIDCService) ConsumeOIDCLogin(ctx context.Context, provider string, state string, clientToken string) (*conlangdev.OIDCLogin, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	invalid := &conlangdev.Error{
+		Code:       conlangdev.EUNAUTHORIZED,
+		Message:    "that sign in is not valid or has expired, try again",
+		StatusCode: http.StatusUnauthorized,
+	}
+	var id uint
+	var clientTokenHash string
+	login := &conlangdev.OIDCLogin{Provider: provider, State: state, ClientToken: clientToken}
+	if err := tx.QueryRowContext(ctx,
+		`SELECT id, client_token_hash, nonce, verifier, redirect_uri
+		FROM oidc_logins
+		WHERE state_hash = ? AND provider = ? AND expires_at > NOW()
+		LIMIT 1 FOR UPDATE`,
+		hashToken(state), provider,
+	).Scan(&id, &clientTokenHash, &login.Nonce, &login.Verifier, &login.RedirectURI); err == sql.ErrNoRows {
+		return nil, invalid
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM oidc_logins WHERE id = ?`,
+		id,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if hashToken(clientToken) != clientTokenHash {
+		return nil, invalid
+	}
+	return login, nil
+}
+
+// Turns the name an identity provider gives for a user into one which can
+// be used as a username, which may still be taken.
+func usernameFromOIDCClaims(claims *conlangdev.OIDCClaims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	var username strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			username.WriteRune(r)
+		}
+	}
+	result := username.String()
+	for len(result) < 3 {
+		result += "_"
+	}
+	if len(result) > 24 {
+		result = result[:24]
+	}
+	return result
+}
+
+// Finds a username based on the claims which is not taken by a user or
+// team, by adding a number to it if needed.
+func availableUsername(ctx context.Context, tx *sql.Tx, claims *conlangdev.OIDCClaims) (string, error) {
+	base := usernameFromOIDCClaims(claims)
+	for i := 1; i <= 100; i++ {
+		username := base
+		if i > 1 {
+			username = fmt.Sprintf("%s%d", base, i)
+		}
+		var taken bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM namespaces WHERE name = ?)
+			OR EXISTS(SELECT 1 FROM users WHERE username = ?)`,
+			username, username,
+		).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return username, nil
+		}
+	}
+	return "", &conlangdev.Error{
+		Code:       conlangdev.ECONFLICT,
+		Message:    "could not find a free username, register with a password instead",
+		StatusCode: http.StatusConflict,
+	}
+}
+
+func (s *OIDCService) GetUserForOIDCClaims(ctx context.Context, provider string, claims *conlangdev.OIDCClaims) (*conlangdev.User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Identities which have signed in before
+	var userID uint
+	if err := tx.QueryRowContext(ctx,
+		`SELECT user_id FROM oidc_identities
+		WHERE provider = ? AND subject = ? LIMIT 1`,
+		provider, claims.Subject,
+	).Scan(&userID); err == nil {
+		return getUserByID(ctx, tx, userID)
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var existing *conlangdev.User
+	var found conlangdev.User
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+userColumns+`
+		FROM users WHERE email = ? LIMIT 1`,
+		claims.Email,
+	)
+	if err := scanUser(row, &found); err == nil {
+		existing = &found
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	user, create, err := conlangdev.UserForOIDCClaims(claims, existing, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if create {
+		if user.Username, err = availableUsername(ctx, tx, claims); err != nil {
+			return nil, err
+		}
+		row := tx.QueryRowContext(ctx,
+			`INSERT INTO users (
+				created_at, updated_at, username, email, email_verified_at,
+				display_name, password_hash
+			) VALUES (NOW(), NOW(), ?, ?, ?, ?, '') RETURNING `+userColumns,
+			user.Username, user.Email, user.EmailVerifiedAt, user.DisplayName,
+		)
+		if err := scanUser(row, user); err != nil {
+			if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+				return nil, &conlangdev.Error{
+					Code:       conlangdev.ECONFLICT,
+					Message:    "user already exists with that username or email",
+					StatusCode: http.StatusConflict,
+				}
+			}
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO namespaces (name, user_id) VALUES (?, ?)`,
+			user.Username, user.ID,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO oidc_identities (
+			created_at, user_id, provider, subject, email
+		) VALUES (NOW(), ?, ?, ?, ?)`,
+		user.ID, provider, claims.Subject, claims.Email,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *OIDCService) FindOIDCIdentitiesForUser(ctx context.Context, user *conlangdev.User) ([]*conlangdev.OIDCIdentity, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+oidcIdentityColumns+`
+		FROM oidc_identities WHERE user_id = ?
+		ORDER BY provider, id`,
+		user.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := make([]*conlangdev.OIDCIdentity, 0)
+	for rows.Next() {
+		var identity conlangdev.OIDCIdentity
+		if err := scanOIDCIdentity(rows, &identity); err != nil {
+			return nil, err
+		}
+		identities = append(identities, &identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
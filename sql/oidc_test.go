@@ -0,0 +1,26 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+func TestUsernameFromOIDCClaims(t *testing.T) {
+	tests := []struct {
+		claims   conlangdev.OIDCClaims
+		username string
+	}{
+		{conlangdev.OIDCClaims{PreferredUsername: "Alice"}, "alice"},
+		{conlangdev.OIDCClaims{PreferredUsername: "a.l i@ce!"}, "alice"},
+		{conlangdev.OIDCClaims{Email: "bob_smith@example.com"}, "bob_smith"},
+		{conlangdev.OIDCClaims{PreferredUsername: "jo"}, "jo_"},
+		{conlangdev.OIDCClaims{PreferredUsername: "ñ"}, "___"},
+		{conlangdev.OIDCClaims{PreferredUsername: "averyveryverylongusername123"}, "averyveryverylongusernam"},
+	}
+	for _, test := range tests {
+		if username := usernameFromOIDCClaims(&test.claims); username != test.username {
+			t.Errorf("%+v: got %q, want %q", test.claims, username, test.username)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+)
+
+type PasswordResetService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+func NewPasswordResetService(db *DB, validate *validator.Validate) *PasswordResetService {
+	return &PasswordResetService{db, validate}
+}
+
+func (s *PasswordResetService) CreatePasswordReset(ctx context.Context, email string) (*conlangdev.User, string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	var user conlangdev.User
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+userColumns+`
+		FROM users WHERE email = ? LIMIT 1`,
+		email,
+	)
+	if err := scanUser(row, &user); err == sql.ErrNoRows {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	token, err := generateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	// Only the latest token sent can be used
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM password_resets WHERE user_id = ?`,
+		user.ID,
+	); err != nil {
+		return nil, "", err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO password_resets (
+			created_at, expires_at, user_id, token_hash
+		) VALUES (NOW(), NOW() + INTERVAL ? SECOND, ?, ?)`,
+		int64(conlangdev.PasswordResetLifetime/time.Second), user.ID, hashToken(token),
+	); err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+
+	return &user, token, nil
+}
+
+func (s *PasswordResetService) ResetPassword(ctx context.Context, token string, password string) (*conlangdev.User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var resetID, userID uint
+	if err := tx.QueryRowContext(ctx,
+		`SELECT id, user_id FROM password_resets
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW()
+		LIMIT 1 FOR UPDATE`,
+		hashToken(token),
+	).Scan(&resetID, &userID); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "that reset token is not valid or has expired",
+			StatusCode: http.StatusBadRequest,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	user, err := getUserByID(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := setUserPassword(ctx, tx, s.validate, user.ID, password)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE password_resets SET used_at = NOW() WHERE id = ?`,
+		resetID,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	user.PasswordHash = hash
+	return user, nil
+}
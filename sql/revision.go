@@ -0,0 +1,289 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+type WordRevisionService struct {
+	db *DB
+}
+
+// Column list matching the order expected by scanWordRevision.
+const wordRevisionColumns = `id, created_at, language_id, word_uid, revision,
+	action, COALESCE(author_id, 0), author_username, headword, part_of_speech,
+	definition, pronunciation, grammar_class, gender, etymology, notes, tags,
+	custom_fields, concepts`
+
+func NewWordRevisionService(db *DB) *WordRevisionService {
+	return &WordRevisionService{db}
+}
+
+func scanWordRevision(row rowScanner, revision *conlangdev.WordRevision) error {
+	var tags, customFields, concepts []byte
+	if err := row.Scan(
+		&revision.ID, &revision.CreatedAt, &revision.LanguageID, &revision.WordUID,
+		&revision.Revision, &revision.Action, &revision.AuthorID,
+		&revision.AuthorUsername, &revision.Headword, &revision.PartOfSpeech,
+		&revision.Definition, &revision.Pronunciation, &revision.GrammarClass,
+		&revision.Gender, &revision.Etymology, &revision.Notes, &tags, &customFields,
+		&concepts,
+	); err != nil {
+		return err
+	}
+	var err error
+	if revision.Tags, err = decodeTags(tags); err != nil {
+		return err
+	}
+	if revision.CustomFields, err = decodeCustomFields(customFields); err != nil {
+		return err
+	}
+	revision.Concepts, err = decodeTags(concepts)
+	return err
+}
+
+// Gives the user in the context as the author of something, with a NULL
+// ID if there is none.
+func authorFromContext(ctx context.Context) (interface{}, string) {
+	if author := conlangdev.GetUserFromContext(ctx); author != nil {
+		return author.ID, author.Username
+	}
+	return nil, ""
+}
+
+// Records the current state of a word as its next revision, attributing
+// it to the user in the context.
+func insertWordRevision(ctx context.Context, tx *sql.Tx, word *conlangdev.Word, action string) error {
+	authorID, authorUsername := authorFromContext(ctx)
+
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO word_revisions (
+			created_at, language_id, word_uid, revision, action,
+			author_id, author_username, headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes, tags, custom_fields,
+			concepts
+		) SELECT
+			NOW(), ?, ?, COALESCE(MAX(revision), 0) + 1, ?,
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		FROM word_revisions WHERE word_uid = ?`,
+		word.LanguageID, word.UID, action,
+		authorID, authorUsername, word.Headword, word.PartOfSpeech, word.Definition,
+		word.Pronunciation, word.GrammarClass, word.Gender, word.Etymology, word.Notes,
+		encodeTags(word.Tags), encodeCustomFields(word.CustomFields),
+		encodeTags(word.Concepts), word.UID,
+	)
+	return err
+}
+
+// Records the first revision of every word matching the given condition,
+// for words which were copied in bulk rather than created one at a time.
+func insertInitialWordRevisions(ctx context.Context, tx *sql.Tx, condition string, args ...interface{}) error {
+	authorID, authorUsername := authorFromContext(ctx)
+
+	args = append([]interface{}{conlangdev.RevisionCreate, authorID, authorUsername}, args...)
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO word_revisions (
+			created_at, language_id, word_uid, revision, action,
+			author_id, author_username, headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes, tags, custom_fields,
+			concepts
+		) SELECT
+			NOW(), language_id, uid, 1, ?, ?, ?, headword, part_of_speech,
+			definition, pronunciation, grammar_class, gender, etymology, notes,
+			tags, custom_fields, concepts
+		FROM words WHERE `+condition,
+		args...,
+	)
+	return err
+}
+
+func (s *WordRevisionService) FindRevisionsForWord(ctx context.Context, language *conlangdev.Language, uid uint64) ([]*conlangdev.WordRevision, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+wordRevisionColumns+`
+		FROM word_revisions WHERE language_id = ? AND word_uid = ?
+		ORDER BY revision DESC`,
+		language.ID, uid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]*conlangdev.WordRevision, 0)
+	for rows.Next() {
+		var revision conlangdev.WordRevision
+		if err := scanWordRevision(rows, &revision); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find any history for that word",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+
+	return revisions, nil
+}
+
+func (s *WordRevisionService) GetRevisionForWord(ctx context.Context, language *conlangdev.Language, uid uint64, revision int) (*conlangdev.WordRevision, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var wordRevision conlangdev.WordRevision
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+wordRevisionColumns+`
+		FROM word_revisions
+		WHERE language_id = ? AND word_uid = ? AND revision = ?
+		LIMIT 1`,
+		language.ID, uid, revision,
+	)
+	if err := scanWordRevision(row, &wordRevision); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that revision",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &wordRevision, nil
+}
+
+// Gives the update which sets every field of a word to its value in the
+// given revision, removing any custom fields the revision did not have.
+func revisionUpdate(word *conlangdev.Word, revision *conlangdev.WordRevision) conlangdev.WordUpdate {
+	customFields := make(map[string]json.RawMessage, len(word.CustomFields)+len(revision.CustomFields))
+	for name := range word.CustomFields {
+		customFields[name] = json.RawMessage("null")
+	}
+	for name, value := range revision.CustomFields {
+		customFields[name] = value
+	}
+	tags, concepts := revision.Tags, revision.Concepts
+
+	return conlangdev.WordUpdate{
+		Headword:      &revision.Headword,
+		PartOfSpeech:  &revision.PartOfSpeech,
+		Definition:    &revision.Definition,
+		Pronunciation: &revision.Pronunciation,
+		GrammarClass:  &revision.GrammarClass,
+		Gender:        &revision.Gender,
+		Etymology:     &revision.Etymology,
+		Notes:         &revision.Notes,
+		Tags:          &tags,
+		CustomFields:  customFields,
+		Concepts:      &concepts,
+	}
+}
+
+// Restores the revision through the same helpers as creating and updating
+// words, so that it is checked against the vocabulary, custom fields and
+// concepts the language has now.
+func (s *WordRevisionService) RestoreRevision(ctx context.Context, language *conlangdev.Language, word *conlangdev.Word, revision *conlangdev.WordRevision) (*conlangdev.Word, error) {
+	if revision.Action == conlangdev.RevisionDelete {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "a deletion cannot be restored, restore an earlier revision instead",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Update the word if it still exists, checking it has not changed
+	// since the caller loaded it. Otherwise take it out of the trash, or
+	// recreate it with the UID it had before.
+	if word != nil {
+		if err := applyWordUpdate(ctx, tx, word, revisionUpdate(word, revision), conlangdev.RevisionRestore); err != nil {
+			return nil, err
+		}
+	} else if word, err = restoreWordFromRevision(ctx, tx, language, revision); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return word, nil
+}
+
+// Brings back a word which is not in the language any more as it was at
+// the given revision.
+func restoreWordFromRevision(ctx context.Context, tx *sql.Tx, language *conlangdev.Language, revision *conlangdev.WordRevision) (*conlangdev.Word, error) {
+	word := &conlangdev.Word{}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE uid = ? AND language_id = ? LIMIT 1`,
+		revision.WordUID, language.ID,
+	)
+	if err := scanWord(row, word); err == sql.ErrNoRows {
+		branchID, err := branchIDForLanguage(ctx, language)
+		if err != nil {
+			return nil, err
+		}
+		return insertWord(ctx, tx, language, branchID, revision.WordUID, conlangdev.WordCreate{
+			Headword:      revision.Headword,
+			PartOfSpeech:  revision.PartOfSpeech,
+			Definition:    revision.Definition,
+			Pronunciation: revision.Pronunciation,
+			GrammarClass:  revision.GrammarClass,
+			Gender:        revision.Gender,
+			Etymology:     revision.Etymology,
+			Notes:         revision.Notes,
+			Tags:          revision.Tags,
+			CustomFields:  revision.CustomFields,
+			Concepts:      revision.Concepts,
+		}, conlangdev.RevisionRestore)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// A word which is not trashed but was not found by the caller is on
+	// another branch
+	if word.DeletedAt == nil {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "that word is on another branch",
+			StatusCode: http.StatusConflict,
+		}
+	}
+	// The version is left for the update to move on, so that the restore
+	// counts as a single change
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE words SET deleted_at = NULL WHERE id = ?`,
+		word.ID,
+	); err != nil {
+		return nil, err
+	}
+	word.DeletedAt = nil
+	if err := applyWordUpdate(ctx, tx, word, revisionUpdate(word, revision), conlangdev.RevisionRestore); err != nil {
+		return nil, err
+	}
+	return word, nil
+}
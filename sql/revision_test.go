@@ -0,0 +1,46 @@
+package sql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+func TestRevisionUpdate(t *testing.T) {
+	word := &conlangdev.Word{
+		Headword: "kala",
+		Tags:     []string{"new"},
+		CustomFields: map[string]json.RawMessage{
+			"plural": json.RawMessage(`"kalat"`),
+			"added":  json.RawMessage(`"since"`),
+		},
+	}
+	revision := &conlangdev.WordRevision{
+		Headword:     "kalo",
+		PartOfSpeech: "noun",
+		Definition:   "fish",
+		CustomFields: map[string]json.RawMessage{
+			"plural": json.RawMessage(`"kalot"`),
+		},
+	}
+
+	update := revisionUpdate(word, revision)
+	if update.Headword == nil || *update.Headword != "kalo" {
+		t.Errorf("headword: got %v, want kalo", update.Headword)
+	}
+	if update.Tags == nil || len(*update.Tags) != 0 {
+		t.Errorf("tags: got %v, want them all removed", update.Tags)
+	}
+	if update.Concepts == nil {
+		t.Error("concepts: got nil, want them replaced")
+	}
+	want := map[string]json.RawMessage{
+		"plural": json.RawMessage(`"kalot"`),
+		"added":  json.RawMessage("null"),
+	}
+	if !reflect.DeepEqual(update.CustomFields, want) {
+		t.Errorf("custom fields: got %s, want %s", update.CustomFields, want)
+	}
+}
@@ -0,0 +1,439 @@
+package sql
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/golang-jwt/jwt"
+)
+
+type SessionService struct {
+	db        *DB
+	jwtSecret []byte
+}
+
+// Claims of an access token, which is tied to the session it was issued
+// for by the session ID.
+type customClaim struct {
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	SessionID uint   `json:"sid"`
+	jwt.StandardClaims
+}
+
+// Column list matching the order expected by scanSession.
+const sessionColumns = `id, created_at, last_used_at, expires_at, user_id,
+	user_agent, ip_address, revoked_at`
+
+func NewSessionService(db *DB, jwtSecret string) *SessionService {
+	return &SessionService{db, []byte(jwtSecret)}
+}
+
+func scanSession(row rowScanner, session *conlangdev.Session) error {
+	return row.Scan(
+		&session.ID, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt,
+		&session.UserID, &session.UserAgent, &session.IPAddress, &session.RevokedAt,
+	)
+}
+
+// Generates a random token of the given number of bytes, encoded so that it
+// can be passed around as text.
+func generateToken(size int) (string, error) {
+	buffer := make([]byte, size)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// Hashes a token for storage. Tokens are random enough that a plain SHA-256
+// hash is all they need, and it lets them be looked up directly.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Limits the client details kept with a session to what fits in the table.
+func truncateSessionCreate(create conlangdev.SessionCreate) conlangdev.SessionCreate {
+	if len(create.UserAgent) > 255 {
+		create.UserAgent = create.UserAgent[:255]
+	}
+	if len(create.IPAddress) > 64 {
+		create.IPAddress = create.IPAddress[:64]
+	}
+	return create
+}
+
+// Issues an access token for a session, to go with the given refresh token.
+func (s *SessionService) issueTokens(user *conlangdev.User, session *conlangdev.Session, refreshToken string) (*conlangdev.SessionTokens, error) {
+	tokenID, err := generateToken(16)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(conlangdev.AccessTokenLifetime)
+	claims := &customClaim{
+		UserID:    user.ID,
+		Username:  user.Username,
+		SessionID: session.ID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			Issuer:    "conlangdev",
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conlangdev.SessionTokens{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  expiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: session.ExpiresAt,
+		Session:               session,
+	}, nil
+}
+
+func (s *SessionService) CreateSession(ctx context.Context, user *conlangdev.User, create conlangdev.SessionCreate) (*conlangdev.SessionTokens, error) {
+	create = truncateSessionCreate(create)
+	refreshToken, err := generateToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var session conlangdev.Session
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO sessions (
+			created_at, last_used_at, expires_at, user_id, user_agent,
+			ip_address, refresh_token_hash
+		) VALUES (
+			NOW(), NOW(), NOW() + INTERVAL ? SECOND, ?, ?, ?, ?
+		) RETURNING `+sessionColumns,
+		int64(conlangdev.RefreshTokenLifetime/time.Second), user.ID,
+		create.UserAgent, create.IPAddress, hashToken(refreshToken),
+	)
+	if err := scanSession(row, &session); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, &session, refreshToken)
+}
+
+func (s *SessionService) RefreshSession(ctx context.Context, refreshToken string, create conlangdev.SessionCreate) (*conlangdev.SessionTokens, error) {
+	create = truncateSessionCreate(create)
+	hash := hashToken(refreshToken)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var session conlangdev.Session
+	var current, active bool
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+`, refresh_token_hash = ?,
+			revoked_at IS NULL AND expires_at > NOW()
+		FROM sessions
+		WHERE refresh_token_hash = ? OR previous_token_hash = ?
+		LIMIT 1 FOR UPDATE`,
+		hash, hash, hash,
+	)
+	if err := row.Scan(
+		&session.ID, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt,
+		&session.UserID, &session.UserAgent, &session.IPAddress, &session.RevokedAt,
+		&current, &active,
+	); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "that refresh token is not valid",
+			StatusCode: http.StatusUnauthorized,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "that session has ended, log in again",
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	if !current {
+		// The token was already swapped, so whoever is using it now may
+		// not be who it was issued to
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE sessions SET revoked_at = NOW() WHERE id = ?`,
+			session.ID,
+		); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "that refresh token has already been used, log in again",
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+
+	// Suspended and deleted users cannot keep their sessions going, even if
+	// one was missed when they were ended
+	user, err := getUserByID(ctx, tx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Suspended() || user.DeletedAt != nil {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE sessions SET revoked_at = NOW() WHERE id = ?`,
+			session.ID,
+		); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		if user.Suspended() {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.EUNAUTHORIZED,
+				Message:    "this account has been suspended",
+				StatusCode: http.StatusForbidden,
+			}
+		}
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "that session has ended, log in again",
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+
+	newRefreshToken, err := generateToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET
+			previous_token_hash = refresh_token_hash,
+			refresh_token_hash = ?,
+			last_used_at = NOW(),
+			expires_at = NOW() + INTERVAL ? SECOND,
+			user_agent = ?,
+			ip_address = ?
+		WHERE id = ?`,
+		hashToken(newRefreshToken), int64(conlangdev.RefreshTokenLifetime/time.Second),
+		create.UserAgent, create.IPAddress, session.ID,
+	); err != nil {
+		return nil, err
+	}
+	row = tx.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`,
+		session.ID,
+	)
+	if err := scanSession(row, &session); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, &session, newRefreshToken)
+}
+
+func (s *SessionService) GetSessionByAccessToken(ctx context.Context, accessToken string) (*conlangdev.Session, *conlangdev.User, error) {
+	token, err := jwt.ParseWithClaims(
+		accessToken,
+		&customClaim{},
+		func(token *jwt.Token) (interface{}, error) {
+			if token.Method != jwt.SigningMethodHS256 {
+				return nil, errors.New("unexpected JWT signing method")
+			}
+			return s.jwtSecret, nil
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Expiry is checked while parsing
+	claims, ok := token.Claims.(*customClaim)
+	if !ok || claims.SessionID == 0 {
+		return nil, nil, errors.New("could not parse JWT claims")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var session conlangdev.Session
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > NOW()
+		LIMIT 1`,
+		claims.SessionID, claims.UserID,
+	)
+	if err := scanSession(row, &session); err != nil {
+		return nil, nil, errors.New("JWT could not be associated with an active session")
+	}
+	user, err := getUserByID(ctx, tx, session.UserID)
+	if err != nil || user.Username != claims.Username {
+		return nil, nil, errors.New("JWT could not be associated with a user")
+	}
+
+	return &session, user, nil
+}
+
+// Lists the active sessions of a user, most recently used first.
+func (s *SessionService) FindSessionsForUser(ctx context.Context, user *conlangdev.User) ([]*conlangdev.Session, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC, id DESC`,
+		user.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*conlangdev.Session, 0)
+	for rows.Next() {
+		var session conlangdev.Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *SessionService) GetSessionForUser(ctx context.Context, user *conlangdev.User, id uint) (*conlangdev.Session, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var session conlangdev.Session
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > NOW()
+		LIMIT 1`,
+		id, user.ID,
+	)
+	if err := scanSession(row, &session); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that session",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (s *SessionService) RevokeSession(ctx context.Context, session *conlangdev.Session) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = NOW()
+		WHERE id = ? AND revoked_at IS NULL`,
+		session.ID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SessionService) RevokeSessionsForUser(ctx context.Context, user *conlangdev.User) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeSessionsForUser(ctx, tx, user.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Revokes every active session of a user within a transaction.
+func revokeSessionsForUser(ctx context.Context, tx *sql.Tx, userID uint) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = ? AND revoked_at IS NULL`,
+		userID,
+	)
+	return err
+}
+
+func (s *SessionService) PurgeSessions(ctx context.Context) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`DELETE FROM sessions WHERE revoked_at IS NOT NULL OR expires_at < NOW()`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
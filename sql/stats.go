@@ -0,0 +1,113 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+// Keeps the stats of each language, and each of its branches, along with
+// the latest word revision of the language at the time and how many there
+// were. Every change to a word records a revision, so stats are worked out
+// again whenever a newer revision turns up, or when revisions have been
+// purged along with words from the trash.
+type StatsService struct {
+	db *DB
+}
+
+func NewStatsService(db *DB) *StatsService {
+	return &StatsService{db}
+}
+
+func (s *StatsService) GetStatsForLanguage(ctx context.Context, language *conlangdev.Language) (*conlangdev.LanguageStats, error) {
+	branchID, err := branchIDForLanguage(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+	// The cache is keyed by branch, with 0 for the main line
+	var cacheBranchID uint
+	if branch := conlangdev.GetBranchFromContext(ctx); branch != nil {
+		cacheBranchID = branch.ID
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var revisionID, revisionCount int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(id), 0), COUNT(*)
+		FROM word_revisions WHERE language_id = ?`,
+		language.ID,
+	).Scan(&revisionID, &revisionCount); err != nil {
+		return nil, err
+	}
+
+	var cachedRevisionID, cachedRevisionCount int
+	var data []byte
+	err = tx.QueryRowContext(ctx,
+		`SELECT revision_id, revision_count, data FROM language_stats
+		WHERE language_id = ? AND branch_id = ?`,
+		language.ID, cacheBranchID,
+	).Scan(&cachedRevisionID, &cachedRevisionCount, &data)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && cachedRevisionID == revisionID && cachedRevisionCount == revisionCount {
+		var stats conlangdev.LanguageStats
+		if err := json.Unmarshal(data, &stats); err == nil {
+			return &stats, nil
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE language_id = ? AND branch_id <=> ? AND deleted_at IS NULL`,
+		language.ID, branchID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := make([]*conlangdev.Word, 0)
+	for rows.Next() {
+		var word conlangdev.Word
+		if err := scanWord(rows, &word); err != nil {
+			return nil, err
+		}
+		words = append(words, &word)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := conlangdev.ComputeLanguageStats(words)
+	data, err = json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO language_stats (
+			language_id, branch_id, revision_id, revision_count, generated_at, data
+		) VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			revision_id = VALUES(revision_id),
+			revision_count = VALUES(revision_count),
+			generated_at = VALUES(generated_at),
+			data = VALUES(data)`,
+		language.ID, cacheBranchID, revisionID, revisionCount, stats.GeneratedAt, data,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,450 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+)
+
+type TeamService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+// Column list matching the order expected by scanTeam.
+const teamColumns = `id, created_at, updated_at, slug, name, description`
+
+// Column list matching the order expected by scanTeamMember. Queries using
+// it must join the member's user as `u`.
+const teamMemberColumns = `m.id, m.created_at, m.updated_at, m.team_id,
+	m.user_id, u.username, m.role`
+
+func NewTeamService(db *DB, validate *validator.Validate) *TeamService {
+	return &TeamService{db, validate}
+}
+
+func scanTeam(row rowScanner, team *conlangdev.Team) error {
+	return row.Scan(
+		&team.ID, &team.CreatedAt, &team.UpdatedAt,
+		&team.Slug, &team.Name, &team.Description,
+	)
+}
+
+// Runs a query selecting teamColumns and scans every resulting row.
+func queryTeams(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]*conlangdev.Team, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := make([]*conlangdev.Team, 0)
+	for rows.Next() {
+		var team conlangdev.Team
+		if err := scanTeam(rows, &team); err != nil {
+			return nil, err
+		}
+		teams = append(teams, &team)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+func scanTeamMember(row rowScanner, member *conlangdev.TeamMember) error {
+	return row.Scan(
+		&member.ID, &member.CreatedAt, &member.UpdatedAt, &member.TeamID,
+		&member.UserID, &member.Username, &member.Role,
+	)
+}
+
+func (s *TeamService) getTeam(ctx context.Context, where string, arg interface{}) (*conlangdev.Team, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var team conlangdev.Team
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+teamColumns+` FROM teams WHERE `+where+` LIMIT 1`,
+		arg,
+	)
+	if err := scanTeam(row, &team); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that team",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+func (s *TeamService) GetTeamByID(ctx context.Context, id uint) (*conlangdev.Team, error) {
+	return s.getTeam(ctx, "id = ?", id)
+}
+
+func (s *TeamService) GetTeamBySlug(ctx context.Context, slug string) (*conlangdev.Team, error) {
+	return s.getTeam(ctx, "slug = ?", slug)
+}
+
+func (s *TeamService) FindTeamsForUser(ctx context.Context, user *conlangdev.User) ([]*conlangdev.Team, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+teamColumns+` FROM teams
+		WHERE id IN (SELECT team_id FROM team_members WHERE user_id = ?)
+		ORDER BY slug`,
+		user.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := make([]*conlangdev.Team, 0)
+	for rows.Next() {
+		var team conlangdev.Team
+		if err := scanTeam(rows, &team); err != nil {
+			return nil, err
+		}
+		teams = append(teams, &team)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// Creates a team with the given user as its first owner. The team's slug
+// is claimed in the namespace shared with usernames.
+func (s *TeamService) CreateTeam(ctx context.Context, owner *conlangdev.User, create conlangdev.TeamCreate) (*conlangdev.Team, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	team := &conlangdev.Team{}
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO teams (
+			created_at, updated_at, slug, name, description
+		) VALUES (NOW(), NOW(), ?, ?, ?)
+		RETURNING `+teamColumns,
+		create.Slug, create.Name, create.Description,
+	)
+	if err := scanTeam(row, team); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "that name is already taken",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO namespaces (name, team_id) VALUES (?, ?)`,
+		team.Slug, team.ID,
+	); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "that name is already taken",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO team_members (
+			created_at, updated_at, team_id, user_id, role
+		) VALUES (NOW(), NOW(), ?, ?, ?)`,
+		team.ID, owner.ID, conlangdev.TeamRoleOwner,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+func (s *TeamService) UpdateTeam(ctx context.Context, team *conlangdev.Team, update conlangdev.TeamUpdate) error {
+	if err := validateStruct(s.validate, &update); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE teams SET
+			updated_at = NOW(),
+			name = COALESCE(?, name),
+			description = COALESCE(?, description)
+		WHERE id = ?`,
+		update.Name, update.Description, team.ID,
+	); err != nil {
+		return err
+	}
+
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+teamColumns+` FROM teams WHERE id = ? LIMIT 1`,
+		team.ID,
+	)
+	if err := scanTeam(row, team); err == sql.ErrNoRows {
+		return &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that team",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Deletes a team along with every language it owns.
+func (s *TeamService) DeleteTeam(ctx context.Context, team *conlangdev.Team) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM teams WHERE id = ?`, team.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *TeamService) GetMemberForTeam(ctx context.Context, team *conlangdev.Team, user *conlangdev.User) (*conlangdev.TeamMember, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var member conlangdev.TeamMember
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+teamMemberColumns+`
+		FROM team_members m
+		INNER JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = ? AND m.user_id = ?
+		LIMIT 1`,
+		team.ID, user.ID,
+	)
+	if err := scanTeamMember(row, &member); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that team member",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (s *TeamService) FindMembersForTeam(ctx context.Context, team *conlangdev.Team) ([]*conlangdev.TeamMember, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+teamMemberColumns+`
+		FROM team_members m
+		INNER JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = ?
+		ORDER BY m.created_at`,
+		team.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]*conlangdev.TeamMember, 0)
+	for rows.Next() {
+		var member conlangdev.TeamMember
+		if err := scanTeamMember(rows, &member); err != nil {
+			return nil, err
+		}
+		members = append(members, &member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (s *TeamService) AddMember(ctx context.Context, team *conlangdev.Team, create conlangdev.TeamMemberCreate) (*conlangdev.TeamMember, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO team_members (
+			created_at, updated_at, team_id, user_id, role
+		) SELECT NOW(), NOW(), ?, id, ? FROM users WHERE username = ?`,
+		team.ID, create.Role, create.Username,
+	)
+	if err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "that user is already a member of the team",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that user",
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	member := &conlangdev.TeamMember{}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+teamMemberColumns+`
+		FROM team_members m
+		INNER JOIN users u ON u.id = m.user_id
+		WHERE m.id = ?`,
+		id,
+	)
+	if err := scanTeamMember(row, member); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// Checks that a team would still have an owner without the given member.
+func ensureOtherOwner(ctx context.Context, tx *sql.Tx, member *conlangdev.TeamMember) error {
+	if member.Role != conlangdev.TeamRoleOwner {
+		return nil
+	}
+	var owners int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM team_members
+		WHERE team_id = ? AND role = ? AND id != ?
+		FOR UPDATE`,
+		member.TeamID, conlangdev.TeamRoleOwner, member.ID,
+	).Scan(&owners); err != nil {
+		return err
+	}
+	if owners == 0 {
+		return &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "a team must always have at least one owner",
+			StatusCode: http.StatusConflict,
+		}
+	}
+	return nil
+}
+
+func (s *TeamService) UpdateMember(ctx context.Context, member *conlangdev.TeamMember, update conlangdev.TeamMemberUpdate) error {
+	if err := validateStruct(s.validate, &update); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if update.Role != conlangdev.TeamRoleOwner {
+		if err := ensureOtherOwner(ctx, tx, member); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE team_members SET updated_at = NOW(), role = ? WHERE id = ?`,
+		update.Role, member.ID,
+	); err != nil {
+		return err
+	}
+
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+teamMemberColumns+`
+		FROM team_members m
+		INNER JOIN users u ON u.id = m.user_id
+		WHERE m.id = ?`,
+		member.ID,
+	)
+	if err := scanTeamMember(row, member); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *TeamService) RemoveMember(ctx context.Context, member *conlangdev.TeamMember) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := ensureOtherOwner(ctx, tx, member); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM team_members WHERE id = ?`, member.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,286 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-sql-driver/mysql"
+)
+
+type TrashService struct {
+	db        *DB
+	retention time.Duration
+}
+
+func NewTrashService(db *DB, retention time.Duration) *TrashService {
+	return &TrashService{db, retention}
+}
+
+func (s *TrashService) trashedLanguage(language *conlangdev.Language) *conlangdev.TrashedLanguage {
+	return &conlangdev.TrashedLanguage{
+		Language:  language,
+		ExpiresAt: language.DeletedAt.Add(s.retention),
+	}
+}
+
+func (s *TrashService) trashedWord(word *conlangdev.Word) *conlangdev.TrashedWord {
+	return &conlangdev.TrashedWord{
+		Word:      word,
+		ExpiresAt: word.DeletedAt.Add(s.retention),
+	}
+}
+
+func (s *TrashService) findTrashedLanguages(ctx context.Context, query string, args ...interface{}) ([]*conlangdev.TrashedLanguage, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	languages, err := queryLanguages(ctx, tx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	trashed := make([]*conlangdev.TrashedLanguage, 0, len(languages))
+	for _, language := range languages {
+		trashed = append(trashed, s.trashedLanguage(language))
+	}
+	return trashed, nil
+}
+
+func (s *TrashService) FindTrashedLanguagesForUser(ctx context.Context, user *conlangdev.User) ([]*conlangdev.TrashedLanguage, error) {
+	return s.findTrashedLanguages(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE user_id = ? AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC`,
+		user.ID,
+	)
+}
+
+func (s *TrashService) FindTrashedLanguagesForTeam(ctx context.Context, team *conlangdev.Team) ([]*conlangdev.TrashedLanguage, error) {
+	return s.findTrashedLanguages(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE team_id = ? AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC`,
+		team.ID,
+	)
+}
+
+func (s *TrashService) GetTrashedLanguageByNamespaceAndSlug(ctx context.Context, namespace string, slug string) (*conlangdev.TrashedLanguage, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var language conlangdev.Language
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE slug = ? AND (
+			user_id = (SELECT user_id FROM namespaces WHERE name = ?) OR
+			team_id = (SELECT team_id FROM namespaces WHERE name = ?)
+		) AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC LIMIT 1`,
+		slug, namespace, namespace,
+	)
+	if err := scanLanguage(row, &language); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that language in the trash",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return s.trashedLanguage(&language), nil
+}
+
+func (s *TrashService) FindTrashedWordsForLanguage(ctx context.Context, language *conlangdev.Language) ([]*conlangdev.TrashedWord, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE language_id = ? AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC`,
+		language.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := make([]*conlangdev.TrashedWord, 0)
+	for rows.Next() {
+		var word conlangdev.Word
+		if err := scanWord(rows, &word); err != nil {
+			return nil, err
+		}
+		words = append(words, s.trashedWord(&word))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
+
+func (s *TrashService) GetTrashedWordByLanguageAndUID(ctx context.Context, language *conlangdev.Language, uid uint64) (*conlangdev.TrashedWord, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var word conlangdev.Word
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE uid = ? AND language_id = ? AND deleted_at IS NOT NULL
+		LIMIT 1`,
+		uid, language.ID,
+	)
+	if err := scanWord(row, &word); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that word in the trash",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return s.trashedWord(&word), nil
+}
+
+func (s *TrashService) RestoreLanguage(ctx context.Context, language *conlangdev.Language) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Trashed languages give up their slug, so another language may have
+	// taken it since
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE languages SET
+			deleted_at = NULL, version = version + 1
+		WHERE id = ?`,
+		language.ID,
+	); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "there is already a language with that slug, rename it before restoring this one",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE id = ? LIMIT 1`,
+		language.ID,
+	)
+	if err := scanLanguage(row, language); err != nil {
+		return err
+	}
+
+	if err := insertAuditEntry(ctx, tx, language.ID, conlangdev.AuditRestore,
+		0, "", "restored from the trash",
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Takes a word out of the trash, recording the restoration in its revision
+// history.
+func (s *TrashService) RestoreWord(ctx context.Context, word *conlangdev.Word) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE words SET
+			deleted_at = NULL, version = version + 1
+		WHERE id = ?`,
+		word.ID,
+	); err != nil {
+		return err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE id = ? LIMIT 1`,
+		word.ID,
+	)
+	if err := scanWord(row, word); err != nil {
+		return err
+	}
+
+	if err := insertWordRevision(ctx, tx, word, conlangdev.RevisionRestore); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Purged words take their revision history with them, so they cannot be
+// brought back by restoring an earlier revision either. Purged languages
+// lose their words and history through the foreign key cascades.
+func (s *TrashService) PurgeExpired(ctx context.Context) (int64, int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	seconds := int64(s.retention / time.Second)
+	if _, err := tx.ExecContext(ctx,
+		`DELETE r FROM word_revisions r
+		INNER JOIN words w ON w.uid = r.word_uid
+		WHERE w.deleted_at < NOW() - INTERVAL ? SECOND`,
+		seconds,
+	); err != nil {
+		return 0, 0, err
+	}
+	result, err := tx.ExecContext(ctx,
+		`DELETE FROM words WHERE deleted_at < NOW() - INTERVAL ? SECOND`,
+		seconds,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	words, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	result, err = tx.ExecContext(ctx,
+		`DELETE FROM languages WHERE deleted_at < NOW() - INTERVAL ? SECOND`,
+		seconds,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	languages, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return languages, words, nil
+}
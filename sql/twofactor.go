@@ -0,0 +1,375 @@
+package sql
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/conlangdev/conlangdev/totp"
+)
+
+type TwoFactorService struct {
+	db *DB
+}
+
+func NewTwoFactorService(db *DB) *TwoFactorService {
+	return &TwoFactorService{db}
+}
+
+var errIncorrectCode = &conlangdev.Error{
+	Code:       conlangdev.EUNAUTHORIZED,
+	Message:    "incorrect code",
+	StatusCode: http.StatusUnauthorized,
+}
+
+var errTwoFactorNotEnabled = &conlangdev.Error{
+	Code:       conlangdev.EBADREQUEST,
+	Message:    "two-factor authentication is not enabled",
+	StatusCode: http.StatusBadRequest,
+}
+
+var errTwoFactorEnabled = &conlangdev.Error{
+	Code:       conlangdev.ECONFLICT,
+	Message:    "two-factor authentication is already enabled",
+	StatusCode: http.StatusConflict,
+}
+
+// Recovery codes are compared without the dash they are shown with, and
+// regardless of case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ToLower(code)
+}
+
+// Replaces the recovery codes of a user, giving the new ones.
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID uint) ([]string, error) {
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+		userID,
+	); err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, conlangdev.RecoveryCodeCount)
+	for i := 0; i < conlangdev.RecoveryCodeCount; i++ {
+		buffer := make([]byte, 5)
+		if _, err := rand.Read(buffer); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(buffer))
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`,
+			userID, hashToken(code),
+		); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code[:4]+"-"+code[4:])
+	}
+	return codes, nil
+}
+
+// Checks a code from an authenticator app against a secret, giving the
+// period it belongs to. Codes from the last period used or any before it
+// are refused, so that a code cannot be replayed.
+func validateTOTPCode(secret string, code string, lastStep int64, now time.Time) (int64, bool) {
+	step, ok := totp.Validate(secret, code, now)
+	if !ok || step <= lastStep {
+		return 0, false
+	}
+	return step, true
+}
+
+// Checks a code from an authenticator app against the secret of a user,
+// refusing codes from a period which has already been used.
+func checkTOTPCode(ctx context.Context, tx *sql.Tx, userID uint, code string) (bool, error) {
+	var secret string
+	var lastStep int64
+	if err := tx.QueryRowContext(ctx,
+		`SELECT secret, last_step FROM two_factor_secrets
+		WHERE user_id = ? LIMIT 1 FOR UPDATE`,
+		userID,
+	).Scan(&secret, &lastStep); err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	step, ok := validateTOTPCode(secret, code, lastStep, time.Now())
+	if !ok {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE two_factor_secrets SET last_step = ? WHERE user_id = ?`,
+		step, userID,
+	); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Checks a code from an authenticator app or a recovery code for a user
+// within a transaction, using up the code if it is right.
+func checkTwoFactorCode(ctx context.Context, tx *sql.Tx, userID uint, code string) error {
+	if ok, err := checkTOTPCode(ctx, tx, userID, code); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+		LIMIT 1`,
+		userID, hashToken(normalizeRecoveryCode(code)),
+	)
+	if err != nil {
+		return err
+	}
+	if used, err := result.RowsAffected(); err != nil {
+		return err
+	} else if used == 0 {
+		return errIncorrectCode
+	}
+	return nil
+}
+
+func (s *TwoFactorService) SetupTwoFactor(ctx context.Context, user *conlangdev.User) (*conlangdev.TwoFactorSetup, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := getUserByID(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current.TwoFactorEnabled() {
+		return nil, errTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`REPLACE INTO two_factor_secrets (user_id, created_at, secret)
+		VALUES (?, NOW(), ?)`,
+		user.ID, secret,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &conlangdev.TwoFactorSetup{
+		Secret: secret,
+		URI:    totp.URI("conlangdev", user.Username, secret),
+	}, nil
+}
+
+func (s *TwoFactorService) EnableTwoFactor(ctx context.Context, user *conlangdev.User, code string) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := getUserByID(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current.TwoFactorEnabled() {
+		return nil, errTwoFactorEnabled
+	}
+
+	// Only the app can be used here, as there are no recovery codes yet
+	if ok, err := checkTOTPCode(ctx, tx, user.ID, code); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errIncorrectCode
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET two_factor_enabled_at = NOW() WHERE id = ?`,
+		user.ID,
+	); err != nil {
+		return nil, err
+	}
+	codes, err := replaceRecoveryCodes(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *TwoFactorService) DisableTwoFactor(ctx context.Context, user *conlangdev.User) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET two_factor_enabled_at = NULL WHERE id = ?`,
+		user.ID,
+	); err != nil {
+		return err
+	}
+	for _, query := range []string{
+		`DELETE FROM two_factor_secrets WHERE user_id = ?`,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+		`DELETE FROM two_factor_challenges WHERE user_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, user.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, user *conlangdev.User) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := getUserByID(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !current.TwoFactorEnabled() {
+		return nil, errTwoFactorNotEnabled
+	}
+	codes, err := replaceRecoveryCodes(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *TwoFactorService) CheckTwoFactorCode(ctx context.Context, user *conlangdev.User, code string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTwoFactorCode(ctx, tx, user.ID, code); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *TwoFactorService) CreateTwoFactorChallenge(ctx context.Context, user *conlangdev.User) (string, error) {
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Clear out challenges which were never finished
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM two_factor_challenges WHERE expires_at < NOW()`,
+	); err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO two_factor_challenges (
+			created_at, expires_at, user_id, token_hash
+		) VALUES (NOW(), NOW() + INTERVAL ? SECOND, ?, ?)`,
+		int64(conlangdev.TwoFactorChallengeLifetime/time.Second), user.ID, hashToken(token),
+	); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *TwoFactorService) CompleteTwoFactorChallenge(ctx context.Context, challenge string, code string) (*conlangdev.User, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var challengeID, userID uint
+	if err := tx.QueryRowContext(ctx,
+		`SELECT id, user_id FROM two_factor_challenges
+		WHERE token_hash = ? AND expires_at > NOW() AND attempts < ?
+		LIMIT 1 FOR UPDATE`,
+		hashToken(challenge), conlangdev.TwoFactorChallengeAttempts,
+	).Scan(&challengeID, &userID); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EUNAUTHORIZED,
+			Message:    "that login has expired, log in again",
+			StatusCode: http.StatusUnauthorized,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := checkTwoFactorCode(ctx, tx, userID, code); err == errIncorrectCode {
+		// Wrong codes count against the challenge, so it cannot be used to
+		// try every code
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE two_factor_challenges SET attempts = attempts + 1 WHERE id = ?`,
+			challengeID,
+		); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, errIncorrectCode
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM two_factor_challenges WHERE id = ?`,
+		challengeID,
+	); err != nil {
+		return nil, err
+	}
+	user, err := getUserByID(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
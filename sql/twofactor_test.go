@@ -0,0 +1,60 @@
+package sql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/conlangdev/conlangdev/totp"
+)
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111111, 0)
+	step := totp.Step(now)
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := totp.Code(secret, step-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		ok       bool
+	}{
+		{"never used", code, 0, true},
+		{"earlier period used", code, step - 1, true},
+		{"same period used", code, step, false},
+		{"later period used", code, step + 1, false},
+		{"code from before the last period used", previous, step, false},
+		{"wrong code", "000000", 0, false},
+	}
+	for _, test := range tests {
+		_, ok := validateTOTPCode(secret, test.code, test.lastStep, now)
+		if ok != test.ok {
+			t.Errorf("%s: got %t, want %t", test.name, ok, test.ok)
+		}
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		code       string
+		normalized string
+	}{
+		{"abcd-efgh", "abcdefgh"},
+		{"ABCD-EFGH", "abcdefgh"},
+		{"abcdefgh", "abcdefgh"},
+		{" abcd - efgh ", "abcdefgh"},
+		{"ab-cd-ef-gh", "abcdefgh"},
+	}
+	for _, test := range tests {
+		if normalized := normalizeRecoveryCode(test.code); normalized != test.normalized {
+			t.Errorf("%q: got %q, want %q", test.code, normalized, test.normalized)
+		}
+	}
+}
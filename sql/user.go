@@ -3,32 +3,93 @@ package sql
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/conlangdev/conlangdev"
 	"github.com/go-playground/validator/v10"
 	"github.com/go-sql-driver/mysql"
-	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	db        *DB
-	validate  *validator.Validate
-	jwtSecret []byte
+	db       *DB
+	validate *validator.Validate
 }
 
-type customClaim struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	jwt.StandardClaims
+func NewUserService(db *DB, validate *validator.Validate) *UserService {
+	return &UserService{db, validate}
 }
 
-func NewUserService(db *DB, validate *validator.Validate, jwtSecret string) *UserService {
-	return &UserService{db, validate, []byte(jwtSecret)}
+// Column list matching the order expected by scanUser.
+const userColumns = `id, created_at, updated_at, username, role, email,
+	email_verified_at, display_name, password_hash, two_factor_enabled_at,
+	bio, avatar_url, links, privacy, profile_hidden_at, suspended_at,
+	suspension_reason, deleted_at`
+
+func scanUser(row rowScanner, user *conlangdev.User) error {
+	var links, privacy []byte
+	if err := row.Scan(
+		&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Username, &user.Role,
+		&user.Email, &user.EmailVerifiedAt, &user.DisplayName, &user.PasswordHash,
+		&user.TwoFactorEnabledAt, &user.Bio, &user.AvatarURL, &links, &privacy,
+		&user.ProfileHiddenAt, &user.SuspendedAt, &user.SuspensionReason,
+		&user.DeletedAt,
+	); err != nil {
+		return err
+	}
+	var err error
+	if user.Links, err = decodeTags(links); err != nil {
+		return err
+	}
+	user.Privacy, err = decodePrivacy(privacy)
+	return err
+}
+
+// Decodes privacy settings from a JSON column. Settings missing from it
+// keep their defaults.
+func decodePrivacy(data []byte) (conlangdev.ProfilePrivacy, error) {
+	privacy := conlangdev.DefaultProfilePrivacy
+	if len(data) == 0 {
+		return privacy, nil
+	}
+	err := json.Unmarshal(data, &privacy)
+	return privacy, err
+}
+
+// Applies an update to privacy settings, leaving out settings which are not
+// being changed.
+func updatePrivacy(privacy conlangdev.ProfilePrivacy, update conlangdev.ProfilePrivacyUpdate) conlangdev.ProfilePrivacy {
+	for _, setting := range []struct {
+		value  *bool
+		update *bool
+	}{
+		{&privacy.ShowBio, update.ShowBio},
+		{&privacy.ShowAvatar, update.ShowAvatar},
+		{&privacy.ShowLinks, update.ShowLinks},
+		{&privacy.ShowJoinDate, update.ShowJoinDate},
+		{&privacy.ShowLanguages, update.ShowLanguages},
+	} {
+		if setting.update != nil {
+			*setting.value = *setting.update
+		}
+	}
+	return privacy
+}
+
+// Loads a user within a transaction.
+func getUserByID(ctx context.Context, tx *sql.Tx, id uint) (*conlangdev.User, error) {
+	var user conlangdev.User
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+userColumns+`
+		FROM users WHERE id = ? LIMIT 1`,
+		id,
+	)
+	if err := scanUser(row, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
 // Generates a string hash based on the given raw password.
@@ -50,16 +111,12 @@ func (s *UserService) GetUserByID(ctx context.Context, id uint) (*conlangdev.Use
 	defer tx.Rollback()
 
 	var user conlangdev.User
-	if err := tx.QueryRowContext(ctx,
-		`SELECT
-			id, created_at, updated_at, username,
-			email, display_name, password_hash
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+userColumns+`
 		FROM users WHERE id = ? LIMIT 1`,
 		id,
-	).Scan(
-		&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Username,
-		&user.Email, &user.DisplayName, &user.PasswordHash,
-	); err == sql.ErrNoRows {
+	)
+	if err := scanUser(row, &user); err == sql.ErrNoRows {
 		return nil, &conlangdev.Error{
 			Code:       conlangdev.ENOTFOUND,
 			Message:    "could not find that user",
@@ -81,16 +138,12 @@ func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*
 	defer tx.Rollback()
 
 	var user conlangdev.User
-	if err := tx.QueryRowContext(ctx,
-		`SELECT
-			id, created_at, updated_at, username,
-			email, display_name, password_hash
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+userColumns+`
 		FROM users WHERE username = ? LIMIT 1`,
 		username,
-	).Scan(
-		&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Username,
-		&user.Email, &user.DisplayName, &user.PasswordHash,
-	); err == sql.ErrNoRows {
+	)
+	if err := scanUser(row, &user); err == sql.ErrNoRows {
 		return nil, &conlangdev.Error{
 			Code:       conlangdev.ENOTFOUND,
 			Message:    "could not find that user",
@@ -103,82 +156,11 @@ func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*
 	return &user, nil
 }
 
-func (s *UserService) GetUserByJWT(ctx context.Context, tokenString string) (*conlangdev.User, error) {
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&customClaim{},
-		func(token *jwt.Token) (interface{}, error) {
-			return s.jwtSecret, nil
-		},
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	claims, ok := token.Claims.(*customClaim)
-	if !ok {
-		return nil, errors.New("could not parse JWT claims")
-	}
-
-	if claims.ExpiresAt < time.Now().Local().Unix() {
-		return nil, errors.New("JWT token has expired")
-	}
-
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	var user conlangdev.User
-	if err := tx.QueryRowContext(ctx,
-		`SELECT
-			id, created_at, updated_at, username,
-			email, display_name, password_hash
-		FROM users WHERE id = ? AND username = ?
-		LIMIT 1`,
-		claims.UserID, claims.Username,
-	).Scan(
-		&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Username,
-		&user.Email, &user.DisplayName, &user.PasswordHash,
-	); err != nil {
-		return nil, errors.New("JWT could not be associated with a user")
-	}
-
-	return &user, nil
-}
-
-func (s *UserService) GenerateJWTForUser(ctx context.Context, user *conlangdev.User) (string, error) {
-	claims := &customClaim{
-		UserID:   user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		StandardClaims: jwt.StandardClaims{
-			Issuer:    "conlangdev",
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.jwtSecret)
-}
-
 // Creates a new user in the database according to the given parameters in the
 // UserCreate DTO.
 func (s *UserService) CreateUser(ctx context.Context, create conlangdev.UserCreate) (*conlangdev.User, error) {
 	// Validate fields on create user DTO
-	if err := s.validate.Struct(&create); err != nil {
-		if val_err, ok := err.(validator.ValidationErrors); ok {
-			var fields []string
-			for _, field := range val_err {
-				fields = append(fields, field.Field())
-			}
-			return nil, &conlangdev.FieldsError{
-				Code:       conlangdev.EVALIDFAIL,
-				Message:    "validation failed",
-				StatusCode: http.StatusBadRequest,
-				Fields:     fields,
-			}
-		}
+	if err := validateStruct(s.validate, &create); err != nil {
 		return nil, err
 	}
 	// Hash password
@@ -191,22 +173,35 @@ func (s *UserService) CreateUser(ctx context.Context, create conlangdev.UserCrea
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+	user, err := insertUser(ctx, tx, create, hash, conlangdev.UserRoleUser, false)
+	if err != nil {
+		return nil, err
+	}
+	// Commit transaction!
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Inserts a user with the given site-wide role and claims their username,
+// marking their email address as verified if asked to.
+func insertUser(ctx context.Context, tx *sql.Tx, create conlangdev.UserCreate, hash string, role string, verified bool) (*conlangdev.User, error) {
 	// Insert the user into the database, scanning the inserted object back
 	// into a new user object
 	user := &conlangdev.User{}
-	if err := tx.QueryRowContext(
+	row := tx.QueryRowContext(
 		ctx,
 		`INSERT INTO users (
-			created_at, updated_at, username,
-			email, display_name, password_hash
-		) VALUES (NOW(), NOW(), ?, ?, ?, ?) RETURNING
-			id, created_at, updated_at, username,
-			email, display_name, password_hash`,
-		create.Username, create.Email, create.DisplayName, hash,
-	).Scan(
-		&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Username,
-		&user.Email, &user.DisplayName, &user.PasswordHash,
-	); err != nil {
+			created_at, updated_at, username, email, display_name,
+			password_hash, role, email_verified_at
+		) VALUES (
+			NOW(), NOW(), ?, ?, ?, ?, ?, IF(?, NOW(), NULL)
+		) RETURNING `+userColumns,
+		create.Username, create.Email, create.DisplayName, hash, role, verified,
+	)
+	if err := scanUser(row, user); err != nil {
 		if sql_err, ok := err.(*mysql.MySQLError); ok {
 			if sql_err.Number == 1062 {
 				return nil, &conlangdev.Error{
@@ -218,23 +213,366 @@ func (s *UserService) CreateUser(ctx context.Context, create conlangdev.UserCrea
 		}
 		return nil, err
 	}
-	// Commit transaction!
-	if err := tx.Commit(); err != nil {
+	// Claim the username in the namespace shared with teams
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO namespaces (name, user_id) VALUES (?, ?)`,
+		user.Username, user.ID,
+	); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "that username is already taken by a team",
+				StatusCode: http.StatusConflict,
+			}
+		}
 		return nil, err
 	}
 	return user, nil
 }
 
 func (s *UserService) UpdateUser(ctx context.Context, user *conlangdev.User, update conlangdev.UserUpdate) error {
-	return errors.New("not implemented")
+	if err := validateStruct(s.validate, &update); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var links interface{}
+	if update.Links != nil {
+		links = encodeTags(uniqueTags(*update.Links))
+	}
+	var privacy interface{}
+	if update.Privacy != nil {
+		// Merge with the current settings so that only those given change
+		current, err := getUserByID(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(updatePrivacy(current.Privacy, *update.Privacy))
+		if err != nil {
+			return err
+		}
+		privacy = string(encoded)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET
+			updated_at = NOW(),
+			display_name = COALESCE(?, display_name),
+			bio = COALESCE(?, bio),
+			avatar_url = COALESCE(?, avatar_url),
+			links = COALESCE(?, links),
+			privacy = COALESCE(?, privacy)
+		WHERE id = ?`,
+		update.DisplayName, update.Bio, update.AvatarURL, links, privacy, user.ID,
+	); err != nil {
+		return err
+	}
+
+	// MariaDB has no UPDATE ... RETURNING, so read the user back
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+userColumns+`
+		FROM users WHERE id = ? LIMIT 1`,
+		user.ID,
+	)
+	if err := scanUser(row, user); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Languages removed along with a user: their own, and those of teams only
+// they belong to.
+const deletedUserLanguagesCondition = `(
+	user_id = ? OR team_id IN (
+		SELECT team_id FROM team_members GROUP BY team_id
+		HAVING COUNT(*) = 1 AND MAX(user_id) = ?
+	)
+)`
+
+func getDeletionForUser(ctx context.Context, tx *sql.Tx, user *conlangdev.User) (*conlangdev.AccountDeletion, error) {
+	deletion := &conlangdev.AccountDeletion{
+		PurgeAt: time.Now().Add(conlangdev.AccountDeletionGracePeriod),
+	}
+	if user.DeletedAt != nil {
+		deletion.PurgeAt = user.DeletedAt.Add(conlangdev.AccountDeletionGracePeriod)
+	}
+
+	// Languages already moved to the trash along with the user still count
+	trashed := "deleted_at IS NULL"
+	args := []interface{}{user.ID, user.ID}
+	if user.DeletedAt != nil {
+		trashed = "(deleted_at IS NULL OR deleted_at = ?)"
+		args = append(args, user.DeletedAt)
+	}
+	var err error
+	if deletion.Languages, err = queryLanguages(ctx, tx,
+		`SELECT `+languageColumns+`
+		FROM languages WHERE `+deletedUserLanguagesCondition+` AND `+trashed+`
+		ORDER BY slug`,
+		args...,
+	); err != nil {
+		return nil, err
+	}
+	for _, language := range deletion.Languages {
+		var count int
+		if err := tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM words
+			WHERE language_id = ? AND branch_id IS NULL AND deleted_at IS NULL`,
+			language.ID,
+		).Scan(&count); err != nil {
+			return nil, err
+		}
+		deletion.WordCount += count
+	}
+
+	if deletion.Teams, err = queryTeams(ctx, tx,
+		`SELECT `+teamColumns+` FROM teams WHERE id IN (
+			SELECT team_id FROM team_members GROUP BY team_id
+			HAVING COUNT(*) = 1 AND MAX(user_id) = ?
+		) ORDER BY slug`,
+		user.ID,
+	); err != nil {
+		return nil, err
+	}
+	if deletion.BlockingTeams, err = queryTeams(ctx, tx,
+		`SELECT `+teamColumns+` FROM teams t WHERE EXISTS (
+			SELECT 1 FROM team_members
+			WHERE team_id = t.id AND user_id = ? AND role = ?
+		) AND NOT EXISTS (
+			SELECT 1 FROM team_members
+			WHERE team_id = t.id AND user_id <> ? AND role = ?
+		) AND EXISTS (
+			SELECT 1 FROM team_members WHERE team_id = t.id AND user_id <> ?
+		) ORDER BY slug`,
+		user.ID, conlangdev.TeamRoleOwner, user.ID, conlangdev.TeamRoleOwner, user.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+func (s *UserService) GetDeletionForUser(ctx context.Context, user *conlangdev.User) (*conlangdev.AccountDeletion, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return getDeletionForUser(ctx, tx, user)
 }
 
 func (s *UserService) DeleteUser(ctx context.Context, user *conlangdev.User) error {
-	return errors.New("not implemented")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := getUserByID(ctx, tx, user.ID)
+	if err != nil {
+		return err
+	}
+	if current.DeletedAt != nil {
+		return &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "that account is already being deleted",
+			StatusCode: http.StatusConflict,
+		}
+	}
+	deletion, err := getDeletionForUser(ctx, tx, current)
+	if err != nil {
+		return err
+	}
+	if len(deletion.BlockingTeams) > 0 {
+		return &conlangdev.Error{
+			Code:       conlangdev.ECONFLICT,
+			Message:    "make someone else an owner of " + deletion.BlockingTeams[0].Slug + " before deleting your account",
+			StatusCode: http.StatusConflict,
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET deleted_at = NOW() WHERE id = ?`,
+		user.ID,
+	); err != nil {
+		return err
+	}
+	if current, err = getUserByID(ctx, tx, user.ID); err != nil {
+		return err
+	}
+	// Languages are trashed at the exact time the user was deleted, so
+	// that cancelling the deletion brings back only those
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE languages SET deleted_at = ?
+		WHERE `+deletedUserLanguagesCondition+` AND deleted_at IS NULL`,
+		current.DeletedAt, user.ID, user.ID,
+	); err != nil {
+		return err
+	}
+	if err := revokeSessionsForUser(ctx, tx, user.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE api_tokens SET revoked_at = NOW()
+		WHERE user_id = ? AND revoked_at IS NULL`,
+		user.ID,
+	); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	*user = *current
+	return nil
+}
+
+func (s *UserService) RestoreUser(ctx context.Context, user *conlangdev.User) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := getUserByID(ctx, tx, user.ID)
+	if err != nil {
+		return err
+	}
+	if current.DeletedAt == nil {
+		return &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "that account is not being deleted",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE languages SET deleted_at = NULL
+		WHERE `+deletedUserLanguagesCondition+` AND deleted_at = ?`,
+		user.ID, user.ID, current.DeletedAt,
+	); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "a language made since this account was deleted has the same slug as one of its languages",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET deleted_at = NULL WHERE id = ?`,
+		user.ID,
+	); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	user.DeletedAt = nil
+	return nil
+}
+
+func (s *UserService) PurgeDeletedUsers(ctx context.Context) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	seconds := int64(conlangdev.AccountDeletionGracePeriod / time.Second)
+	// Teams only the purged users belong to go with them, which takes
+	// their languages along
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM teams WHERE id IN (
+			SELECT team_id FROM (
+				SELECT m.team_id FROM team_members m
+				INNER JOIN users u ON u.id = m.user_id
+				GROUP BY m.team_id
+				HAVING COUNT(*) = SUM(u.deleted_at < NOW() - INTERVAL ? SECOND)
+			) AS abandoned
+		)`,
+		seconds,
+	); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx,
+		`DELETE FROM users WHERE deleted_at < NOW() - INTERVAL ? SECOND`,
+		seconds,
+	)
+	if err != nil {
+		return 0, err
+	}
+	users, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return users, nil
 }
 
+// Sets a new password for a user, logging them out of every session so that
+// anyone who knew the old password loses access.
 func (s *UserService) UpdateUserPassword(ctx context.Context, user *conlangdev.User, password string) error {
-	return errors.New("not implemented")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	hash, err := setUserPassword(ctx, tx, s.validate, user.ID, password)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	user.PasswordHash = hash
+	return nil
+}
+
+// Validates and hashes a new password for a user within a transaction,
+// revoking every session of the user, and gives the new hash.
+func setUserPassword(ctx context.Context, tx *sql.Tx, validate *validator.Validate, userID uint, password string) (string, error) {
+	if err := validate.Var(password, "min=8"); err != nil {
+		return "", &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "validation failed",
+			StatusCode: http.StatusBadRequest,
+			Fields:     []string{"Password"},
+		}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET updated_at = NOW(), password_hash = ? WHERE id = ?`,
+		string(hash), userID,
+	); err != nil {
+		return "", err
+	}
+	if err := revokeSessionsForUser(ctx, tx, userID); err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
 }
 
 func (s *UserService) CheckUserPassword(ctx context.Context, user *conlangdev.User, password string) error {
@@ -242,8 +580,76 @@ func (s *UserService) CheckUserPassword(ctx context.Context, user *conlangdev.Us
 }
 
 func (s *UserService) GetViewForUser(ctx context.Context, user *conlangdev.User) (*conlangdev.UserView, error) {
-	return &conlangdev.UserView{
+	view := &conlangdev.UserView{
 		Username:    user.Username,
 		DisplayName: user.DisplayName,
-	}, nil
+	}
+	// Profiles hidden by an administrator only keep their names
+	visible := user.ProfileHiddenAt == nil
+	if visible && user.Privacy.ShowBio {
+		view.Bio = user.Bio
+	}
+	if visible && user.Privacy.ShowAvatar {
+		view.AvatarURL = user.AvatarURL
+	}
+	if visible && user.Privacy.ShowLinks {
+		view.Links = user.Links
+	}
+	if user.Privacy.ShowJoinDate {
+		joinedAt := user.CreatedAt
+		view.JoinedAt = &joinedAt
+	}
+	return view, nil
+}
+
+func (s *UserService) GetProfileForUser(ctx context.Context, user *conlangdev.User) (*conlangdev.UserProfile, error) {
+	view, err := s.GetViewForUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	profile := &conlangdev.UserProfile{UserView: *view}
+	if !user.Privacy.ShowLanguages {
+		return profile, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Only languages anybody could find are listed, counting the words on
+	// their main line
+	rows, err := tx.QueryContext(ctx,
+		`SELECT l.name, l.slug, l.endonym, l.updated_at, (
+			SELECT COUNT(*) FROM words w
+			WHERE w.language_id = l.id AND w.branch_id IS NULL AND w.deleted_at IS NULL
+		)
+		FROM languages l
+		WHERE l.user_id = ? AND l.visibility = ?
+			AND l.hidden_at IS NULL AND l.deleted_at IS NULL
+		ORDER BY l.name`,
+		user.ID, conlangdev.VisibilityPublic,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profile.Languages = make([]*conlangdev.ProfileLanguage, 0)
+	for rows.Next() {
+		var language conlangdev.ProfileLanguage
+		if err := rows.Scan(
+			&language.Name, &language.Slug, &language.Endonym,
+			&language.UpdatedAt, &language.WordCount,
+		); err != nil {
+			return nil, err
+		}
+		profile.Languages = append(profile.Languages, &language)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
 }
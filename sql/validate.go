@@ -0,0 +1,29 @@
+package sql
+
+import (
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validates a DTO, converting any validation errors into a
+// `conlangdev.FieldsError` listing the offending fields.
+func validateStruct(validate *validator.Validate, s interface{}) error {
+	if err := validate.Struct(s); err != nil {
+		if val_err, ok := err.(validator.ValidationErrors); ok {
+			var fields []string
+			for _, field := range val_err {
+				fields = append(fields, field.Field())
+			}
+			return &conlangdev.FieldsError{
+				Code:       conlangdev.EVALIDFAIL,
+				Message:    "validation failed",
+				StatusCode: http.StatusBadRequest,
+				Fields:     fields,
+			}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package sql
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/conlangdev/conlangdev"
+)
+
+// Checks that an update made only against the version of a row the caller
+// loaded went through, reporting a conflicting change if it did not.
+func checkVersionUpdated(result sql.Result, resource string) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &conlangdev.Error{
+			Code:       conlangdev.EPRECONDITIONFAILED,
+			Message:    "that " + resource + " has been changed since it was loaded",
+			StatusCode: http.StatusPreconditionFailed,
+		}
+	}
+	return nil
+}
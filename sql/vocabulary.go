@@ -0,0 +1,467 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/conlangdev/conlangdev"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+)
+
+type VocabularyService struct {
+	db       *DB
+	validate *validator.Validate
+}
+
+// Column list matching the order expected by scanVocabularyTerm.
+const vocabularyTermColumns = `id, created_at, updated_at, language_id,
+	category, value, label, abbreviation, aliases`
+
+// The categories of vocabulary, which are also the names of the word
+// columns they constrain.
+var vocabularyCategories = []string{
+	conlangdev.VocabularyPartOfSpeech,
+	conlangdev.VocabularyGender,
+	conlangdev.VocabularyGrammarClass,
+}
+
+func NewVocabularyService(db *DB, validate *validator.Validate) *VocabularyService {
+	return &VocabularyService{db, validate}
+}
+
+func scanVocabularyTerm(row rowScanner, term *conlangdev.VocabularyTerm) error {
+	var aliases []byte
+	if err := row.Scan(
+		&term.ID, &term.CreatedAt, &term.UpdatedAt, &term.LanguageID,
+		&term.Category, &term.Value, &term.Label, &term.Abbreviation, &aliases,
+	); err != nil {
+		return err
+	}
+	var err error
+	term.Aliases, err = decodeTags(aliases)
+	return err
+}
+
+func queryVocabularyTerms(ctx context.Context, tx *sql.Tx, languageID uint) ([]*conlangdev.VocabularyTerm, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+vocabularyTermColumns+`
+		FROM language_vocabulary WHERE language_id = ?
+		ORDER BY category, value`,
+		languageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terms := make([]*conlangdev.VocabularyTerm, 0)
+	for rows.Next() {
+		var term conlangdev.VocabularyTerm
+		if err := scanVocabularyTerm(rows, &term); err != nil {
+			return nil, err
+		}
+		terms = append(terms, &term)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return terms, nil
+}
+
+// The terms of a language by category, with every way of writing each
+// term mapped onto its value.
+type vocabulary map[string]map[string]string
+
+// Gives the form of a value used to match it against terms, so that "N."
+// and "n" are taken to be the same.
+func vocabularyKey(value string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(value), "."))
+}
+
+func loadVocabulary(ctx context.Context, tx *sql.Tx, languageID uint) (vocabulary, error) {
+	terms, err := queryVocabularyTerms(ctx, tx, languageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Values are added last so that they win over any other term which
+	// uses them as an abbreviation or alias
+	v := make(vocabulary)
+	for _, term := range terms {
+		if v[term.Category] == nil {
+			v[term.Category] = make(map[string]string)
+		}
+		for _, alias := range term.Aliases {
+			v[term.Category][vocabularyKey(alias)] = term.Value
+		}
+		if term.Label != "" {
+			v[term.Category][vocabularyKey(term.Label)] = term.Value
+		}
+		if term.Abbreviation != "" {
+			v[term.Category][vocabularyKey(term.Abbreviation)] = term.Value
+		}
+	}
+	for _, term := range terms {
+		v[term.Category][vocabularyKey(term.Value)] = term.Value
+	}
+	return v, nil
+}
+
+// Gives the term value for a value given in a category, and whether it
+// is allowed at all. Empty values are left to the validation of the word.
+func (v vocabulary) resolve(category string, value string) (string, bool) {
+	terms, ok := v[category]
+	if !ok || value == "" {
+		return value, true
+	}
+	resolved, ok := terms[vocabularyKey(value)]
+	return resolved, ok
+}
+
+// Checks the part of speech, gender and grammar class given for a word
+// against the vocabulary of its language, replacing each with the value
+// of the term it matches. Values which are not given are skipped.
+func checkVocabulary(ctx context.Context, tx *sql.Tx, languageID uint, partOfSpeech *string, gender *string, grammarClass *string) error {
+	v, err := loadVocabulary(ctx, tx, languageID)
+	if err != nil {
+		return err
+	}
+
+	values := []struct {
+		field    string
+		category string
+		value    *string
+	}{
+		{"PartOfSpeech", conlangdev.VocabularyPartOfSpeech, partOfSpeech},
+		{"Gender", conlangdev.VocabularyGender, gender},
+		{"GrammarClass", conlangdev.VocabularyGrammarClass, grammarClass},
+	}
+	var invalid []string
+	for _, value := range values {
+		if value.value == nil {
+			continue
+		}
+		resolved, ok := v.resolve(value.category, *value.value)
+		if !ok {
+			invalid = append(invalid, value.field)
+			continue
+		}
+		*value.value = resolved
+	}
+
+	if len(invalid) > 0 {
+		return &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "values must be among those defined for this language",
+			StatusCode: http.StatusBadRequest,
+			Fields:     invalid,
+		}
+	}
+	return nil
+}
+
+func (s *VocabularyService) FindTermsForLanguage(ctx context.Context, language *conlangdev.Language) ([]*conlangdev.VocabularyTerm, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return queryVocabularyTerms(ctx, tx, language.ID)
+}
+
+func (s *VocabularyService) GetTermByID(ctx context.Context, language *conlangdev.Language, id uint) (*conlangdev.VocabularyTerm, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var term conlangdev.VocabularyTerm
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+vocabularyTermColumns+`
+		FROM language_vocabulary WHERE id = ? AND language_id = ? LIMIT 1`,
+		id, language.ID,
+	)
+	if err := scanVocabularyTerm(row, &term); err == sql.ErrNoRows {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.ENOTFOUND,
+			Message:    "could not find that term",
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &term, nil
+}
+
+func (s *VocabularyService) CreateTerm(ctx context.Context, language *conlangdev.Language, create conlangdev.VocabularyTermCreate) (*conlangdev.VocabularyTerm, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	term := &conlangdev.VocabularyTerm{}
+	row := tx.QueryRowContext(ctx,
+		`INSERT INTO language_vocabulary (
+			created_at, updated_at, language_id, category, value, label,
+			abbreviation, aliases
+		) VALUES (
+			NOW(), NOW(), ?, ?, ?, ?, ?, ?
+		) RETURNING `+vocabularyTermColumns,
+		language.ID, create.Category, create.Value, create.Label,
+		create.Abbreviation, encodeTags(uniqueTags(create.Aliases)),
+	)
+	if err := scanVocabularyTerm(row, term); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return nil, &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "there is already a term with that value",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return term, nil
+}
+
+func (s *VocabularyService) UpdateTerm(ctx context.Context, term *conlangdev.VocabularyTerm, update conlangdev.VocabularyTermUpdate) error {
+	if err := validateStruct(s.validate, &update); err != nil {
+		return err
+	}
+	var aliases interface{}
+	if update.Aliases != nil {
+		aliases = encodeTags(uniqueTags(*update.Aliases))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE language_vocabulary SET
+			updated_at = NOW(),
+			value = COALESCE(?, value),
+			label = COALESCE(?, label),
+			abbreviation = COALESCE(?, abbreviation),
+			aliases = COALESCE(?, aliases)
+		WHERE id = ?`,
+		update.Value, update.Label, update.Abbreviation, aliases, term.ID,
+	); err != nil {
+		if sql_err, ok := err.(*mysql.MySQLError); ok && sql_err.Number == 1062 {
+			return &conlangdev.Error{
+				Code:       conlangdev.ECONFLICT,
+				Message:    "there is already a term with that value",
+				StatusCode: http.StatusConflict,
+			}
+		}
+		return err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+vocabularyTermColumns+`
+		FROM language_vocabulary WHERE id = ?`,
+		term.ID,
+	)
+	if err := scanVocabularyTerm(row, term); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *VocabularyService) DeleteTerm(ctx context.Context, term *conlangdev.VocabularyTerm) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM language_vocabulary WHERE id = ?`,
+		term.ID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Works out the term value for each distinct value words have in the
+// categories which have terms, then updates the words one at a time so
+// that each change shows in their history. Explicit mappings are tried
+// before the terms themselves.
+func (s *VocabularyService) NormalizeWords(ctx context.Context, language *conlangdev.Language, normalize conlangdev.VocabularyNormalize) (*conlangdev.VocabularyNormalizeResult, error) {
+	branchID, err := branchIDForLanguage(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	v, err := loadVocabulary(ctx, tx, language.ID)
+	if err != nil {
+		return nil, err
+	}
+	mappings, err := resolveVocabularyMappings(v, normalize.Mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &conlangdev.VocabularyNormalizeResult{
+		Changed:   make([]*conlangdev.VocabularyChange, 0),
+		Unmatched: make([]*conlangdev.VocabularyMismatch, 0),
+	}
+	for _, category := range vocabularyCategories {
+		if _, ok := v[category]; !ok {
+			continue
+		}
+		counts, err := countWordValues(ctx, tx, language.ID, branchID, category)
+		if err != nil {
+			return nil, err
+		}
+		for _, count := range counts {
+			to, ok := mappings[category][count.value]
+			if !ok {
+				to, ok = v.resolve(category, count.value)
+			}
+			if !ok {
+				result.Unmatched = append(result.Unmatched, &conlangdev.VocabularyMismatch{
+					Category: category,
+					Value:    count.value,
+					Words:    count.words,
+				})
+				continue
+			}
+			if to == count.value {
+				continue
+			}
+			result.Changed = append(result.Changed, &conlangdev.VocabularyChange{
+				Category: category,
+				From:     count.value,
+				To:       to,
+				Words:    count.words,
+			})
+		}
+	}
+
+	if normalize.DryRun {
+		return result, nil
+	}
+
+	for _, change := range result.Changed {
+		from, to := change.From, change.To
+		var match conlangdev.WordMatch
+		var update conlangdev.WordUpdate
+		switch change.Category {
+		case conlangdev.VocabularyPartOfSpeech:
+			match.PartOfSpeech, update.PartOfSpeech = &from, &to
+		case conlangdev.VocabularyGender:
+			match.Gender, update.Gender = &from, &to
+		case conlangdev.VocabularyGrammarClass:
+			match.GrammarClass, update.GrammarClass = &from, &to
+		}
+		words, err := findMatchingWords(ctx, tx, language, branchID, &match)
+		if err != nil {
+			return nil, err
+		}
+		for _, word := range words {
+			if err := updateWord(ctx, tx, word, update); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Checks that every explicit mapping is onto the value of a term, giving
+// the mappings with their targets resolved.
+func resolveVocabularyMappings(v vocabulary, mappings map[string]map[string]string) (map[string]map[string]string, error) {
+	resolved := make(map[string]map[string]string, len(mappings))
+	var invalid []string
+	for category, variants := range mappings {
+		resolved[category] = make(map[string]string, len(variants))
+		for from, to := range variants {
+			value, ok := v.resolve(category, to)
+			if _, defined := v[category]; !defined || !ok || value == "" {
+				invalid = append(invalid, category+"."+from)
+				continue
+			}
+			resolved[category][from] = value
+		}
+	}
+
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return nil, &conlangdev.FieldsError{
+			Code:       conlangdev.EVALIDFAIL,
+			Message:    "mappings must be onto terms defined for this language",
+			StatusCode: http.StatusBadRequest,
+			Fields:     invalid,
+		}
+	}
+	return resolved, nil
+}
+
+type wordValueCount struct {
+	value string
+	words int
+}
+
+// Counts the words with each distinct value in the given column, leaving
+// out words with no value.
+func countWordValues(ctx context.Context, tx *sql.Tx, languageID uint, branchID interface{}, column string) ([]*wordValueCount, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+column+`, COUNT(*)
+		FROM words
+		WHERE language_id = ? AND branch_id <=> ? AND deleted_at IS NULL
+			AND `+column+` IS NOT NULL AND `+column+` != ''
+		GROUP BY `+column+`
+		ORDER BY `+column,
+		languageID, branchID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]*wordValueCount, 0)
+	for rows.Next() {
+		var count wordValueCount
+		if err := rows.Scan(&count.value, &count.words); err != nil {
+			return nil, err
+		}
+		counts = append(counts, &count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
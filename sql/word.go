@@ -3,8 +3,9 @@ package sql
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"encoding/json"
 	"net/http"
+	"sort"
 
 	"github.com/conlangdev/conlangdev"
 	"github.com/go-playground/validator/v10"
@@ -16,10 +17,61 @@ type WordService struct {
 	validate *validator.Validate
 }
 
+// Column list matching the order expected by scanWord.
+const wordColumns = `id, uid, created_at, updated_at, version, headword,
+	part_of_speech, definition, pronunciation, grammar_class, gender,
+	etymology, notes, language_id, COALESCE(upstream_uid, 0), upstream_updated_at,
+	COALESCE(branch_id, 0), COALESCE(origin_uid, 0), deleted_at, tags,
+	custom_fields, concepts`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
 func NewWordService(db *DB, validate *validator.Validate) *WordService {
 	return &WordService{db, validate}
 }
 
+// Scans a row selected using wordColumns into the given word.
+func scanWord(row rowScanner, word *conlangdev.Word) error {
+	var tags, customFields, concepts []byte
+	if err := row.Scan(
+		&word.ID, &word.UID, &word.CreatedAt, &word.UpdatedAt, &word.Version,
+		&word.Headword, &word.PartOfSpeech, &word.Definition, &word.Pronunciation,
+		&word.GrammarClass, &word.Gender, &word.Etymology, &word.Notes, &word.LanguageID,
+		&word.UpstreamUID, &word.UpstreamUpdatedAt, &word.BranchID,
+		&word.OriginUID, &word.DeletedAt, &tags, &customFields, &concepts,
+	); err != nil {
+		return err
+	}
+	var err error
+	if word.Tags, err = decodeTags(tags); err != nil {
+		return err
+	}
+	if word.CustomFields, err = decodeCustomFields(customFields); err != nil {
+		return err
+	}
+	word.Concepts, err = decodeTags(concepts)
+	return err
+}
+
+// Gives the ID of the branch in the context as a query argument, which is
+// NULL for the main line, making sure the branch belongs to the language.
+func branchIDForLanguage(ctx context.Context, language *conlangdev.Language) (interface{}, error) {
+	branch := conlangdev.GetBranchFromContext(ctx)
+	if branch == nil {
+		return nil, nil
+	}
+	if branch.LanguageID != language.ID {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "that branch does not belong to this language",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return branch.ID, nil
+}
+
 func (s *WordService) GetWordByID(ctx context.Context, id uint) (*conlangdev.Word, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -28,18 +80,12 @@ func (s *WordService) GetWordByID(ctx context.Context, id uint) (*conlangdev.Wor
 	defer tx.Rollback()
 
 	var word conlangdev.Word
-	if err := tx.QueryRowContext(ctx,
-		`SELECT
-			id, uid, created_at, updated_at, headword, part_of_speech,
-			definition, pronunciation, grammar_class, gender,
-			etymology, notes, language_id
-		FROM words WHERE id = ? LIMIT 1`,
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE id = ? AND deleted_at IS NULL LIMIT 1`,
 		id,
-	).Scan(
-		&word.ID, &word.UID, &word.CreatedAt, &word.UpdatedAt, &word.Headword,
-		&word.PartOfSpeech, &word.Definition, &word.Pronunciation, &word.GrammarClass,
-		&word.Gender, &word.Etymology, &word.Notes, &word.LanguageID,
-	); err == sql.ErrNoRows {
+	)
+	if err := scanWord(row, &word); err == sql.ErrNoRows {
 		return nil, &conlangdev.Error{
 			Code:       conlangdev.ENOTFOUND,
 			Message:    "could not find that language",
@@ -53,25 +99,30 @@ func (s *WordService) GetWordByID(ctx context.Context, id uint) (*conlangdev.Wor
 }
 
 func (s *WordService) GetWordByLanguageAndUID(ctx context.Context, language *conlangdev.Language, uid uint64) (*conlangdev.Word, error) {
+	branchID, err := branchIDForLanguage(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	return getWordByUID(ctx, tx, language, branchID, uid)
+}
+
+func getWordByUID(ctx context.Context, tx *sql.Tx, language *conlangdev.Language, branchID interface{}, uid uint64) (*conlangdev.Word, error) {
 	var word conlangdev.Word
-	if err := tx.QueryRowContext(ctx,
-		`SELECT
-			id, uid, created_at, updated_at, headword, part_of_speech,
-			definition, pronunciation, grammar_class, gender,
-			etymology, notes, language_id
-		FROM words WHERE uid = ? AND language_id = ? LIMIT 1`,
-		uid, language.ID,
-	).Scan(
-		&word.ID, &word.UID, &word.CreatedAt, &word.UpdatedAt, &word.Headword,
-		&word.PartOfSpeech, &word.Definition, &word.Pronunciation, &word.GrammarClass,
-		&word.Gender, &word.Etymology, &word.Notes, &word.LanguageID,
-	); err == sql.ErrNoRows {
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE uid = ? AND language_id = ? AND branch_id <=> ?
+			AND deleted_at IS NULL
+		LIMIT 1`,
+		uid, language.ID, branchID,
+	)
+	if err := scanWord(row, &word); err == sql.ErrNoRows {
 		return nil, &conlangdev.Error{
 			Code:       conlangdev.ENOTFOUND,
 			Message:    "could not find that word",
@@ -84,18 +135,55 @@ func (s *WordService) GetWordByLanguageAndUID(ctx context.Context, language *con
 	return &word, nil
 }
 
-func (s *WordService) FindWordsForLanguage(ctx context.Context, language *conlangdev.Language) ([]*conlangdev.WordIndex, error) {
+// Gives the conditions on words for the given filter, to be added to a
+// query's WHERE clause, along with their arguments.
+func wordFilterConditions(filter conlangdev.WordFilter) (string, []interface{}) {
+	conditions := ``
+	args := make([]interface{}, 0)
+	if filter.PartOfSpeech != "" {
+		conditions += ` AND part_of_speech = ?`
+		args = append(args, filter.PartOfSpeech)
+	}
+	if filter.Tag != "" {
+		conditions += ` AND JSON_CONTAINS(tags, JSON_QUOTE(?))`
+		args = append(args, filter.Tag)
+	}
+	if filter.Concept != "" {
+		conditions += ` AND JSON_CONTAINS(concepts, JSON_QUOTE(?))`
+		args = append(args, filter.Concept)
+	}
+	// Sort the field names so that the same filter always gives the same
+	// query
+	names := make([]string, 0, len(filter.CustomFields))
+	for name := range filter.CustomFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conditions += ` AND JSON_UNQUOTE(JSON_EXTRACT(custom_fields, ?)) = ?`
+		args = append(args, customFieldPath(name), filter.CustomFields[name])
+	}
+	return conditions, args
+}
+
+func (s *WordService) FindWordsForLanguage(ctx context.Context, language *conlangdev.Language, filter conlangdev.WordFilter) ([]*conlangdev.WordIndex, error) {
+	branchID, err := branchIDForLanguage(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	conditions, args := wordFilterConditions(filter)
 	rows, err := tx.QueryContext(ctx,
 		`SELECT
-			id, uid, headword, definition
-		FROM words WHERE language_id = ?`,
-		language.ID,
+			id, uid, headword, definition, tags
+		FROM words WHERE language_id = ? AND branch_id <=> ? AND deleted_at IS NULL`+conditions,
+		append([]interface{}{language.ID, branchID}, args...)...,
 	)
 	if err != nil {
 		return nil, err
@@ -105,11 +193,15 @@ func (s *WordService) FindWordsForLanguage(ctx context.Context, language *conlan
 	words := make([]*conlangdev.WordIndex, 0)
 	for rows.Next() {
 		var word conlangdev.WordIndex
+		var tags []byte
 		if err := rows.Scan(
-			&word.ID, &word.UID, &word.Headword, &word.Definition,
+			&word.ID, &word.UID, &word.Headword, &word.Definition, &tags,
 		); err != nil {
 			return nil, err
 		}
+		if word.Tags, err = decodeTags(tags); err != nil {
+			return nil, err
+		}
 		words = append(words, &word)
 	}
 	if err := rows.Err(); err != nil {
@@ -119,20 +211,51 @@ func (s *WordService) FindWordsForLanguage(ctx context.Context, language *conlan
 	return words, nil
 }
 
-func (s *WordService) CreateWordForLanguage(ctx context.Context, language *conlangdev.Language, create conlangdev.WordCreate) (*conlangdev.Word, error) {
-	if err := s.validate.Struct(&create); err != nil {
-		if val_err, ok := err.(validator.ValidationErrors); ok {
-			var fields []string
-			for _, field := range val_err {
-				fields = append(fields, field.Field())
-			}
-			return nil, &conlangdev.FieldsError{
-				Code:       conlangdev.EVALIDFAIL,
-				Message:    "validation failed",
-				StatusCode: http.StatusBadRequest,
-				Fields:     fields,
-			}
+func (s *WordService) ListWordsForLanguage(ctx context.Context, language *conlangdev.Language, filter conlangdev.WordFilter) ([]*conlangdev.Word, error) {
+	branchID, err := branchIDForLanguage(ctx, language)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	conditions, args := wordFilterConditions(filter)
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE language_id = ? AND branch_id <=> ? AND deleted_at IS NULL`+conditions+`
+		ORDER BY headword, uid`,
+		append([]interface{}{language.ID, branchID}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := make([]*conlangdev.Word, 0)
+	for rows.Next() {
+		var word conlangdev.Word
+		if err := scanWord(rows, &word); err != nil {
+			return nil, err
 		}
+		words = append(words, &word)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
+
+func (s *WordService) CreateWordForLanguage(ctx context.Context, language *conlangdev.Language, create conlangdev.WordCreate) (*conlangdev.Word, error) {
+	if err := validateStruct(s.validate, &create); err != nil {
+		return nil, err
+	}
+	branchID, err := branchIDForLanguage(ctx, language)
+	if err != nil {
 		return nil, err
 	}
 
@@ -142,25 +265,55 @@ func (s *WordService) CreateWordForLanguage(ctx context.Context, language *conla
 	}
 	defer tx.Rollback()
 
+	word, err := createWord(ctx, tx, language, branchID, create)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return word, nil
+}
+
+// Creates a word within a transaction, recording its first revision. The
+// word is expected to have been validated already.
+func createWord(ctx context.Context, tx *sql.Tx, language *conlangdev.Language, branchID interface{}, create conlangdev.WordCreate) (*conlangdev.Word, error) {
+	return insertWord(ctx, tx, language, branchID, nil, create, conlangdev.RevisionCreate)
+}
+
+// Inserts a word with the given UID, or a new one if it is nil, recording
+// its revision with the given action.
+func insertWord(ctx context.Context, tx *sql.Tx, language *conlangdev.Language, branchID interface{}, uid interface{}, create conlangdev.WordCreate, action string) (*conlangdev.Word, error) {
+	if err := checkVocabulary(ctx, tx, language.ID,
+		&create.PartOfSpeech, &create.Gender, &create.GrammarClass,
+	); err != nil {
+		return nil, err
+	}
+	customFields, err := checkCustomFields(ctx, tx, language.ID, branchID, create.CustomFields)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConcepts(create.Concepts); err != nil {
+		return nil, err
+	}
+
 	word := &conlangdev.Word{}
-	if err := tx.QueryRowContext(ctx,
+	row := tx.QueryRowContext(ctx,
 		`INSERT INTO words (
-			created_at, updated_at, headword, part_of_speech, definition,
-			pronunciation, grammar_class, gender, etymology, notes, language_id
+			uid, created_at, updated_at, headword, part_of_speech, definition,
+			pronunciation, grammar_class, gender, etymology, notes,
+			tags, custom_fields, concepts, language_id, branch_id
 		) VALUES (
-			NOW(), NOW(), ?, ?, ?, ?, ?, ?, ?, ?, ?
-		) RETURNING
-			id, uid, created_at, updated_at, headword, part_of_speech,
-			definition, pronunciation, grammar_class, gender, etymology,
-			notes, language_id`,
-		create.Headword, create.PartOfSpeech, create.Definition, create.Pronunciation,
+			COALESCE(?, UUID_SHORT()), NOW(), NOW(), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		) RETURNING `+wordColumns,
+		uid, create.Headword, create.PartOfSpeech, create.Definition, create.Pronunciation,
 		create.GrammarClass, create.Gender, create.Etymology, create.Notes,
-		language.ID,
-	).Scan(
-		&word.ID, &word.UID, &word.CreatedAt, &word.UpdatedAt, &word.Headword,
-		&word.PartOfSpeech, &word.Definition, &word.Pronunciation, &word.GrammarClass,
-		&word.Gender, &word.Etymology, &word.Notes, &word.LanguageID,
-	); err != nil {
+		encodeTags(uniqueTags(create.Tags)), encodeCustomFields(customFields),
+		encodeTags(uniqueTags(create.Concepts)), language.ID, branchID,
+	)
+	if err := scanWord(row, word); err != nil {
 		if sql_err, ok := err.(*mysql.MySQLError); ok {
 			if sql_err.Number == 1062 {
 				return nil, &conlangdev.Error{
@@ -179,7 +332,7 @@ func (s *WordService) CreateWordForLanguage(ctx context.Context, language *conla
 		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := insertWordRevision(ctx, tx, word, action); err != nil {
 		return nil, err
 	}
 
@@ -187,9 +340,227 @@ func (s *WordService) CreateWordForLanguage(ctx context.Context, language *conla
 }
 
 func (s *WordService) UpdateWord(ctx context.Context, word *conlangdev.Word, update conlangdev.WordUpdate) error {
-	return errors.New("not implemented")
+	if err := validateStruct(s.validate, &update); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateWord(ctx, tx, word, update); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Updates a word within a transaction, as long as it is still at the
+// version it was loaded at, and records the new revision. The update is
+// expected to have been validated already.
+//
+// Custom fields are only checked against their definitions when the
+// update changes them, in which case every field the word has is checked.
+func updateWord(ctx context.Context, tx *sql.Tx, word *conlangdev.Word, update conlangdev.WordUpdate) error {
+	return applyWordUpdate(ctx, tx, word, update, conlangdev.RevisionUpdate)
+}
+
+// Applies an update to a word, recording its revision with the given
+// action.
+func applyWordUpdate(ctx context.Context, tx *sql.Tx, word *conlangdev.Word, update conlangdev.WordUpdate, action string) error {
+	if err := checkVocabulary(ctx, tx, word.LanguageID,
+		update.PartOfSpeech, update.Gender, update.GrammarClass,
+	); err != nil {
+		return err
+	}
+	var tags, customFields, concepts interface{}
+	if update.Tags != nil {
+		tags = encodeTags(uniqueTags(*update.Tags))
+	}
+	if update.Concepts != nil {
+		if err := checkConcepts(*update.Concepts); err != nil {
+			return err
+		}
+		concepts = encodeTags(uniqueTags(*update.Concepts))
+	}
+	if update.CustomFields != nil {
+		merged := make(map[string]json.RawMessage, len(word.CustomFields)+len(update.CustomFields))
+		for name, value := range word.CustomFields {
+			merged[name] = value
+		}
+		for name, value := range update.CustomFields {
+			merged[name] = value
+		}
+		var branchID interface{}
+		if word.BranchID != 0 {
+			branchID = word.BranchID
+		}
+		checked, err := checkCustomFields(ctx, tx, word.LanguageID, branchID, merged)
+		if err != nil {
+			return err
+		}
+		customFields = encodeCustomFields(checked)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE words SET
+			updated_at = NOW(),
+			version = version + 1,
+			headword = COALESCE(?, headword),
+			part_of_speech = COALESCE(?, part_of_speech),
+			definition = COALESCE(?, definition),
+			pronunciation = COALESCE(?, pronunciation),
+			grammar_class = COALESCE(?, grammar_class),
+			gender = COALESCE(?, gender),
+			etymology = COALESCE(?, etymology),
+			notes = COALESCE(?, notes),
+			tags = COALESCE(?, tags),
+			custom_fields = COALESCE(?, custom_fields),
+			concepts = COALESCE(?, concepts)
+		WHERE id = ? AND version = ?`,
+		update.Headword, update.PartOfSpeech, update.Definition,
+		update.Pronunciation, update.GrammarClass, update.Gender,
+		update.Etymology, update.Notes, tags, customFields, concepts,
+		word.ID, word.Version,
+	)
+	if err != nil {
+		return err
+	}
+	if err := checkVersionUpdated(result, "word"); err != nil {
+		return err
+	}
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+wordColumns+`
+		FROM words WHERE id = ?`,
+		word.ID,
+	)
+	if err := scanWord(row, word); err != nil {
+		return err
+	}
+
+	return insertWordRevision(ctx, tx, word, action)
 }
 
+// Moves a word to the trash, recording its final state in its revision
+// history.
 func (s *WordService) DeleteWord(ctx context.Context, word *conlangdev.Word) error {
-	return errors.New("not implemented")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteWord(ctx, tx, word); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteWord(ctx context.Context, tx *sql.Tx, word *conlangdev.Word) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE words SET
+			deleted_at = NOW(), version = version + 1
+		WHERE id = ? AND version = ?`,
+		word.ID, word.Version,
+	)
+	if err != nil {
+		return err
+	}
+	if err := checkVersionUpdated(result, "word"); err != nil {
+		return err
+	}
+
+	return insertWordRevision(ctx, tx, word, conlangdev.RevisionDelete)
+}
+
+// Compares a forked language with the language it was forked from.
+//
+// Words are matched up using the upstream UID recorded on each forked
+// word. A word counts as changed in the fork if it has been updated since
+// it was copied, and as changed upstream if the upstream word has been
+// updated since the time recorded when it was copied.
+func (s *WordService) CompareWithUpstream(ctx context.Context, fork *conlangdev.Language, upstream *conlangdev.Language) (*conlangdev.UpstreamComparison, error) {
+	if fork.ForkedFromID != upstream.ID || fork.ForkedAt == nil {
+		return nil, &conlangdev.Error{
+			Code:       conlangdev.EBADREQUEST,
+			Message:    "that language was not forked from the given language",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	forkWords, err := s.ListWordsForLanguage(ctx, fork, conlangdev.WordFilter{})
+	if err != nil {
+		return nil, err
+	}
+	// Any branch in the context belongs to the fork, so the upstream side
+	// is always its main line
+	upstreamWords, err := s.ListWordsForLanguage(
+		conlangdev.NewContextWithBranch(ctx, nil), upstream, conlangdev.WordFilter{},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamByUID := make(map[uint64]*conlangdev.Word, len(upstreamWords))
+	for _, word := range upstreamWords {
+		upstreamByUID[word.UID] = word
+	}
+
+	comparison := &conlangdev.UpstreamComparison{
+		AddedInFork:     make([]*conlangdev.WordChange, 0),
+		ChangedInFork:   make([]*conlangdev.WordChange, 0),
+		RemovedInFork:   make([]*conlangdev.WordChange, 0),
+		AddedUpstream:   make([]*conlangdev.WordChange, 0),
+		ChangedUpstream: make([]*conlangdev.WordChange, 0),
+		RemovedUpstream: make([]*conlangdev.WordChange, 0),
+	}
+	copied := make(map[uint64]bool, len(forkWords))
+	for _, word := range forkWords {
+		change := &conlangdev.WordChange{
+			UID:         word.UID,
+			UpstreamUID: word.UpstreamUID,
+			Headword:    word.Headword,
+		}
+		if word.UpstreamUID == 0 {
+			comparison.AddedInFork = append(comparison.AddedInFork, change)
+			continue
+		}
+		copied[word.UpstreamUID] = true
+		upstreamWord, ok := upstreamByUID[word.UpstreamUID]
+		if !ok {
+			comparison.RemovedUpstream = append(comparison.RemovedUpstream, change)
+			continue
+		}
+		// Copies start at the first version, and every edit since moves them
+		// on, which timestamps cannot show within the second of the fork
+		if word.Version > 1 {
+			comparison.ChangedInFork = append(comparison.ChangedInFork, change)
+		}
+		if word.UpstreamUpdatedAt != nil && upstreamWord.UpdatedAt.After(*word.UpstreamUpdatedAt) {
+			comparison.ChangedUpstream = append(comparison.ChangedUpstream, change)
+		}
+	}
+
+	// Upstream words with no copy either existed when the fork was made
+	// and were removed from the fork, or were added upstream afterwards and
+	// so have a higher ID than any upstream word had then
+	for _, word := range upstreamWords {
+		if copied[word.UID] {
+			continue
+		}
+		change := &conlangdev.WordChange{
+			UpstreamUID: word.UID,
+			Headword:    word.Headword,
+		}
+		if word.ID > fork.ForkedWordID {
+			comparison.AddedUpstream = append(comparison.AddedUpstream, change)
+		} else {
+			comparison.RemovedInFork = append(comparison.RemovedInFork, change)
+		}
+	}
+
+	return comparison, nil
 }
@@ -0,0 +1,225 @@
+package conlangdev
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// How many clusters and homographs are listed in stats, most common first.
+const statsListLimit = 20
+
+// How often a value occurs among the words of a language.
+type StatsFrequency struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// The words added to a language in a month, and the total at its end.
+type StatsGrowth struct {
+	Month string `json:"month"`
+	Added int    `json:"added"`
+	Total int    `json:"total"`
+}
+
+// Statistics on the words of a language, or of one of its branches.
+// Grapheme frequencies are taken from headwords and phoneme frequencies
+// from pronunciations. Clusters are the consonants before the first and
+// after the last vowel of a word, going by its pronunciation if it has
+// one and its headword if not.
+type LanguageStats struct {
+	GeneratedAt           time.Time         `json:"generated_at"`
+	WordCount             int               `json:"word_count"`
+	PartsOfSpeech         []*StatsFrequency `json:"parts_of_speech"`
+	Genders               []*StatsFrequency `json:"genders"`
+	Growth                []*StatsGrowth    `json:"growth"`
+	AverageHeadwordLength float64           `json:"average_headword_length"`
+	Graphemes             []*StatsFrequency `json:"graphemes"`
+	Phonemes              []*StatsFrequency `json:"phonemes"`
+	InitialClusters       []*StatsFrequency `json:"initial_clusters"`
+	FinalClusters         []*StatsFrequency `json:"final_clusters"`
+	// Headwords shared by more than one word, and how many words share
+	// them between them.
+	HomographCount     int               `json:"homograph_count"`
+	HomographWordCount int               `json:"homograph_word_count"`
+	Homographs         []*StatsFrequency `json:"homographs"`
+}
+
+// Vowels, as IPA symbols and common Latin letters, used to find where the
+// clusters at either end of a word stop.
+const statsVowels = "iyɨʉɯuɪʏʊeøɘɵɤoəɛœɜɞʌɔæɐaɶɑɒ" +
+	"áàâäãåāéèêëēíìîïīóòôöõōúùûüūýÿ"
+
+// Splits text into segments of a base character followed by any marks and
+// modifiers attached to it, leaving out spaces and punctuation. When
+// modifiers is set, modifier letters such as ʰ and ː are attached to the
+// segment before them, as they are in IPA.
+func splitSegments(text string, modifiers bool) []string {
+	segments := make([]string, 0)
+	joinNext := false
+	for _, r := range strings.ToLower(text) {
+		attach := unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+			(modifiers && unicode.Is(unicode.Lm, r))
+		if len(segments) > 0 && (attach || joinNext) {
+			segments[len(segments)-1] += string(r)
+			// Tie bars join the next character into the same segment
+			joinNext = r == '͡' || r == '͜'
+			continue
+		}
+		joinNext = false
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			segments = append(segments, string(r))
+		}
+	}
+	return segments
+}
+
+// Splits a pronunciation into phonemes, ignoring any slashes or brackets
+// around it along with stress marks and syllable breaks.
+func splitPhonemes(pronunciation string) []string {
+	pronunciation = strings.Map(func(r rune) rune {
+		switch r {
+		case 'ˈ', 'ˌ', '.':
+			return -1
+		}
+		return r
+	}, pronunciation)
+	return splitSegments(pronunciation, true)
+}
+
+func isVowelSegment(segment string) bool {
+	for _, r := range segment {
+		return strings.ContainsRune(statsVowels, r)
+	}
+	return false
+}
+
+// Gives the consonants before the first vowel and after the last vowel of
+// a word, which are empty if it begins or ends with a vowel.
+func edgeClusters(segments []string) (string, string) {
+	first, last := -1, -1
+	for i, segment := range segments {
+		if isVowelSegment(segment) {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return "", ""
+	}
+	return strings.Join(segments[:first], ""), strings.Join(segments[last+1:], "")
+}
+
+type frequencyCounter map[string]int
+
+func (c frequencyCounter) add(value string) {
+	if value != "" {
+		c[value]++
+	}
+}
+
+// Lists the counted values, most frequent first, keeping at most limit of
+// them unless limit is zero.
+func (c frequencyCounter) list(limit int) []*StatsFrequency {
+	frequencies := make([]*StatsFrequency, 0, len(c))
+	for value, count := range c {
+		frequencies = append(frequencies, &StatsFrequency{value, count})
+	}
+	sort.Slice(frequencies, func(i, j int) bool {
+		if frequencies[i].Count != frequencies[j].Count {
+			return frequencies[i].Count > frequencies[j].Count
+		}
+		return frequencies[i].Value < frequencies[j].Value
+	})
+	if limit > 0 && len(frequencies) > limit {
+		frequencies = frequencies[:limit]
+	}
+	return frequencies
+}
+
+// Works out statistics for the given words.
+func ComputeLanguageStats(words []*Word) *LanguageStats {
+	partsOfSpeech := make(frequencyCounter)
+	genders := make(frequencyCounter)
+	months := make(frequencyCounter)
+	graphemes := make(frequencyCounter)
+	phonemes := make(frequencyCounter)
+	initials := make(frequencyCounter)
+	finals := make(frequencyCounter)
+	headwords := make(frequencyCounter)
+	headwordLength := 0
+
+	for _, word := range words {
+		partsOfSpeech.add(word.PartOfSpeech)
+		genders.add(word.Gender)
+		months.add(word.CreatedAt.UTC().Format("2006-01"))
+		headwords.add(word.Headword)
+
+		segments := splitSegments(word.Headword, false)
+		headwordLength += len(segments)
+		for _, grapheme := range segments {
+			graphemes.add(grapheme)
+		}
+		if word.Pronunciation != "" {
+			segments = splitPhonemes(word.Pronunciation)
+			for _, phoneme := range segments {
+				phonemes.add(phoneme)
+			}
+		}
+		initial, final := edgeClusters(segments)
+		initials.add(initial)
+		finals.add(final)
+	}
+
+	stats := &LanguageStats{
+		GeneratedAt:     time.Now(),
+		WordCount:       len(words),
+		PartsOfSpeech:   partsOfSpeech.list(0),
+		Genders:         genders.list(0),
+		Growth:          make([]*StatsGrowth, 0, len(months)),
+		Graphemes:       graphemes.list(0),
+		Phonemes:        phonemes.list(0),
+		InitialClusters: initials.list(statsListLimit),
+		FinalClusters:   finals.list(statsListLimit),
+		Homographs:      make([]*StatsFrequency, 0),
+	}
+	if len(words) > 0 {
+		stats.AverageHeadwordLength = float64(headwordLength) / float64(len(words))
+	}
+
+	// Months are listed in order, with the running total at each
+	monthNames := make([]string, 0, len(months))
+	for month := range months {
+		monthNames = append(monthNames, month)
+	}
+	sort.Strings(monthNames)
+	total := 0
+	for _, month := range monthNames {
+		total += months[month]
+		stats.Growth = append(stats.Growth, &StatsGrowth{month, months[month], total})
+	}
+
+	for _, homograph := range headwords.list(0) {
+		if homograph.Count < 2 {
+			break
+		}
+		stats.HomographCount++
+		stats.HomographWordCount += homograph.Count
+		if len(stats.Homographs) < statsListLimit {
+			stats.Homographs = append(stats.Homographs, homograph)
+		}
+	}
+
+	return stats
+}
+
+// Stats services act on the branch in the context, or the main line of the
+// language if there is none. Stats are cached until any word in the
+// language changes.
+type StatsService interface {
+	GetStatsForLanguage(ctx context.Context, language *Language) (*LanguageStats, error)
+}
@@ -0,0 +1,95 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+// Roles a user can hold within a team. Team owners own every language the
+// team owns, admins manage them and members edit them.
+const (
+	TeamRoleOwner  = "owner"
+	TeamRoleAdmin  = "admin"
+	TeamRoleMember = "member"
+)
+
+// Reports how privileged a team role is, so that roles can be compared.
+func TeamRoleRank(role string) int {
+	switch role {
+	case TeamRoleMember:
+		return 1
+	case TeamRoleAdmin:
+		return 2
+	case TeamRoleOwner:
+		return 3
+	}
+	return 0
+}
+
+// Gives the role a team role grants on the languages the team owns.
+func LanguageRoleForTeamRole(role string) string {
+	switch role {
+	case TeamRoleOwner:
+		return RoleOwner
+	case TeamRoleAdmin:
+		return RoleAdmin
+	case TeamRoleMember:
+		return RoleEditor
+	}
+	return ""
+}
+
+// A team is a shared namespace which can own languages. Team slugs share
+// the namespace with usernames, so both can be used in language routes.
+type Team struct {
+	ID          uint      `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+}
+
+type TeamCreate struct {
+	Slug        string `json:"slug" validate:"min=3,max=32"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+type TeamUpdate struct {
+	Name        *string `json:"name" validate:"omitempty,min=1"`
+	Description *string `json:"description"`
+}
+
+type TeamMember struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	TeamID    uint      `json:"team_id"`
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+}
+
+type TeamMemberCreate struct {
+	Username string `json:"username" validate:"required"`
+	Role     string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+type TeamMemberUpdate struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+type TeamService interface {
+	GetTeamByID(ctx context.Context, id uint) (*Team, error)
+	GetTeamBySlug(ctx context.Context, slug string) (*Team, error)
+	FindTeamsForUser(ctx context.Context, user *User) ([]*Team, error)
+	CreateTeam(ctx context.Context, owner *User, create TeamCreate) (*Team, error)
+	UpdateTeam(ctx context.Context, team *Team, update TeamUpdate) error
+	DeleteTeam(ctx context.Context, team *Team) error
+	GetMemberForTeam(ctx context.Context, team *Team, user *User) (*TeamMember, error)
+	FindMembersForTeam(ctx context.Context, team *Team) ([]*TeamMember, error)
+	AddMember(ctx context.Context, team *Team, create TeamMemberCreate) (*TeamMember, error)
+	UpdateMember(ctx context.Context, member *TeamMember, update TeamMemberUpdate) error
+	RemoveMember(ctx context.Context, member *TeamMember) error
+}
@@ -0,0 +1,93 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA-1 secret from the test vectors in RFC 6238, "12345678901234567890"
+// encoded in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The SHA-1 test vectors from RFC 6238, appendix B, cut down to the six
+// digits used here, which are the last six of the eight given there.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCode(t *testing.T) {
+	for _, vector := range rfcVectors {
+		code, err := Code(rfcSecret, Step(time.Unix(vector.unix, 0)))
+		if err != nil {
+			t.Fatalf("%d: %v", vector.unix, err)
+		}
+		if code != vector.code {
+			t.Errorf("%d: got %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestCodeLowerCaseSecret(t *testing.T) {
+	code, err := Code(strings.ToLower(rfcSecret), Step(time.Unix(59, 0)))
+	if err != nil || code != "287082" {
+		t.Errorf("got %q, %v, want 287082", code, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	tests := []struct {
+		name string
+		code string
+		at   time.Time
+		step int64
+		ok   bool
+	}{
+		{"current code", "050471", now, step, true},
+		{"code with a space", "050 471", now, step, true},
+		{"code from the last period", "050471", now.Add(Period), step, true},
+		{"code from the next period", "050471", now.Add(-Period), step, true},
+		{"code from too long ago", "050471", now.Add(2 * Period), 0, false},
+		{"code from too far ahead", "050471", now.Add(-2 * Period), 0, false},
+		{"wrong code", "123456", now, 0, false},
+		{"too short", "05047", now, 0, false},
+		{"too long", "0504711", now, 0, false},
+	}
+	for _, test := range tests {
+		got, ok := Validate(rfcSecret, test.code, test.at)
+		if ok != test.ok || got != test.step {
+			t.Errorf("%s: got %d, %t, want %d, %t", test.name, got, ok, test.step, test.ok)
+		}
+	}
+}
+
+func TestValidateInvalidSecret(t *testing.T) {
+	if _, ok := Validate("not base32!", "123456", time.Now()); ok {
+		t.Error("accepted a code for an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := Code(secret, Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Validate(secret, code, time.Now()); !ok {
+		t.Error("a code for a generated secret did not validate")
+	}
+}
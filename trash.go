@@ -0,0 +1,39 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+// How long deleted languages and words are kept in the trash when no other
+// retention period is configured.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// A deleted language which can still be restored until it expires.
+type TrashedLanguage struct {
+	*Language
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// A deleted word which can still be restored until it expires.
+type TrashedWord struct {
+	*Word
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Deleting a language or word only moves it to the trash, where it is
+// hidden everywhere else. Trash services look after those items until they
+// are either restored or purged for good once the retention period is up.
+type TrashService interface {
+	FindTrashedLanguagesForUser(ctx context.Context, user *User) ([]*TrashedLanguage, error)
+	FindTrashedLanguagesForTeam(ctx context.Context, team *Team) ([]*TrashedLanguage, error)
+	GetTrashedLanguageByNamespaceAndSlug(ctx context.Context, namespace string, slug string) (*TrashedLanguage, error)
+	FindTrashedWordsForLanguage(ctx context.Context, language *Language) ([]*TrashedWord, error)
+	GetTrashedWordByLanguageAndUID(ctx context.Context, language *Language, uid uint64) (*TrashedWord, error)
+	RestoreLanguage(ctx context.Context, language *Language) error
+	RestoreWord(ctx context.Context, word *Word) error
+	// Permanently deletes everything which has been in the trash for
+	// longer than the retention period, returning how many languages and
+	// words were removed.
+	PurgeExpired(ctx context.Context) (languages int64, words int64, err error)
+}
@@ -0,0 +1,52 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+// How long a user has to give their second factor once they have logged in
+// with their password, and how many tries they get at it.
+const (
+	TwoFactorChallengeLifetime = 5 * time.Minute
+	TwoFactorChallengeAttempts = 5
+)
+
+// How many recovery codes are handed out when two-factor authentication is
+// enabled. Each one can be used once in place of a code from the app.
+const RecoveryCodeCount = 10
+
+// A secret for an authenticator app, given out when setting up two-factor
+// authentication. The URI can be shown as a QR code for apps to scan.
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Two-factor authentication asks users who have enabled it for a code from
+// an authenticator app, or one of their recovery codes, after their
+// password. API tokens are not affected, as they are created from a session
+// which already passed the second step and can only touch the languages
+// they are scoped to.
+type TwoFactorService interface {
+	// Creates a new secret for the user, which is not used until it is
+	// confirmed with EnableTwoFactor.
+	SetupTwoFactor(ctx context.Context, user *User) (*TwoFactorSetup, error)
+	// Turns on two-factor authentication once the user has shown their app
+	// gives the right codes, giving their recovery codes.
+	EnableTwoFactor(ctx context.Context, user *User, code string) ([]string, error)
+	// Turns off two-factor authentication, removing the secret and any
+	// recovery codes. This is also how it is reset for users who have lost
+	// both their app and their recovery codes.
+	DisableTwoFactor(ctx context.Context, user *User) error
+	// Replaces the recovery codes of the user with new ones.
+	RegenerateRecoveryCodes(ctx context.Context, user *User) ([]string, error)
+	// Checks a code from the app or a recovery code, which can then not be
+	// used again.
+	CheckTwoFactorCode(ctx context.Context, user *User, code string) error
+	// Creates a token standing in for the user between logging in with
+	// their password and giving their second factor.
+	CreateTwoFactorChallenge(ctx context.Context, user *User) (string, error)
+	// Finishes a challenge with a code, giving its user.
+	CompleteTwoFactorChallenge(ctx context.Context, challenge string, code string) (*User, error)
+}
@@ -5,18 +5,87 @@ import (
 	"time"
 )
 
+// How long deleted accounts are kept before they are purged, during which
+// the deletion can still be cancelled.
+const AccountDeletionGracePeriod = 30 * 24 * time.Hour
+
 type User struct {
 	ID           uint      `json:"id"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	Username     string    `json:"username"`
+	Role         string    `json:"role"`
 	Email        string    `json:"email"`
 	DisplayName  string    `json:"display_name"`
 	PasswordHash string    `json:"-"`
+	// When the user confirmed that their email address is theirs. Until
+	// then they cannot publish languages.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// When the user turned on two-factor authentication, if they have.
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at"`
+	Bio                string     `json:"bio"`
+	AvatarURL          string     `json:"avatar_url"`
+	Links              []string   `json:"links"`
+	// Which parts of the profile of the user are shown to others.
+	Privacy ProfilePrivacy `json:"privacy"`
+	// When an administrator hid the bio, avatar and links of the user.
+	ProfileHiddenAt *time.Time `json:"profile_hidden_at,omitempty"`
+	// When an administrator suspended the user, and why.
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	// When the user asked for their account to be deleted, if they have.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
+func (u *User) Suspended() bool {
+	return u.SuspendedAt != nil
+}
+
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactorEnabledAt != nil
+}
+
+// Which parts of their public profile a user shows. Everything is shown
+// unless the user hides it.
+type ProfilePrivacy struct {
+	ShowBio       bool `json:"show_bio"`
+	ShowAvatar    bool `json:"show_avatar"`
+	ShowLinks     bool `json:"show_links"`
+	ShowJoinDate  bool `json:"show_join_date"`
+	ShowLanguages bool `json:"show_languages"`
+}
+
+var DefaultProfilePrivacy = ProfilePrivacy{
+	ShowBio:       true,
+	ShowAvatar:    true,
+	ShowLinks:     true,
+	ShowJoinDate:  true,
+	ShowLanguages: true,
+}
+
+type ProfilePrivacyUpdate struct {
+	ShowBio       *bool `json:"show_bio"`
+	ShowAvatar    *bool `json:"show_avatar"`
+	ShowLinks     *bool `json:"show_links"`
+	ShowJoinDate  *bool `json:"show_join_date"`
+	ShowLanguages *bool `json:"show_languages"`
 }
 
 type UserUpdate struct {
-	DisplayName string `json:"display_name"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=255"`
+	Bio         *string `json:"bio" validate:"omitempty,max=2000"`
+	// An empty string removes the avatar.
+	AvatarURL *string               `json:"avatar_url" validate:"omitempty,url,max=255"`
+	Links     *[]string             `json:"links" validate:"omitempty,max=5,dive,url,max=255"`
+	Privacy   *ProfilePrivacyUpdate `json:"privacy"`
 }
 
 type UserCreate struct {
@@ -26,20 +95,70 @@ type UserCreate struct {
 	Password    string `json:"password" validate:"min=8"`
 }
 
+// What anybody can see of a user. Parts the user has hidden are left out.
 type UserView struct {
-	Username    string `json:"username"`
-	DisplayName string `json:"display_name"`
+	Username    string     `json:"username"`
+	DisplayName string     `json:"display_name"`
+	Bio         string     `json:"bio,omitempty"`
+	AvatarURL   string     `json:"avatar_url,omitempty"`
+	Links       []string   `json:"links,omitempty"`
+	JoinedAt    *time.Time `json:"joined_at,omitempty"`
+}
+
+// A public language as listed on the profile of its owner.
+type ProfileLanguage struct {
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Endonym   string    `json:"endonym"`
+	UpdatedAt time.Time `json:"updated_at"`
+	WordCount int       `json:"word_count"`
+}
+
+// The full public profile of a user, which also lists their public
+// languages unless they have hidden them.
+type UserProfile struct {
+	UserView
+	Languages []*ProfileLanguage `json:"languages,omitempty"`
+}
+
+// Everything deleting an account removes, so that users can be warned
+// before they go ahead.
+type AccountDeletion struct {
+	// When the account would be purged if it was deleted now, or when it
+	// will be purged if it already has been.
+	PurgeAt time.Time `json:"purge_at"`
+	// Languages owned by the user or by teams only they belong to.
+	Languages []*Language `json:"languages"`
+	WordCount int         `json:"word_count"`
+	// Teams only the user belongs to, which are deleted with them.
+	Teams []*Team `json:"teams"`
+	// Teams the user is the only owner of while others belong to them.
+	// The account cannot be deleted until they are handed over.
+	BlockingTeams []*Team `json:"blocking_teams"`
 }
 
 type UserService interface {
 	GetUserByID(ctx context.Context, id uint) (*User, error)
 	GetUserByUsername(ctx context.Context, username string) (*User, error)
-	GetUserByJWT(ctx context.Context, jwt string) (*User, error)
-	GenerateJWTForUser(ctx context.Context, user *User) (string, error)
 	CreateUser(ctx context.Context, create UserCreate) (*User, error)
 	UpdateUser(ctx context.Context, user *User, update UserUpdate) error
+	// Describes what deleting the user would remove.
+	GetDeletionForUser(ctx context.Context, user *User) (*AccountDeletion, error)
+	// Schedules the user to be purged once the grace period is up. Their
+	// sessions and API tokens are ended straight away, and their
+	// languages moved to the trash.
 	DeleteUser(ctx context.Context, user *User) error
+	// Cancels the deletion of a user within the grace period.
+	RestoreUser(ctx context.Context, user *User) error
+	// Permanently deletes users whose grace period is up, along with
+	// everything they own, giving how many were deleted.
+	PurgeDeletedUsers(ctx context.Context) (int64, error)
+	// Changing the password revokes every session of the user.
 	UpdateUserPassword(ctx context.Context, user *User, password string) error
 	CheckUserPassword(ctx context.Context, user *User, password string) error
+	// Gives what others can see of the user, following their privacy
+	// settings.
 	GetViewForUser(ctx context.Context, user *User) (*UserView, error)
+	// Gives the view of the user along with their public languages.
+	GetProfileForUser(ctx context.Context, user *User) (*UserProfile, error)
 }
@@ -0,0 +1,87 @@
+package conlangdev
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	VocabularyPartOfSpeech = "part_of_speech"
+	VocabularyGender       = "gender"
+	VocabularyGrammarClass = "grammar_class"
+)
+
+// One of the values a language allows for the part of speech, gender or
+// grammar class of its words. Words may be given the label, abbreviation
+// or any alias of a term in place of its value, which is what is stored.
+// Categories with no terms are left unconstrained.
+type VocabularyTerm struct {
+	ID           uint      `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	LanguageID   uint      `json:"language_id"`
+	Category     string    `json:"category"`
+	Value        string    `json:"value"`
+	Label        string    `json:"label"`
+	Abbreviation string    `json:"abbreviation"`
+	Aliases      []string  `json:"aliases"`
+}
+
+type VocabularyTermCreate struct {
+	Category     string   `json:"category" validate:"required,oneof=part_of_speech gender grammar_class"`
+	Value        string   `json:"value" validate:"required,max=255"`
+	Label        string   `json:"label" validate:"max=255"`
+	Abbreviation string   `json:"abbreviation" validate:"max=255"`
+	Aliases      []string `json:"aliases" validate:"max=64,dive,min=1,max=255"`
+}
+
+// Changing the value of a term does not change the words which already
+// have it, see VocabularyService.NormalizeWords.
+type VocabularyTermUpdate struct {
+	Value        *string   `json:"value" validate:"omitempty,min=1,max=255"`
+	Label        *string   `json:"label" validate:"omitempty,max=255"`
+	Abbreviation *string   `json:"abbreviation" validate:"omitempty,max=255"`
+	Aliases      *[]string `json:"aliases" validate:"omitempty,max=64,dive,min=1,max=255"`
+}
+
+type VocabularyNormalize struct {
+	// Reports what would be changed without changing anything.
+	DryRun bool `json:"dry_run"`
+	// Extra variants to map onto term values, by category, for variants
+	// which are not already an alias of a term.
+	Mappings map[string]map[string]string `json:"mappings"`
+}
+
+// A variant found on words and the term value it was mapped onto.
+type VocabularyChange struct {
+	Category string `json:"category"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Words    int    `json:"words"`
+}
+
+// A value found on words which could not be mapped onto any term.
+type VocabularyMismatch struct {
+	Category string `json:"category"`
+	Value    string `json:"value"`
+	Words    int    `json:"words"`
+}
+
+type VocabularyNormalizeResult struct {
+	Changed   []*VocabularyChange   `json:"changed"`
+	Unmatched []*VocabularyMismatch `json:"unmatched"`
+}
+
+// Vocabulary services normalise the words on the branch in the context, or
+// the main line if there is none, recording each change in the history of
+// the word.
+type VocabularyService interface {
+	FindTermsForLanguage(ctx context.Context, language *Language) ([]*VocabularyTerm, error)
+	GetTermByID(ctx context.Context, language *Language, id uint) (*VocabularyTerm, error)
+	CreateTerm(ctx context.Context, language *Language, create VocabularyTermCreate) (*VocabularyTerm, error)
+	UpdateTerm(ctx context.Context, term *VocabularyTerm, update VocabularyTermUpdate) error
+	DeleteTerm(ctx context.Context, term *VocabularyTerm) error
+	// Maps the variants found on words onto the values of the terms they
+	// match, reporting any values left which match no term.
+	NormalizeWords(ctx context.Context, language *Language, normalize VocabularyNormalize) (*VocabularyNormalizeResult, error)
+}
@@ -2,6 +2,7 @@ package conlangdev
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 )
 
@@ -10,6 +11,7 @@ type Word struct {
 	UID           uint64    `json:"uid"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
+	Version       int       `json:"version"`
 	Headword      string    `json:"headword"`
 	PartOfSpeech  string    `json:"part_of_speech"`
 	Definition    string    `json:"definition"`
@@ -18,18 +20,62 @@ type Word struct {
 	Gender        string    `json:"gender"`
 	Etymology     string    `json:"etymology"`
 	Notes         string    `json:"notes"`
+	Tags          []string  `json:"tags"`
 	LanguageID    uint      `json:"language_id"`
+	// For words copied when forking a language, the UID of the upstream
+	// word and when it was last updated at the time of the fork.
+	UpstreamUID       uint64     `json:"upstream_uid"`
+	UpstreamUpdatedAt *time.Time `json:"upstream_updated_at"`
+	// For words on a branch, the branch and the UID of the main word they
+	// were copied from, if any.
+	BranchID  uint   `json:"branch_id,omitempty"`
+	OriginUID uint64 `json:"origin_uid,omitempty"`
+	// When the word was moved to the trash, if it has been deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Values for the custom fields defined by the language, by field name.
+	// Values are kept as they were given once checked against their
+	// definitions, so that word UIDs keep their full precision.
+	CustomFields map[string]json.RawMessage `json:"custom_fields"`
+	// IDs of the reference concepts the word stands for, see ConceptList.
+	Concepts []string `json:"concepts"`
+}
+
+// A named text field of a word, pointing into the word itself.
+type WordField struct {
+	Name  string
+	Value *string
+}
+
+// Lists the text fields of a word in a fixed order.
+func (w *Word) Fields() []WordField {
+	return []WordField{
+		{"headword", &w.Headword},
+		{"part_of_speech", &w.PartOfSpeech},
+		{"definition", &w.Definition},
+		{"pronunciation", &w.Pronunciation},
+		{"grammar_class", &w.GrammarClass},
+		{"gender", &w.Gender},
+		{"etymology", &w.Etymology},
+		{"notes", &w.Notes},
+	}
 }
 
 type WordUpdate struct {
-	Headword      string `json:"headword"`
-	PartOfSpeech  string `json:"part_of_speech"`
-	Definition    string `json:"definition"`
-	Pronunciation string `json:"pronunciation"`
-	GrammarClass  string `json:"grammar_class"`
-	Gender        string `json:"gender"`
-	Etymology     string `json:"etymology"`
-	Notes         string `json:"notes"`
+	Headword      *string `json:"headword" validate:"omitempty,min=1"`
+	PartOfSpeech  *string `json:"part_of_speech" validate:"omitempty,min=1"`
+	Definition    *string `json:"definition" validate:"omitempty,min=1"`
+	Pronunciation *string `json:"pronunciation"`
+	GrammarClass  *string `json:"grammar_class"`
+	Gender        *string `json:"gender"`
+	Etymology     *string `json:"etymology"`
+	Notes         *string `json:"notes"`
+	// Replaces every tag of the word when given.
+	Tags *[]string `json:"tags" validate:"omitempty,max=64,dive,min=1,max=64"`
+	// Sets the given custom fields, leaving any others as they are. Fields
+	// set to null are removed.
+	CustomFields map[string]json.RawMessage `json:"custom_fields"`
+	// Replaces every concept the word is linked to when given.
+	Concepts *[]string `json:"concepts" validate:"omitempty,max=64,dive,min=1,max=64"`
 }
 
 type WordCreate struct {
@@ -41,20 +87,65 @@ type WordCreate struct {
 	Gender        string `json:"gender"`
 	Etymology     string `json:"etymology"`
 	Notes         string `json:"notes"`
+	// Tags are free-form, while custom fields are checked against the
+	// definitions of the language.
+	Tags         []string                   `json:"tags" validate:"max=64,dive,min=1,max=64"`
+	CustomFields map[string]json.RawMessage `json:"custom_fields"`
+	Concepts     []string                   `json:"concepts" validate:"max=64,dive,min=1,max=64"`
 }
 
 type WordIndex struct {
-	ID         uint   `json:"id"`
-	UID        uint64 `json:"uid"`
-	Headword   string `json:"headword"`
-	Definition string `json:"definition"`
+	ID         uint     `json:"id"`
+	UID        uint64   `json:"uid"`
+	Headword   string   `json:"headword"`
+	Definition string   `json:"definition"`
+	Tags       []string `json:"tags"`
+}
+
+// A word which differs between a fork and its upstream language.
+type WordChange struct {
+	UID         uint64 `json:"uid,omitempty"`
+	UpstreamUID uint64 `json:"upstream_uid,omitempty"`
+	Headword    string `json:"headword"`
+}
+
+// Differences between a forked language and the language it was forked
+// from, on both sides, since the fork was made.
+type UpstreamComparison struct {
+	AddedInFork     []*WordChange `json:"added_in_fork"`
+	ChangedInFork   []*WordChange `json:"changed_in_fork"`
+	RemovedInFork   []*WordChange `json:"removed_in_fork"`
+	AddedUpstream   []*WordChange `json:"added_upstream"`
+	ChangedUpstream []*WordChange `json:"changed_upstream"`
+	RemovedUpstream []*WordChange `json:"removed_upstream"`
+}
+
+type WordFilter struct {
+	PartOfSpeech string
+	Tag          string
+	// The ID of a reference concept words are linked to.
+	Concept string
+	// Custom field values to match, by field name. Values are compared as
+	// text, so `true` matches a boolean field which is set.
+	CustomFields map[string]string
 }
 
+// Word services act on the branch in the context, or the main line of the
+// language if there is none, and record the user in the context as the
+// author of the revisions made by any change to a word. Words which have
+// been changed since they were loaded are not updated or deleted, going
+// by their version.
 type WordService interface {
 	GetWordByID(ctx context.Context, id uint) (*Word, error)
 	GetWordByLanguageAndUID(ctx context.Context, language *Language, uid uint64) (*Word, error)
-	FindWordsForLanguage(ctx context.Context, language *Language) ([]*WordIndex, error)
+	FindWordsForLanguage(ctx context.Context, language *Language, filter WordFilter) ([]*WordIndex, error)
+	ListWordsForLanguage(ctx context.Context, language *Language, filter WordFilter) ([]*Word, error)
 	CreateWordForLanguage(ctx context.Context, language *Language, create WordCreate) (*Word, error)
 	UpdateWord(ctx context.Context, word *Word, update WordUpdate) error
+	// Moves a word to the trash, see TrashService.
 	DeleteWord(ctx context.Context, word *Word) error
+	// Applies a batch of operations in a single transaction. Operations
+	// which fail are reported in the result rather than as an error.
+	ApplyWordBatch(ctx context.Context, language *Language, batch WordBatch) (*WordBatchResult, error)
+	CompareWithUpstream(ctx context.Context, fork *Language, upstream *Language) (*UpstreamComparison, error)
 }